@@ -0,0 +1,31 @@
+package main
+
+import "strings"
+
+// arrayStyle sends every candidate with two values, for parameters only read
+// as arrays: repeated (id=a&id=b), with brackets (id[]=a&id[]=b) or comma
+// separated (id=a,b). Repeated values become arrays in JSON bodies and
+// repeated elements in XML ones. Empty sends a single value.
+var arrayStyle string
+
+// Array styles.
+const (
+	arrayRepeat   = "repeat"
+	arrayBrackets = "brackets"
+	arrayComma    = "comma"
+)
+
+// arrayStyles lists the supported values of arrayStyle.
+var arrayStyles = []string{arrayRepeat, arrayBrackets, arrayComma}
+
+// canaryValues returns the values a candidate is sent with in arrayStyle,
+// drawing each canary from next.
+func canaryValues(next func() string) []string {
+	switch arrayStyle {
+	case arrayRepeat, arrayBrackets:
+		return []string{next(), next()}
+	case arrayComma:
+		return []string{strings.Join([]string{next(), next()}, ",")}
+	}
+	return []string{next()}
+}
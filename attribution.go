@@ -0,0 +1,41 @@
+package main
+
+// reflectionShortcut attributes a changed chunk directly to the parameters
+// whose value was reflected, instead of bisecting the chunk to find them.
+var reflectionShortcut = true
+
+// splitReflectedPart splits a changed chunk into one part per reflected
+// parameter, which need no further requests, plus the remaining parameters
+// when the response still differs from the baselines once the reflections
+// are discounted.
+func splitReflectedPart(part []string, response ResponseData, initialResponses InitialResponses) [][]string {
+	if !reflectionShortcut || catchAll == catchAllReflects || len(response.ReflectedParams) == 0 || len(part) == 1 {
+		return [][]string{part}
+	}
+
+	isReflected := make(map[string]bool)
+	var parts [][]string
+	for _, param := range response.ReflectedParams {
+		isReflected[param] = true
+		evidence.record([]string{param}, response, true)
+		parts = append(parts, []string{param})
+	}
+
+	var rest []string
+	for _, param := range part {
+		if !isReflected[param] {
+			rest = append(rest, param)
+		}
+	}
+
+	stripped := response
+	stripped.Body = stripReflections(response)
+	stripped.BodyHash = [len(response.BodyHash)]byte{}
+	stripped.Reflections = 0
+	stripped.ReflectedParams = nil
+	stripped.ReflectedValues = nil
+	if len(rest) > 0 && responseChanged(initialResponses.Responses, stripped, initialResponses.SameBody) {
+		parts = append(parts, rest)
+	}
+	return parts
+}
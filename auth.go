@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// authCredentials holds the "user:pass" used to authenticate every request.
+var authCredentials string
+
+// authType selects the HTTP authentication scheme: basic or digest.
+var authType = "basic"
+
+// applyAuth sets the Authorization header of req for the configured scheme.
+// Digest authentication can only answer once a challenge has been received.
+func applyAuth(req *http.Request) {
+	if authCredentials == "" {
+		return
+	}
+	user, pass, _ := strings.Cut(authCredentials, ":")
+	switch authType {
+	case "digest":
+		if header, ok := digest.authorization(req, user, pass); ok {
+			req.Header.Set("Authorization", header)
+		}
+	default:
+		req.SetBasicAuth(user, pass)
+	}
+}
+
+// needsDigestRetry reports whether resp is a digest challenge that the request
+// should be retried with, storing the challenge for subsequent requests.
+func needsDigestRetry(resp *http.Response) bool {
+	if authCredentials == "" || authType != "digest" || resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		if digest.update(challenge) {
+			return true
+		}
+	}
+	return false
+}
+
+// digestAuth keeps the last digest challenge so it can be answered without a
+// round trip on every request, counting the nonce uses as the RFC requires.
+type digestAuth struct {
+	mu     sync.Mutex
+	params map[string]string
+	count  int
+}
+
+var digest = &digestAuth{}
+
+func (d *digestAuth) update(challenge string) bool {
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return false
+	}
+
+	params := map[string]string{}
+	for _, part := range splitChallenge(rest) {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	if params["nonce"] == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.params = params
+	d.count = 0
+	return true
+}
+
+func (d *digestAuth) authorization(req *http.Request, user, pass string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.params == nil {
+		return "", false
+	}
+	d.count++
+
+	var newHash func() hash.Hash = md5.New
+	algorithm := d.params["algorithm"]
+	if strings.EqualFold(algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	digestOf := func(s string) string {
+		h := newHash()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	realm, nonce := d.params["realm"], d.params["nonce"]
+	uri := req.URL.RequestURI()
+	ha1 := digestOf(user + ":" + realm + ":" + pass)
+	ha2 := digestOf(req.Method + ":" + uri)
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, user, realm, nonce, uri)
+	if qop := d.params["qop"]; qop != "" {
+		nc := fmt.Sprintf("%08x", d.count)
+		cnonce := randomString(16)
+		response := digestOf(strings.Join([]string{ha1, nonce, nc, cnonce, "auth", ha2}, ":"))
+		header += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s", response="%s"`, nc, cnonce, response)
+	} else {
+		header += fmt.Sprintf(`, response="%s"`, digestOf(ha1+":"+nonce+":"+ha2))
+	}
+	if algorithm != "" {
+		header += ", algorithm=" + algorithm
+	}
+	if opaque := d.params["opaque"]; opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return header, true
+}
+
+// splitChallenge splits the challenge parameters on commas outside quotes.
+func splitChallenge(s string) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ',' && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// BaselineComparison is how a pair of baseline responses compare, reported
+// when they differ too much to scan: the indices of the two baselines in the
+// order they were requested, the similarity of their bodies and their status
+// codes and reflection counts.
+type BaselineComparison struct {
+	Baselines   [2]int  `json:"baselines"`
+	Similarity  float64 `json:"similarity"`
+	StatusCodes [2]int  `json:"status_codes"`
+	Reflections [2]int  `json:"reflections"`
+}
+
+// includeBaselineHeaders records a snapshot of the baseline response in the
+// results, as context for the findings.
+var includeBaselineHeaders bool
+
+// scanBaseline is the first baseline response of the current scan, recorded
+// as soon as the baselines are made so aborted scans have it too.
+var scanBaseline *ResponseData
+
+func recordBaseline(response ResponseData) {
+	scanBaseline = &response
+}
+
+// snapshotHeaders are the baseline headers recorded by includeBaselineHeaders.
+// They describe the application, unlike e.g. cookies which could hold secrets.
+var snapshotHeaders = []string{"Server", "Content-Type", "X-Powered-By", "X-AspNet-Version", "Via", "Cache-Control"}
+
+// BaselineSnapshot is the metadata of the baseline response: its status
+// code, the snapshotHeaders it has and the length of its body.
+type BaselineSnapshot struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	BodyLength int               `json:"body_length"`
+}
+
+func newBaselineSnapshot(response ResponseData) *BaselineSnapshot {
+	snapshot := &BaselineSnapshot{
+		StatusCode: response.StatusCode,
+		Headers:    map[string]string{},
+		BodyLength: len(response.Body),
+	}
+	for _, name := range snapshotHeaders {
+		if value := response.Headers.Get(name); value != "" {
+			snapshot.Headers[name] = value
+		}
+	}
+	return snapshot
+}
+
+// compareBaselines compares every pair of baseline responses.
+func compareBaselines(responses []ResponseData) []BaselineComparison {
+	comparisons := []BaselineComparison{}
+	for i := 0; i < len(responses); i++ {
+		for j := i + 1; j < len(responses); j++ {
+			comparisons = append(comparisons, BaselineComparison{
+				Baselines:   [2]int{i, j},
+				Similarity:  responseSimilarity(responses[i], responses[j]),
+				StatusCodes: [2]int{responses[i].StatusCode, responses[j].StatusCode},
+				Reflections: [2]int{responses[i].Reflections, responses[j].Reflections},
+			})
+		}
+	}
+	return comparisons
+}
+
+// inconsistentBaselinesReason summarizes how far apart the baselines are, so
+// the abort reason tells whether raising -baselines, lowering -similarity or
+// -force is worth trying.
+func inconsistentBaselinesReason(comparisons []BaselineComparison) string {
+	reason := inconsistentAbortReason
+	if len(comparisons) == 0 {
+		return reason
+	}
+	lowest := comparisons[0].Similarity
+	var statusCodes, reflections []int
+	for _, comparison := range comparisons {
+		lowest = min(lowest, comparison.Similarity)
+		for k := range 2 {
+			if !slices.Contains(statusCodes, comparison.StatusCodes[k]) {
+				statusCodes = append(statusCodes, comparison.StatusCodes[k])
+			}
+			if !slices.Contains(reflections, comparison.Reflections[k]) {
+				reflections = append(reflections, comparison.Reflections[k])
+			}
+		}
+	}
+
+	details := []string{fmt.Sprintf("lowest similarity %.3f, threshold %.3f", lowest, similarityThreshold)}
+	if len(statusCodes) > 1 {
+		slices.Sort(statusCodes)
+		details = append(details, "status codes "+joinInts(statusCodes))
+	}
+	if len(reflections) > 1 {
+		slices.Sort(reflections)
+		details = append(details, "reflections "+joinInts(reflections))
+	}
+	return reason + ": " + strings.Join(details, ", ")
+}
+
+func joinInts(values []int) string {
+	texts := make([]string, len(values))
+	for i, value := range values {
+		texts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(texts, "/")
+}
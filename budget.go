@@ -0,0 +1,52 @@
+package main
+
+import "sync/atomic"
+
+// maxRequests caps the requests sent during a scan, so a target on which every
+// chunk looks valid can't make the narrowing phase explode. A value of zero
+// means no limit.
+var maxRequests int
+
+// requestBudget counts the requests of a scan against maxRequests. Requests
+// are sent from many goroutines, so the count is kept atomically and the first
+// request over the budget marks it as exceeded for every other worker.
+type requestBudget struct {
+	spent    atomic.Int64
+	exceeded atomic.Bool
+}
+
+var budget = &requestBudget{}
+
+func (b *requestBudget) reset() {
+	b.spent.Store(0)
+	b.exceeded.Store(false)
+}
+
+// spend counts a request and reports whether it may be sent.
+func (b *requestBudget) spend() bool {
+	spent := b.spent.Add(1)
+	if maxRequests <= 0 || spent <= int64(maxRequests) {
+		return true
+	}
+	b.spent.Add(-1) // Not sent
+	if !b.exceeded.Swap(true) {
+		logger.Warn("Request budget exceeded, stopping the scan", "max_requests", maxRequests)
+	}
+	return false
+}
+
+// requests returns the requests counted since the scan started, retries
+// excluded.
+func (b *requestBudget) requests() int64 {
+	return b.spent.Load()
+}
+
+func (b *requestBudget) isExceeded() bool {
+	return b.exceeded.Load()
+}
+
+// scanStopped reports whether the scan should stop sending requests, either
+// because the target is blocking it or because the budget is spent.
+func scanStopped() bool {
+	return detector.isBlocked() || budget.isExceeded()
+}
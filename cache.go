@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheEnabled makes identical requests within a scan share one response.
+var cacheEnabled bool
+
+// cacheSize bounds the number of responses kept by the request cache.
+var cacheSize = 10000
+
+// requestCache deduplicates requests for the same set of parameters, both in
+// flight and completed. Parameter values are random canaries, so requests are
+// keyed by the parameter names rather than the exact URL.
+type requestCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	order   []string
+	hits    atomic.Int64
+}
+
+type cacheEntry struct {
+	ready    chan struct{}
+	response ResponseData
+}
+
+var responseCache = newRequestCache()
+
+func newRequestCache() *requestCache {
+	return &requestCache{entries: make(map[string]*cacheEntry)}
+}
+
+func cacheKey(request Request, params url.Values) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join([]string{request.Method, request.URL, request.Data, strings.Join(names, "&")}, "\x00")
+}
+
+// get returns the response cached for key, waiting for an in-flight request
+// with the same key, or calls fetch and caches its response.
+func (c *requestCache) get(key string, fetch func() ResponseData) ResponseData {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-entry.ready
+		c.hits.Add(1)
+		return entry.response
+	}
+
+	entry := &cacheEntry{ready: make(chan struct{})}
+	c.entries[key] = entry
+	c.order = append(c.order, key)
+	for len(c.order) > cacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.mu.Unlock()
+
+	entry.response = fetch()
+	close(entry.ready)
+	if entry.response.Err != nil {
+		// Let a later identical request try again
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	return entry.response
+}
@@ -0,0 +1,55 @@
+package main
+
+// defaultSimilarityThreshold is the body similarity above which a response is
+// considered unchanged when the threshold isn't calibrated.
+const defaultSimilarityThreshold = 0.9
+
+// similarityOverride, when positive, is used as the similarity threshold
+// instead of calibrating it.
+var similarityOverride float64
+
+// similarityThreshold is the threshold in use by the current scan.
+var similarityThreshold = defaultSimilarityThreshold
+
+// calibrationRequests is the number of requests with an unknown parameter
+// sent to measure how much the responses vary on their own.
+var calibrationRequests = 3
+
+// The calibrated threshold sits calibrationMargin below the lowest similarity
+// measured, kept within bounds so a single outlier can't disable detection.
+const (
+	calibrationMargin      = 0.05
+	minCalibratedThreshold = 0.5
+	maxCalibratedThreshold = 0.95
+)
+
+// calibrateSimilarity sends random, definitely nonexistent parameters and
+// derives the similarity threshold from how far their responses drift from
+// the closest baseline.
+func calibrateSimilarity(request Request, initialResponses InitialResponses) float64 {
+	lowest := 1.0
+	measured := 0
+	for i := 0; i < calibrationRequests; i++ {
+		response := makeRequest(request, generateParams([]string{"zz" + randomString(12)}))
+		if response.StatusCode == 0 {
+			continue
+		}
+		_, similarity := bestMatchingBaseline(initialResponses.Responses, response)
+		if similarity < lowest {
+			lowest = similarity
+		}
+		measured++
+	}
+	if measured == 0 {
+		return defaultSimilarityThreshold
+	}
+
+	threshold := lowest - calibrationMargin
+	if threshold < minCalibratedThreshold {
+		threshold = minCalibratedThreshold
+	} else if threshold > maxCalibratedThreshold {
+		threshold = maxCalibratedThreshold
+	}
+	logger.Info("Calibrated the similarity threshold", "threshold", threshold, "lowest_similarity", lowest)
+	return threshold
+}
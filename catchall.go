@@ -0,0 +1,40 @@
+package main
+
+// Catch-all determinations recorded in the report. An application that
+// reflects or reacts to a parameter it can't possibly know would make every
+// candidate look valid, so detection has to be adjusted for it.
+const (
+	catchAllNone     = "none"
+	catchAllReacts   = "reacts"
+	catchAllReflects = "reflects"
+)
+
+// catchAll is the determination for the current scan.
+var catchAll string
+
+// catchAllContentChange is the content change tolerated on applications that
+// reflect every parameter, when no -min-content-change is configured.
+const catchAllContentChange = 0.1
+
+// detectCatchAll sends a request with a random, definitely unknown parameter
+// and compares it with the baselines. When the application reflects the bogus
+// value, reflections stop being a signal and only the content outside of them
+// is compared. When it otherwise reacts to it, its response is added to the
+// baselines so the generic reaction to unknown parameters isn't reported.
+func detectCatchAll(request Request, initialResponses InitialResponses) (string, InitialResponses) {
+	bogus := "zz" + randomString(12)
+	response := makeRequest(request, generateParams([]string{bogus}))
+	if response.StatusCode == 0 {
+		return catchAllNone, initialResponses
+	}
+	if response.Reflections > 0 {
+		logger.Warn("The application reflects unknown parameters, reflections will be ignored when comparing responses", "parameter", bogus)
+		return catchAllReflects, initialResponses
+	}
+	if responseChanged(initialResponses.Responses, response, initialResponses.SameBody) {
+		logger.Warn("The application reacts to unknown parameters, its response to them will be treated as a baseline", "parameter", bogus, "status", response.StatusCode)
+		initialResponses.Responses = append(initialResponses.Responses, response)
+		return catchAllReacts, initialResponses
+	}
+	return catchAllNone, initialResponses
+}
@@ -0,0 +1,32 @@
+package main
+
+import "sync"
+
+// clusterTracker keeps the groups of parameters recursiveFilter stopped
+// bisecting at maxRecursionDepth. A cluster changed the response, so at least
+// one of its parameters is valid, but which ones needs manual review.
+type clusterTracker struct {
+	mu     sync.Mutex
+	groups [][]string
+}
+
+var clusters = &clusterTracker{}
+
+func (c *clusterTracker) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groups = nil
+}
+
+func (c *clusterTracker) add(params []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groups = append(c.groups, append([]string{}, params...))
+}
+
+// list returns the clusters in the order they were found.
+func (c *clusterTracker) list() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]string(nil), c.groups...)
+}
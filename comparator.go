@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+)
+
+// responseComparator decides whether a candidate response differs from the
+// baseline responses, which is what makes a parameter valid. sameBody is set
+// when all baselines are identical, so an exact comparison is possible. It's
+// called from concurrent workers and must be safe for concurrent use.
+type responseComparator func(baselines []ResponseData, candidate ResponseData, sameBody bool) bool
+
+// comparator is an app-specific comparison of candidate responses, e.g. to
+// diff JSON fields or look for a pattern. It supplements the built-in
+// comparison by status code, reflections and body similarity, or replaces it
+// with comparatorReplaces. Baseline stability and WAF detection always use the
+// built-in comparison. run sets it from -compare-command.
+var comparator responseComparator
+
+// comparatorReplaces makes comparator the only comparison of responses.
+var comparatorReplaces bool
+
+// compareCommand is a shell command comparing candidate responses, so users
+// can plug in their own comparison without rebuilding paramsmap.
+var compareCommand string
+
+// comparedResponse is a response as written to the compare command.
+type comparedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+}
+
+func newComparedResponse(response ResponseData) comparedResponse {
+	return comparedResponse{StatusCode: response.StatusCode, Headers: response.Headers, Body: string(response.Body)}
+}
+
+// commandComparator runs command for every candidate response, writing the
+// baselines and the candidate to its stdin as a JSON object. Exiting with 0
+// means the candidate changed and 1 that it didn't; any other failure is
+// logged and counts as unchanged.
+func commandComparator(command string) responseComparator {
+	return func(baselines []ResponseData, candidate ResponseData, sameBody bool) bool {
+		input := struct {
+			Baselines []comparedResponse `json:"baselines"`
+			Candidate comparedResponse   `json:"candidate"`
+			SameBody  bool               `json:"same_body"`
+		}{Candidate: newComparedResponse(candidate), SameBody: sameBody}
+		for _, baseline := range baselines {
+			input.Baselines = append(input.Baselines, newComparedResponse(baseline))
+		}
+		data, err := json.Marshal(input)
+		if err != nil {
+			logger.Warn("Failed to encode the responses for the compare command", "error", err)
+			return false
+		}
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(data)
+		err = cmd.Run()
+		if err == nil {
+			return true
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			logger.Warn("Compare command failed, counting the response as unchanged", "error", err)
+		}
+		return false
+	}
+}
+
+// changeCheck is a comparison of candidate responses besides the built-in
+// one, which it supplements or replaces.
+type changeCheck struct {
+	changed  responseComparator
+	replaces bool
+}
+
+// changeChecks returns the checks of the scan: the -match-string and
+// -filter-string markers, replacing the built-in comparison with -match-only,
+// and the comparator.
+func changeChecks() []changeCheck {
+	var checks []changeCheck
+	if usingMarkers() {
+		markers := func(baselines []ResponseData, candidate ResponseData, _ bool) bool {
+			return markersChanged(baselines, candidate)
+		}
+		checks = append(checks, changeCheck{changed: markers, replaces: matchOnly})
+	}
+	if comparator != nil {
+		checks = append(checks, changeCheck{changed: comparator, replaces: comparatorReplaces})
+	}
+	return checks
+}
+
+// compareResponse decides whether a candidate response is changed. When any
+// check replaces the built-in comparison, the replacing checks decide alone;
+// otherwise the response is changed when the built-in comparison or any check
+// flags it.
+func compareResponse(baselines []ResponseData, candidate ResponseData, sameBody bool) bool {
+	checks := changeChecks()
+	replaced := false
+	for _, check := range checks {
+		if check.replaces {
+			replaced = true
+			if check.changed(baselines, candidate, sameBody) {
+				return true
+			}
+		}
+	}
+	if replaced {
+		return false
+	}
+	if builtinChanged(baselines, candidate, sameBody) {
+		return true
+	}
+	for _, check := range checks {
+		if check.changed(baselines, candidate, sameBody) {
+			return true
+		}
+	}
+	return false
+}
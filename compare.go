@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Signals a candidate response is compared with the baselines on.
+const (
+	signalStatus      = "status"
+	signalLength      = "length"
+	signalReflections = "reflections"
+	signalHeaders     = "headers"
+	signalTiming      = "timing"
+	signalDelta       = "delta"
+)
+
+var compareSignals = []string{signalStatus, signalLength, signalReflections, signalHeaders, signalTiming, signalDelta}
+
+// compared are the signals set with -compare. Length stands for the body as a
+// whole: its length, similarity or content, depending on the scan. Timing
+// compares the latency with all the baselines at once, see latencyAnomaly.
+// Delta only compares the body length, see lengthDeltaMatches.
+var compared = map[string]bool{signalStatus: true, signalLength: true, signalReflections: true}
+
+// compareMode is how many baselines a candidate must match to be unchanged:
+// "any" baseline, which tolerates baselines that vary but can hide a change
+// that happens to look like one of them; a "majority" of them; or "all" of
+// them, which catches the most changes but also takes any baseline jitter
+// for one.
+var compareMode = "any"
+
+var compareModes = []string{"any", "majority", "all"}
+
+// unchangedMatches reports whether matching matched of total baselines makes
+// a candidate unchanged in the compareMode.
+func unchangedMatches(matched, total int) bool {
+	switch compareMode {
+	case "all":
+		return total > 0 && matched == total
+	case "majority":
+		return matched*2 > total
+	default:
+		return matched > 0
+	}
+}
+
+// setCompared parses the comma separated -compare list.
+func setCompared(list string) error {
+	signals := map[string]bool{}
+	for _, signal := range strings.Split(list, ",") {
+		if signal = strings.ToLower(strings.TrimSpace(signal)); signal == "" {
+			continue
+		}
+		if !slices.Contains(compareSignals, signal) {
+			return fmt.Errorf("unsupported signal %q, supported: %s", signal, strings.Join(compareSignals, ", "))
+		}
+		signals[signal] = true
+	}
+	if len(signals) == 0 {
+		return fmt.Errorf("at least one signal is required")
+	}
+	compared = signals
+	return nil
+}
+
+func statusMatches(a, b ResponseData) bool {
+	return !compared[signalStatus] || a.StatusCode == b.StatusCode
+}
+
+func reflectionsMatch(a, b ResponseData) bool {
+	return !compared[signalReflections] || a.Reflections == b.Reflections
+}
+
+// minLengthDelta is the body length difference, in bytes, from which the delta
+// signal counts a response as changed.
+var minLengthDelta = 1
+
+// lengthDeltaMatches reports whether the bodies differ in length by less than
+// minLengthDelta, whatever their content. Bodies aren't compared in degraded
+// mode, so neither are their lengths.
+func lengthDeltaMatches(a, b ResponseData) bool {
+	return !compared[signalDelta] || degradedMode || lengthDelta(a, b) < minLengthDelta
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig applies the options in a YAML or TOML file to the flag set. Keys
+// are flag names; flags set on the command line take precedence over the file,
+// which takes precedence over the flag defaults. Unknown keys are an error.
+func loadConfig(flags *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("unsupported config file format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if flags.Lookup(key) == nil || key == "config" {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		if explicit[key] {
+			continue
+		}
+
+		items, isList := values[key].([]any)
+		if !isList {
+			items = []any{values[key]}
+		}
+		for _, item := range items {
+			if err := flags.Set(key, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("invalid value for config key %q: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
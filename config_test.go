@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newConfigFlags() (*flag.FlagSet, *string, *int, *string) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	method := flags.String("method", "GET", "")
+	chunkSize := flags.Int("chunk-size", 1000, "")
+	contentType := flags.String("type", "form", "")
+	return flags, method, chunkSize, contentType
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "method: POST\nchunk-size: 50\n",
+		"config.toml": "method = \"POST\"\nchunk-size = 50\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		flags, method, chunkSize, contentType := newConfigFlags()
+		if err := flags.Parse([]string{"-chunk-size", "10"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadConfig(flags, path); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if *chunkSize != 10 {
+			t.Errorf("%s: expected the command line chunk-size 10 to win, got %d", name, *chunkSize)
+		}
+		if *method != "POST" {
+			t.Errorf("%s: expected the file method POST to override the default, got %s", name, *method)
+		}
+		if *contentType != "form" {
+			t.Errorf("%s: expected the default type form to be kept, got %s", name, *contentType)
+		}
+	}
+}
+
+func TestLoadConfigUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("methd: POST\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	flags, _, _, _ := newConfigFlags()
+	if err := loadConfig(flags, path); err == nil {
+		t.Errorf("Expected an error for the unknown key methd")
+	}
+}
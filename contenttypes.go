@@ -0,0 +1,50 @@
+package main
+
+import (
+	"mime"
+	"strings"
+)
+
+// scannedContentTypes are the media types of the baselines worth scanning.
+// Binary responses such as images or downloads can't be diffed meaningfully
+// and would only waste requests. An empty list scans any content type.
+var scannedContentTypes = []string{"text/html", "application/json", "text/plain", "application/xml"}
+
+// setContentTypes parses the comma separated -content-types list, replacing
+// the defaults.
+func setContentTypes(list string) error {
+	scannedContentTypes = nil
+	for _, contentType := range strings.Split(list, ",") {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			scannedContentTypes = append(scannedContentTypes, contentType)
+		}
+	}
+	return nil
+}
+
+// unsupportedContentType returns the media type of the baseline when it isn't
+// one of scannedContentTypes, or an empty string when it can be scanned.
+// Structured syntax suffixes count as their base type, so application/ld+json
+// is scanned as JSON. A baseline without a Content-Type is always scanned.
+func unsupportedContentType(baseline ResponseData) string {
+	header := baseline.Headers.Get("Content-Type")
+	if len(scannedContentTypes) == 0 || header == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return header
+	}
+	candidates := []string{mediaType}
+	if _, suffix, found := strings.Cut(mediaType, "+"); found {
+		candidates = append(candidates, "application/"+suffix)
+	}
+	for _, candidate := range candidates {
+		for _, allowed := range scannedContentTypes {
+			if candidate == allowed {
+				return ""
+			}
+		}
+	}
+	return mediaType
+}
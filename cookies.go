@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync/atomic"
+)
+
+// reuseCookies keeps the cookies the target sets while the baselines are
+// requested and sends them with every following request, for applications
+// that start a new session, and answer differently, for every cookieless
+// request.
+var reuseCookies bool
+
+// baselineJar stores cookies until it's frozen once the baselines are done,
+// so candidates setting or clearing cookies can't change the session every
+// other request is compared in.
+type baselineJar struct {
+	jar    *cookiejar.Jar
+	frozen atomic.Bool
+}
+
+// cookies is the jar of the shared client, nil when cookies aren't reused.
+var cookies *baselineJar
+
+func newBaselineJar() *baselineJar {
+	jar, _ := cookiejar.New(nil) // Never fails without options
+	return &baselineJar{jar: jar}
+}
+
+func (j *baselineJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if !j.frozen.Load() {
+		j.jar.SetCookies(u, cookies)
+	}
+}
+
+func (j *baselineJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// primeCookies sends a request to collect the cookies of a new session before
+// the baselines, so the baselines are all requested within that session.
+func primeCookies(request Request) {
+	if cookies == nil {
+		return
+	}
+	sendRequest(request, url.Values{})
+	if parsedURL, err := url.Parse(request.URL); err == nil {
+		logger.Info("Reusing cookies set by the target", "count", len(cookies.Cookies(parsedURL)))
+	}
+}
+
+// freezeCookies stops the jar from taking further cookies.
+func freezeCookies() {
+	if cookies != nil {
+		cookies.frozen.Store(true)
+	}
+}
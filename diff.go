@@ -1,34 +1,147 @@
 package main
 
-import "github.com/sergi/go-diff/diffmatchpatch"
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// minContentChange, when positive, requires a candidate to change the content
+// of the response by at least this fraction once reflected values are removed,
+// so parameters that only echo their value back aren't reported.
+var minContentChange float64
+
+// forceScan proceeds with scans whose baselines are inconsistent instead of
+// aborting them.
+var forceScan bool
+
+// degradedMode is set for forced scans of dynamic pages. Bodies are ignored and
+// only the status code and the reflections, which tolerate dynamic content,
+// are compared.
+var degradedMode bool
+
+// failedRequests counts the candidate responses of the scan that were skipped
+// because their request failed.
+var failedRequests atomic.Int64
 
 func responseChanged(baselineResponses []ResponseData, new ResponseData, equalCheck bool) bool {
+	if new.Err != nil {
+		// An error page would look like a change, the parameters are skipped instead
+		if new.Err != ErrBudgetExceeded {
+			failedRequests.Add(1)
+			logger.Debug("Skipping failed request instead of comparing it", "error", new.Err)
+		}
+		return false
+	}
+	if isIgnoredStatus(new) {
+		ignoredResponses.Add(1)
+		logger.Debug("Skipping response with an ignored status code", "status", new.StatusCode)
+		return false
+	}
+	return compareResponse(baselineResponses, new, equalCheck)
+}
+
+// builtinChanged compares the response with the baselines on the -compare
+// signals, by default status code, reflections and body similarity, and
+// reports it changed unless it matches as many baselines as -compare-mode
+// requires. With timing compared, a latency anomaly is a change too.
+func builtinChanged(baselineResponses []ResponseData, new ResponseData, equalCheck bool) bool {
+	matched := 0
 	for _, baseline := range baselineResponses {
-		if equalCheck && responsesAreEqual(baseline, new) {
-			return false
-		} else if !equalCheck && responsesAreSimilar(baseline, new) {
-			return false
+		if responseMatches(baseline, new, equalCheck) && headersMatch(baseline, new) && lengthDeltaMatches(baseline, new) {
+			matched++
+			if compareMode == "any" {
+				break
+			}
+		}
+	}
+	changed := !unchangedMatches(matched, len(baselineResponses)) || (compared[signalTiming] && latencyAnomaly(new))
+
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		index, similarity := bestMatchingBaseline(baselineResponses, new)
+		delta := 0
+		if index >= 0 {
+			delta = lengthDelta(baselineResponses[index], new)
 		}
+		logger.Debug("Compared candidate response against baselines", "changed", changed, "baseline", index, "similarity", similarity, "length_delta", delta)
 	}
-	return true // Response is different from all baselines; significant change detected
+	return changed
 }
 
-func responsesAreSimilar(a, b ResponseData) bool {
-	similarityThreshold := 0.9
-	similarity := 1.0
-	if len(a.Body) != len(b.Body) {
-		similarity = computeSimilarity(a.Body, b.Body)
+// responseMatches reports whether the candidate matches a baseline, comparing
+// what the scan's mode allows to be compared.
+func responseMatches(baseline ResponseData, new ResponseData, equalCheck bool) bool {
+	if degradedMode {
+		return statusMatches(baseline, new) && reflectionsMatch(baseline, new)
+	}
+	if threshold := contentChangeThreshold(); threshold > 0 {
+		return statusMatches(baseline, new) && (!compared[signalLength] || contentChange(baseline, new) < threshold)
 	}
+	if equalCheck {
+		return responsesAreEqual(baseline, new)
+	}
+	return responsesAreSimilar(baseline, new)
+}
+
+// contentChangeThreshold returns the content change a candidate needs to be
+// reported, or 0 when responses are compared as a whole.
+func contentChangeThreshold() float64 {
+	if minContentChange == 0 && catchAll == catchAllReflects {
+		return catchAllContentChange
+	}
+	return minContentChange
+}
 
-	return a.StatusCode == b.StatusCode &&
-		a.Reflections == b.Reflections &&
-		similarity >= similarityThreshold
+// bestMatchingBaseline returns the index of the baseline with the highest body
+// similarity to the candidate, together with that similarity.
+func bestMatchingBaseline(baselineResponses []ResponseData, new ResponseData) (int, float64) {
+	bestIndex, bestSimilarity := -1, -1.0
+	for i, baseline := range baselineResponses {
+		similarity := responseSimilarity(baseline, new)
+		if similarity > bestSimilarity {
+			bestIndex, bestSimilarity = i, similarity
+		}
+	}
+	return bestIndex, bestSimilarity
+}
+
+func responsesAreSimilar(a, b ResponseData) bool {
+	return statusMatches(a, b) &&
+		reflectionsMatch(a, b) &&
+		(!compared[signalLength] || responseSimilarity(a, b) >= similarityThreshold)
+}
+
+// responseSimilarity returns the body similarity of two responses, skipping the
+// diff when both bodies have the same length. With the dom algorithm it's the
+// similarity of their element structure instead.
+func responseSimilarity(a, b ResponseData) float64 {
+	if diffAlgo == "dom" {
+		aSignature, bSignature := domSignature(a.Body), domSignature(b.Body)
+		if aSignature == bSignature {
+			return 1.0
+		}
+		return computeSimilarity([]byte(aSignature), []byte(bSignature))
+	}
+	if len(a.Body) == len(b.Body) {
+		return 1.0
+	}
+	return computeSimilarity(a.Body, b.Body)
 }
 
 func responsesAreEqual(a, b ResponseData) bool {
-	return a.StatusCode == b.StatusCode &&
-		a.Reflections == b.Reflections &&
-		len(a.Body) == len(b.Body)
+	if !statusMatches(a, b) || !reflectionsMatch(a, b) {
+		return false
+	}
+	if !compared[signalLength] {
+		return true
+	}
+	if diffAlgo == "dom" {
+		return domSignature(a.Body) == domSignature(b.Body)
+	}
+	return len(a.Body) == len(b.Body) && a.hash() == b.hash()
 }
 
 func baselineResponsesAreConsistent(baselineResponses []ResponseData, compareFunc func(ResponseData, ResponseData) bool) bool {
@@ -42,7 +155,68 @@ func baselineResponsesAreConsistent(baselineResponses []ResponseData, compareFun
 	return true
 }
 
+// stableBaselines returns the largest group of baseline responses that are all
+// comparable to the same modal response, dropping outliers such as a transient
+// error. It returns nil when no strict majority of the baselines agree.
+func stableBaselines(baselineResponses []ResponseData, compareFunc func(ResponseData, ResponseData) bool) []ResponseData {
+	var best []ResponseData
+	for i := range baselineResponses {
+		var group []ResponseData
+		for j := range baselineResponses {
+			if i == j || compareFunc(baselineResponses[i], baselineResponses[j]) {
+				group = append(group, baselineResponses[j])
+			}
+		}
+		if len(group) > len(best) {
+			best = group
+		}
+	}
+
+	if len(best)*2 <= len(baselineResponses) {
+		return nil
+	}
+	return best
+}
+
+// lengthDelta returns the absolute difference between the body lengths.
+func lengthDelta(a, b ResponseData) int {
+	delta := len(a.Body) - len(b.Body)
+	if delta < 0 {
+		return -delta
+	}
+	return delta
+}
+
+// contentChange returns how much the content of b differs from a, between 0
+// and 1, after removing the values reflected in either response. A response
+// that only echoes a parameter value back has no content change even though
+// its length differs.
+func contentChange(a, b ResponseData) float64 {
+	aBody, bBody := stripReflections(a), stripReflections(b)
+	if bytes.Equal(aBody, bBody) {
+		return 0
+	}
+	return 1 - computeSimilarity(aBody, bBody)
+}
+
+func stripReflections(response ResponseData) []byte {
+	body := response.Body
+	for _, value := range response.ReflectedValues {
+		body = bytes.ReplaceAll(body, []byte(value), nil)
+	}
+	return body
+}
+
+// maxDiffBytes caps the body size computeSimilarity runs a full diff on. The
+// diff's cost grows with the product of the body lengths, so larger bodies
+// are compared by their common prefix and suffix instead. A value of zero
+// means no limit.
+var maxDiffBytes int
+
 func computeSimilarity(aBody, bBody []byte) float64 {
+	if maxDiffBytes > 0 && (len(aBody) > maxDiffBytes || len(bBody) > maxDiffBytes) {
+		return affixSimilarity(aBody, bBody)
+	}
 	aText := string(aBody)
 	bText := string(bBody)
 
@@ -65,3 +239,26 @@ func computeSimilarity(aBody, bBody []byte) float64 {
 	similarity := 1 - float64(distance)/float64(maxLen)
 	return similarity
 }
+
+// affixSimilarity estimates the similarity of two bodies in linear time as the
+// share of the longer body covered by their common prefix and suffix, which
+// matches the diff for a single insertion, deletion or replacement.
+func affixSimilarity(aBody, bBody []byte) float64 {
+	maxLen, minLen := len(aBody), len(bBody)
+	if minLen > maxLen {
+		maxLen, minLen = minLen, maxLen
+	}
+	if maxLen == 0 {
+		return 1.0
+	}
+
+	prefix := 0
+	for prefix < minLen && aBody[prefix] == bBody[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < minLen-prefix && aBody[len(aBody)-1-suffix] == bBody[len(bBody)-1-suffix] {
+		suffix++
+	}
+	return float64(prefix+suffix) / float64(maxLen)
+}
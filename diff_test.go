@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestResponseChangedMinContentChange(t *testing.T) {
+	defer func(previous float64) { minContentChange = previous }(minContentChange)
+
+	baselines := []ResponseData{
+		{Body: []byte("<html><body><h1>Search</h1><p>You searched for: </p></body></html>"), StatusCode: 200},
+	}
+	echoed := ResponseData{
+		Body:            []byte("<html><body><h1>Search</h1><p>You searched for: qWeRtYuI</p></body></html>"),
+		StatusCode:      200,
+		Reflections:     1,
+		ReflectedValues: []string{"qWeRtYuI"},
+	}
+	altered := ResponseData{
+		Body:       []byte("<html><body><h1>Admin</h1><table><tr><td>users</td><td>42</td></tr></table></body></html>"),
+		StatusCode: 200,
+	}
+
+	if lengthDelta(baselines[0], echoed) != len("qWeRtYuI") {
+		t.Errorf("Expected the length delta to be the length of the echoed value, got %d", lengthDelta(baselines[0], echoed))
+	}
+
+	minContentChange = 0
+	if !responseChanged(baselines, echoed, false) {
+		t.Errorf("Expected the echoed response to be reported as changed by default")
+	}
+	if !responseChanged(baselines, altered, false) {
+		t.Errorf("Expected the altered response to be reported as changed by default")
+	}
+
+	minContentChange = 0.05
+	if responseChanged(baselines, echoed, false) {
+		t.Errorf("Expected a length-only echo not to be reported when a minimum content change is required")
+	}
+	if !responseChanged(baselines, altered, false) {
+		t.Errorf("Expected a content change to be reported when a minimum content change is required")
+	}
+}
+
+func TestResponseChangedLengthDelta(t *testing.T) {
+	defer func(previous map[string]bool) { compared = previous }(compared)
+	defer func(previous int) { minLengthDelta = previous }(minLengthDelta)
+	defer func(previous float64) { minContentChange = previous }(minContentChange)
+
+	baselines := []ResponseData{
+		{Body: []byte("<html><body><p>role: guest</p><p>You searched for: </p></body></html>"), StatusCode: 200},
+	}
+	// Same content apart from a long echoed value
+	echoed := ResponseData{
+		Body:            []byte("<html><body><p>role: guest</p><p>You searched for: qWeRtYuIoPaSdFgHjKlZxCvBnM</p></body></html>"),
+		StatusCode:      200,
+		Reflections:     1,
+		ReflectedValues: []string{"qWeRtYuIoPaSdFgHjKlZxCvBnM"},
+	}
+	// Different content of the same length
+	escalated := ResponseData{
+		Body:       []byte("<html><body><p>role: admin</p><p>You searched for: </p></body></html>"),
+		StatusCode: 200,
+	}
+
+	if err := setCompared("status,delta"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	minLengthDelta = 20
+	if !responseChanged(baselines, echoed, false) {
+		t.Errorf("Expected a length delta of %d bytes to be a change from %d", lengthDelta(baselines[0], echoed), minLengthDelta)
+	}
+	if responseChanged(baselines, escalated, false) {
+		t.Errorf("Expected a content change of the same length not to change the length delta")
+	}
+	minLengthDelta = 50
+	if responseChanged(baselines, echoed, false) {
+		t.Errorf("Expected a length delta of %d bytes not to be a change from %d", lengthDelta(baselines[0], echoed), minLengthDelta)
+	}
+
+	if err := setCompared("status,length,reflections"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	minContentChange = 0.05
+	if responseChanged(baselines, echoed, false) {
+		t.Errorf("Expected a minimum content change to ignore the length of the echoed value")
+	}
+	if !responseChanged(baselines, escalated, false) {
+		t.Errorf("Expected a minimum content change to catch a content change of the same length")
+	}
+}
+
+func TestResponseChangedIgnoresVolatileHeaders(t *testing.T) {
+	defer func(enabled bool, ignored []string) { diffHeaders, ignoredHeaders = enabled, ignored }(diffHeaders, ignoredHeaders)
+	diffHeaders = true
+
+	body := []byte("<html><body><h1>Normal</h1></body></html>")
+	baselines := []ResponseData{
+		{Body: body, StatusCode: 200, Headers: http.Header{"Date": {"Mon, 01 Jan 2024 12:00:00 GMT"}, "Content-Type": {"text/html"}}},
+	}
+	dated := ResponseData{Body: body, StatusCode: 200, Headers: http.Header{"Date": {"Mon, 01 Jan 2024 12:00:07 GMT"}, "Content-Type": {"text/html"}}}
+	debug := ResponseData{Body: body, StatusCode: 200, Headers: http.Header{"Date": {"Mon, 01 Jan 2024 12:00:00 GMT"}, "Content-Type": {"text/html"}, "X-Debug": {"1"}}}
+	traced := ResponseData{Body: body, StatusCode: 200, Headers: http.Header{"Date": {"Mon, 01 Jan 2024 12:00:00 GMT"}, "Content-Type": {"text/html"}, "X-Trace": {"abc"}}}
+
+	if responseChanged(baselines, dated, true) {
+		t.Errorf("Expected a Date-only difference not to be reported as a change")
+	}
+	if !responseChanged(baselines, debug, true) {
+		t.Errorf("Expected a new header to be reported as a change")
+	}
+
+	addIgnoredHeaders("x-trace, ")
+	if responseChanged(baselines, traced, true) {
+		t.Errorf("Expected a header added to the ignore list not to be reported as a change")
+	}
+}
+
+func TestCompareSignals(t *testing.T) {
+	defer func(previous map[string]bool) { compared = previous }(compared)
+	defer func(previous time.Duration) { latencyThreshold = previous }(latencyThreshold)
+
+	baseline := ResponseData{Body: []byte("<html><body><h1>Items</h1><p>3 results</p></body></html>"), StatusCode: 200, Elapsed: 100 * time.Millisecond}
+	reordered := ResponseData{Body: []byte("<html><body><h1>Items</h1><table><tr><td>3</td></tr></table></body></html>"), StatusCode: 200, Elapsed: 100 * time.Millisecond}
+	forbidden := ResponseData{Body: baseline.Body, StatusCode: 403, Elapsed: 100 * time.Millisecond}
+	slow := ResponseData{Body: baseline.Body, StatusCode: 200, Elapsed: 5 * time.Second}
+	echoed := ResponseData{Body: baseline.Body, StatusCode: 200, Reflections: 1, Elapsed: 100 * time.Millisecond}
+	latencyThreshold = newLatencyThreshold([]ResponseData{baseline})
+
+	for _, test := range []struct {
+		signals string
+		changed []bool // reordered, forbidden, slow, echoed
+	}{
+		{"status,length,reflections", []bool{true, true, false, true}},
+		{"status", []bool{false, true, false, false}},
+		{"length, reflections", []bool{true, false, false, true}},
+		{"status,timing", []bool{false, true, true, false}},
+	} {
+		if err := setCompared(test.signals); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.signals, err)
+		}
+		for i, candidate := range []ResponseData{reordered, forbidden, slow, echoed} {
+			if changed := responseChanged([]ResponseData{baseline}, candidate, false); changed != test.changed[i] {
+				t.Errorf("-compare %s: expected candidate %d changed=%v, got %v", test.signals, i, test.changed[i], changed)
+			}
+		}
+	}
+
+	for _, list := range []string{"", "status,size"} {
+		if err := setCompared(list); err == nil {
+			t.Errorf("Expected -compare %q to be rejected", list)
+		}
+	}
+}
+
+func TestCompareMode(t *testing.T) {
+	defer func(previous string) { compareMode = previous }(compareMode)
+
+	body := []byte("<html><body><h1>Items</h1><p>3 results</p></body></html>")
+	// Baselines varying between a page and a transient error
+	baselines := []ResponseData{{Body: body, StatusCode: 200}, {Body: body, StatusCode: 200}, {Body: body, StatusCode: 404}}
+	candidates := []ResponseData{{Body: body, StatusCode: 200}, {Body: body, StatusCode: 404}, {Body: body, StatusCode: 500}}
+
+	for _, test := range []struct {
+		mode    string
+		changed []bool // matching 2, 1 and 0 of the 3 baselines
+	}{
+		{"any", []bool{false, false, true}},
+		{"majority", []bool{false, true, true}},
+		{"all", []bool{true, true, true}},
+	} {
+		compareMode = test.mode
+		for i, candidate := range candidates {
+			if changed := responseChanged(baselines, candidate, true); changed != test.changed[i] {
+				t.Errorf("-compare-mode %s: expected the candidate matching %d baselines changed=%v, got %v", test.mode, 2-i, test.changed[i], changed)
+			}
+		}
+		if responseChanged(baselines[:2], candidates[0], true) {
+			t.Errorf("-compare-mode %s: expected a candidate matching every baseline to be unchanged", test.mode)
+		}
+		if !responseChanged(nil, candidates[0], true) {
+			t.Errorf("-compare-mode %s: expected a candidate without baselines to be changed", test.mode)
+		}
+	}
+}
+
+func TestResponsesAreEqualComparesContent(t *testing.T) {
+	a := ResponseData{Body: []byte("<html><body><p>role: guest</p></body></html>"), StatusCode: 200}
+	b := ResponseData{Body: []byte("<html><body><p>role: admin</p></body></html>"), StatusCode: 200}
+	if len(a.Body) != len(b.Body) {
+		t.Fatalf("Expected bodies of equal length")
+	}
+	if responsesAreEqual(a, b) {
+		t.Errorf("Expected bodies of equal length but different content to differ")
+	}
+	if !responsesAreEqual(a, ResponseData{Body: []byte(string(a.Body)), StatusCode: 200}) {
+		t.Errorf("Expected identical bodies to be equal")
+	}
+
+	// With the hashes computed when the responses were read
+	a.BodyHash, b.BodyHash = sha256.Sum256(a.Body), sha256.Sum256(b.Body)
+	if responsesAreEqual(a, b) {
+		t.Errorf("Expected the stored hashes of different bodies to differ")
+	}
+	if responseChanged([]ResponseData{a}, a, true) || !responseChanged([]ResponseData{a}, b, true) {
+		t.Errorf("Expected the equal check against a same-body baseline to compare content")
+	}
+}
+
+func TestInconsistentBaselinesReason(t *testing.T) {
+	defer func(previous float64) { similarityThreshold = previous }(similarityThreshold)
+	similarityThreshold = 0.95
+
+	baselines := []ResponseData{
+		{Body: []byte("<html><body><p>Welcome back</p></body></html>"), StatusCode: 200},
+		{Body: []byte("<html><body><p>Welcome back</p></body></html>"), StatusCode: 200},
+		{Body: []byte("<html><body><h1>Service Unavailable</h1></body></html>"), StatusCode: 503, Reflections: 1},
+	}
+	comparisons := compareBaselines(baselines)
+	if len(comparisons) != 3 {
+		t.Fatalf("Expected every pair of baselines to be compared, got: %+v", comparisons)
+	}
+	if first := comparisons[0]; first.Baselines != [2]int{0, 1} || first.Similarity != 1 || first.StatusCodes != [2]int{200, 200} {
+		t.Errorf("Expected the identical baselines to compare equal, got: %+v", first)
+	}
+	if last := comparisons[2]; last.Baselines != [2]int{1, 2} || last.Similarity >= 0.95 || last.StatusCodes != [2]int{200, 503} || last.Reflections != [2]int{0, 1} {
+		t.Errorf("Expected the outlier to be reported as different, got: %+v", last)
+	}
+
+	reason := inconsistentBaselinesReason(comparisons)
+	expected := fmt.Sprintf("Baseline responses differ significantly: lowest similarity %.3f, threshold 0.950, status codes 200/503, reflections 0/1", comparisons[1].Similarity)
+	if reason != expected {
+		t.Errorf("Expected %q, got %q", expected, reason)
+	}
+}
+
+func TestDomSignatureIgnoresText(t *testing.T) {
+	a := domSignature([]byte(`<html><body><h1>Time</h1><div class="now">10:00</div></body></html>`))
+	b := domSignature([]byte(`<html><body><h1>Time</h1><div class="later">11:30:42</div></body></html>`))
+	c := domSignature([]byte(`<html><body><h2>Secret</h2></body></html>`))
+
+	if a != b {
+		t.Errorf("Expected text and attribute values to be ignored, got %q and %q", a, b)
+	}
+	if a == c {
+		t.Errorf("Expected a structural change to change the signature")
+	}
+}
+
+func TestDiscoverParamsCustomComparator(t *testing.T) {
+	startMockServer()
+	defer func(previous responseComparator, replaces bool) {
+		comparator, comparatorReplaces = previous, replaces
+	}(comparator, comparatorReplaces)
+
+	// Only a session value leaking into the page counts as a change
+	comparator = func(baselines []ResponseData, candidate ResponseData, sameBody bool) bool {
+		return bytes.Contains(candidate.Body, []byte("abc123"))
+	}
+	comparatorReplaces = true
+
+	request := Request{
+		URL:    "http://localhost:8181",
+		Method: "GET",
+	}
+	results, err := DiscoverParams(request, []string{"page", "param1", "session", "user", "random1"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "session" {
+		t.Errorf("Expected only the parameter matched by the custom comparator, got: %v", results.Params)
+	}
+}
+
+func TestRunCompareCommand(t *testing.T) {
+	startMockServer()
+	defer func(previous responseComparator, replaces bool, output io.Writer) {
+		comparator, comparatorReplaces, summaryOutput = previous, replaces, output
+	}(comparator, comparatorReplaces, summaryOutput)
+	summaryOutput = io.Discard
+
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "params.txt")
+	if err := os.WriteFile(wordlist, []byte("page\nparam1\nsession\nuser\nrandom1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "report.json")
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		// Only a session value leaking into the page counts as a change
+		{"grep -q abc123", []string{"session"}},
+		// A failing command counts every response as unchanged
+		{"exit 2", nil},
+	}
+	for _, test := range tests {
+		args := []string{"-quiet", "-url", "http://localhost:8181", "-wordlist", wordlist, "-report", report, "-chunk-size", "1", "-compare-command", test.command, "-compare-command-only"}
+		if code := run(args); code != exitOK {
+			t.Fatalf("%s: expected exit code %d, got %d", test.command, exitOK, code)
+		}
+		results, err := loadReport(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results.Params) != len(test.expected) || (len(test.expected) > 0 && !reflect.DeepEqual(results.Params, test.expected)) {
+			t.Errorf("%s: expected %v, got: %v", test.command, test.expected, results.Params)
+		}
+	}
+
+	if code := run([]string{"-quiet", "-url", "http://localhost:8181", "-wordlist", wordlist, "-report", "", "-compare-command-only"}); code != exitError {
+		t.Errorf("Expected -compare-command-only without a command to be rejected, got exit code %d", code)
+	}
+}
+
+func TestDiscoverParamsComparatorSupplements(t *testing.T) {
+	startMockServer()
+	defer func(previous responseComparator, replaces bool) {
+		comparator, comparatorReplaces = previous, replaces
+	}(comparator, comparatorReplaces)
+
+	// The role field changing is interesting however small the change to the body
+	comparator = func(baselines []ResponseData, candidate ResponseData, sameBody bool) bool {
+		for _, baseline := range baselines {
+			var before, after struct{ Role string }
+			if json.Unmarshal(baseline.Body, &before) != nil || json.Unmarshal(candidate.Body, &after) != nil || before.Role == after.Role {
+				return false
+			}
+		}
+		return len(baselines) > 0
+	}
+
+	request := Request{
+		URL:    "http://localhost:8181/account",
+		Method: "GET",
+	}
+	params := []string{"page", "as", "verbose", "random1"}
+	for replaces, expected := range map[bool][]string{false: {"as", "verbose"}, true: {"as"}} {
+		comparatorReplaces = replaces
+		results, err := DiscoverParams(request, params, 1)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		sort.Strings(results.Params)
+		if !reflect.DeepEqual(results.Params, expected) {
+			t.Errorf("Expected %v with the comparator replacing=%v, got: %v", expected, replaces, results.Params)
+		}
+	}
+
+	// The markers replacing the comparison take precedence over a supplementing comparator
+	defer func(match string, only bool) { matchString, matchOnly = match, only }(matchString, matchOnly)
+	comparatorReplaces = false
+	matchString, matchOnly = `"debug":true`, true
+	results, err := DiscoverParams(request, params, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 0 {
+		t.Errorf("Expected only the replacing markers to decide, got: %v", results.Params)
+	}
+
+	comparator, matchString, matchOnly = nil, "", false
+	results, err = DiscoverParams(request, params, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "verbose" {
+		t.Errorf("Expected the role change to be missed by the built-in comparison, got: %v", results.Params)
+	}
+}
+
+func TestComputeSimilarityMaxDiffBytes(t *testing.T) {
+	defer func(previous int) { maxDiffBytes = previous }(maxDiffBytes)
+
+	a := bytes.Repeat([]byte("<p>Lorem ipsum dolor sit amet</p>"), 100)
+	b := append(append(append([]byte{}, a[:1000]...), []byte("<div>Debug output</div>")...), a[1000:]...)
+
+	maxDiffBytes = 0
+	diffed := computeSimilarity(a, b)
+	maxDiffBytes = 1024
+	estimated := computeSimilarity(a, b)
+	if estimated != affixSimilarity(a, b) || estimated < diffed-0.01 || estimated > diffed+0.01 {
+		t.Errorf("Expected the heuristic to be used and to match the diff for an insertion, got %v and %v", estimated, diffed)
+	}
+	if affixSimilarity(a, bytes.Repeat([]byte("x"), len(a))) != 0 {
+		t.Errorf("Expected unrelated bodies to have no similarity")
+	}
+}
+
+func BenchmarkComputeSimilarity(b *testing.B) {
+	defer func(previous int) { maxDiffBytes = previous }(maxDiffBytes)
+	for _, name := range []string{"small", "large"} {
+		a, c := benchmarkPage(benchmarkBodies[name], "Normal"), benchmarkPage(benchmarkBodies[name], "Changed")
+		for _, limit := range []int{0, 4 << 10} {
+			b.Run(fmt.Sprintf("%s/max-diff-bytes=%d", name, limit), func(b *testing.B) {
+				maxDiffBytes = limit
+				for i := 0; i < b.N; i++ {
+					computeSimilarity(a, c)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// diffAlgo selects how response bodies are compared: "bytes" compares the raw
+// bodies, while "dom" only compares their element structure, which ignores
+// reordered or dynamic text on content heavy pages.
+var diffAlgo = "bytes"
+
+// domSignature reduces an HTML body to its element structure: one entry per
+// element in document order with its depth, tag name and sorted attribute
+// names. Text and attribute values are left out.
+func domSignature(body []byte) string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return string(body)
+	}
+
+	var signature strings.Builder
+	doc.Find("*").Each(func(_ int, selection *goquery.Selection) {
+		node := selection.Get(0)
+		attributes := make([]string, 0, len(node.Attr))
+		for _, attribute := range node.Attr {
+			attributes = append(attributes, attribute.Key)
+		}
+		sort.Strings(attributes)
+
+		signature.WriteString(strconv.Itoa(selection.Parents().Length()))
+		signature.WriteString(node.Data)
+		if len(attributes) > 0 {
+			signature.WriteString("[" + strings.Join(attributes, ",") + "]")
+		}
+		signature.WriteString(" ")
+	})
+	return signature.String()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Drift lists how the discovered parameters changed since a previous report,
+// making periodic scans of a target usable to detect API surface changes.
+type Drift struct {
+	BaselineReport string   `json:"baseline_report"`
+	NewParams      []string `json:"new_params"`
+	RemovedParams  []string `json:"removed_params"`
+}
+
+// loadReport reads the results of a previous scan from a JSON report.
+func loadReport(path string) (Results, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Results{}, fmt.Errorf("reading report: %w", err)
+	}
+	if err := validateReport(data); err != nil {
+		return Results{}, fmt.Errorf("%s: %w", path, err)
+	}
+	var results Results
+	if err := json.Unmarshal(data, &results); err != nil {
+		return Results{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+// newDrift compares the parameters of the current results with the baseline
+// ones, keeping the order in which each side reported them.
+func newDrift(baselinePath string, baseline Results, current Results) *Drift {
+	return &Drift{
+		BaselineReport: baselinePath,
+		NewParams:      paramsMissingFrom(current.Params, baseline.Params),
+		RemovedParams:  paramsMissingFrom(baseline.Params, current.Params),
+	}
+}
+
+// paramsMissingFrom returns the params that aren't in other.
+func paramsMissingFrom(params []string, other []string) []string {
+	known := make(map[string]bool, len(other))
+	for _, param := range other {
+		known[param] = true
+	}
+	missing := []string{}
+	for _, param := range params {
+		if !known[param] {
+			missing = append(missing, param)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Duplicate parameter behaviours recorded in the report: which occurrence of
+// a repeated query parameter the application honours.
+const (
+	duplicateFirstWins = "first"
+	duplicateLastWins  = "last"
+	duplicateAll       = "all"
+	duplicateUnknown   = "unknown"
+)
+
+// duplicateParams is the behaviour determined for the current scan, empty
+// when the URL has no query parameters a candidate could collide with.
+var duplicateParams string
+
+// replaceExisting makes candidates named like a query parameter of the URL
+// replace its value instead of being sent along with it.
+var replaceExisting bool
+
+// How candidates colliding with a query parameter of the URL are sent,
+// recorded in the report.
+const (
+	collisionsAppend  = "append"
+	collisionsPrepend = "prepend"
+	collisionsReplace = "replace"
+)
+
+// queryCollisions returns how the current scan sends colliding candidates.
+func queryCollisions() string {
+	if replaceExisting {
+		return collisionsReplace
+	}
+	if duplicateParams == duplicateFirstWins {
+		return collisionsPrepend
+	}
+	return collisionsAppend
+}
+
+// hasQueryCollisions reports whether candidates can collide with query
+// parameters of the URL, which is when they are injected into a query string
+// that already has some.
+func hasQueryCollisions(request Request) bool {
+	if injectionPoint(request) != "query" {
+		return false
+	}
+	parsedURL, err := url.Parse(request.URL)
+	return err == nil && parsedURL.RawQuery != ""
+}
+
+// maxDuplicateProbes bounds the query parameters of the URL probed while
+// looking for one that is reflected.
+const maxDuplicateProbes = 3
+
+// detectDuplicateParams sends the query parameters of the URL twice, x=a&x=b,
+// and looks at which value is reflected. Candidates are appended after the
+// parameters already in the URL, so on a first-wins application a candidate
+// named like one of them would never be seen and has to go first instead.
+func detectDuplicateParams(request Request) string {
+	if !hasQueryCollisions(request) {
+		return ""
+	}
+	parsedURL, _ := url.Parse(request.URL)
+	query := parsedURL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > maxDuplicateProbes {
+		keys = keys[:maxDuplicateProbes]
+	}
+
+	for _, key := range keys {
+		probeQuery := parsedURL.Query()
+		probeQuery.Del(key)
+		probeURL := *parsedURL
+		probeURL.RawQuery = probeQuery.Encode()
+		probe := request
+		probe.URL = probeURL.String()
+
+		first, last := "dup"+randomString(10), "dup"+randomString(10)
+		response := makeRequest(probe, url.Values{key: {first, last}})
+		if response.Err != nil {
+			continue
+		}
+		body := string(response.Body)
+		hasFirst, hasLast := strings.Contains(body, first), strings.Contains(body, last)
+		switch {
+		case hasFirst && hasLast:
+			return duplicateAll
+		case hasFirst:
+			logger.Info("The application honours the first of repeated parameters, candidates colliding with the URL's go first", "parameter", key)
+			return duplicateFirstWins
+		case hasLast:
+			return duplicateLastWins
+		}
+	}
+	return duplicateUnknown
+}
+
+// addQueryParams adds params to the query. The values of a parameter already
+// in it replace the existing ones with -replace-existing, and otherwise go
+// before them when the application only honours the first occurrence.
+// Baselines carry no candidates, so they are sent with the URL's query as is
+// whatever the mode.
+func addQueryParams(query url.Values, params url.Values) {
+	mode := queryCollisions()
+	for key, values := range params {
+		if !query.Has(key) {
+			query[key] = append([]string{}, values...)
+			continue
+		}
+		switch mode {
+		case collisionsReplace:
+			query[key] = append([]string{}, values...)
+		case collisionsPrepend:
+			query[key] = append(append([]string{}, values...), query[key]...)
+		default:
+			query[key] = append(query[key], values...)
+		}
+	}
+}
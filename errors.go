@@ -0,0 +1,55 @@
+package main
+
+import "errors"
+
+// Errors of the conditions a scan fails or is aborted on, for library callers
+// to branch on with errors.Is. DiscoverParams returns ErrTargetUnreachable,
+// the others are returned by Results.Err for aborted scans. ErrBudgetExceeded
+// is also the error of the requests refused by the budget.
+var (
+	ErrTargetUnreachable      = errors.New("target unreachable")
+	ErrInconsistentBaselines  = errors.New("baseline responses differ significantly")
+	ErrUnsupportedContentType = errors.New(contentTypeAbortReason)
+	ErrBlocked                = errors.New(wafAbortReason)
+	ErrBudgetExceeded         = errors.New(budgetAbortReason)
+)
+
+// Abort reasons, the first words of Results.AbortReason.
+const (
+	inconsistentAbortReason = "Baseline responses differ significantly"
+	contentTypeAbortReason  = "unsupported content type"
+	wafAbortReason          = "possible WAF block"
+	budgetAbortReason       = "request budget exceeded"
+)
+
+// AbortError is the error of an aborted scan: the reason it was aborted for,
+// with the details of Results.AbortReason, and the error of its condition.
+type AbortError struct {
+	Reason string
+	Err    error
+}
+
+func (e *AbortError) Error() string {
+	return "scan aborted: " + e.Reason
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Err
+}
+
+// Err returns an *AbortError wrapping the error of the condition the scan was
+// aborted on, or nil when the scan completed. The condition isn't part of a
+// report, results loaded from one only have the AbortReason.
+func (r Results) Err() error {
+	if !r.Aborted {
+		return nil
+	}
+	return &AbortError{Reason: r.AbortReason, Err: r.abortErr}
+}
+
+// abort marks the scan as aborted on condition, for reason.
+func (r *Results) abort(condition error, reason string) {
+	r.Aborted = true
+	r.AbortReason = reason
+	r.abortErr = condition
+}
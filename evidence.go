@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// evidenceDir is the directory the request and response proving each
+// discovered parameter are written to. Empty disables evidence collection.
+var evidenceDir string
+
+// evidenceMaxBytes bounds the total size of the evidence written for a scan.
+var evidenceMaxBytes int64 = 10 << 20
+
+// evidenceCollector keeps, for every parameter, a response that changed when
+// the parameter was sent on its own (or was reflected in a larger request),
+// and the file the response was written to.
+type evidenceCollector struct {
+	mu        sync.Mutex
+	responses map[string]ResponseData
+	paths     map[string]string
+}
+
+var evidence = &evidenceCollector{responses: make(map[string]ResponseData), paths: make(map[string]string)}
+
+func (e *evidenceCollector) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.responses = make(map[string]ResponseData)
+	e.paths = make(map[string]string)
+}
+
+// pathOf returns the file the response proving param was written to, empty
+// when none was.
+func (e *evidenceCollector) pathOf(param string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paths[param]
+}
+
+// record stores response as the evidence for params when it changed and can
+// be attributed to a single parameter.
+func (e *evidenceCollector) record(params []string, response ResponseData, changed bool) {
+	if evidenceDir == "" || !changed || len(params) != 1 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.responses[params[0]]; !ok {
+		e.responses[params[0]] = response
+	}
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// evidenceFilename turns a parameter name into a safe, bounded file name.
+func evidenceFilename(param string) string {
+	name := unsafeFilenameChars.ReplaceAllString(param, "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	if name == "" || name[0] == '.' {
+		name = "_" + name
+	}
+	return name
+}
+
+// write saves the request and response of every valid parameter to dir, one
+// pair of files per parameter, until the size budget is exhausted.
+func (e *evidenceCollector) write(dir string, validParams []string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	used := make(map[string]bool)
+	var written int64
+	for _, param := range validParams {
+		response, ok := e.responses[param]
+		if !ok {
+			continue
+		}
+		name := evidenceFilename(param)
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", evidenceFilename(param), i)
+		}
+		used[name] = true
+
+		size := int64(len(response.RawRequest) + len(response.Body))
+		if written+size > evidenceMaxBytes {
+			logger.Warn("Evidence size limit reached, skipping the remaining parameters", "limit", evidenceMaxBytes)
+			return nil
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".request.txt"), response.RawRequest, 0644); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name+".response.txt")
+		if err := os.WriteFile(path, response.Body, 0644); err != nil {
+			return err
+		}
+		e.paths[param] = path
+		written += size
+	}
+	return nil
+}
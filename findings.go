@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Parameter sources reported in findings.
+const (
+	sourceWordlist = "wordlist"
+	sourceForm     = "form"
+)
+
+// Finding describes a discovered parameter: where it came from, whether its
+// value was reflected in a response and in which contexts, whether it
+// triggered a server error, the file its evidence was saved to, where it
+// redirected to when that differs from the baseline and the URL and method it
+// was found with.
+type Finding struct {
+	Name               string   `json:"name"`
+	Sources            []string `json:"sources"`
+	Reflected          bool     `json:"reflected"`
+	ReflectionContexts []string `json:"reflection_contexts,omitempty"`
+	ErrorTriggering    bool     `json:"error_triggering"`
+	Evidence           string   `json:"evidence,omitempty"`
+	Title              string   `json:"title,omitempty"`
+	Heading            string   `json:"heading,omitempty"`
+	RedirectsTo        string   `json:"redirects_to,omitempty"`
+	URL                string   `json:"url"`
+	Method             string   `json:"method"`
+}
+
+// onlyReflected restricts the reported parameters to those whose value was
+// reflected in a response, the candidate XSS sinks.
+var onlyReflected bool
+
+// reflectionTracker remembers the parameters whose value has been seen
+// reflected in any response of the scan, and the contexts it was reflected
+// in by the first of those responses.
+type reflectionTracker struct {
+	mu       sync.Mutex
+	params   map[string]bool
+	contexts map[string][]string
+}
+
+var reflected = &reflectionTracker{params: make(map[string]bool), contexts: make(map[string][]string)}
+
+func (r *reflectionTracker) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.params = make(map[string]bool)
+	r.contexts = make(map[string][]string)
+}
+
+// add records the names of params reflected in a response with body and
+// contentType. The body is only parsed for newly reflected parameters.
+func (r *reflectionTracker) add(params url.Values, names []string, body []byte, contentType string) {
+	if len(names) == 0 {
+		return
+	}
+	r.mu.Lock()
+	var added []string
+	for _, name := range names {
+		if !r.params[name] {
+			r.params[name] = true
+			added = append(added, name)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, name := range added {
+		contexts := reflectionContexts(body, contentType, params.Get(name))
+		r.mu.Lock()
+		r.contexts[name] = contexts
+		r.mu.Unlock()
+		logger.Info("Parameter value reflected", "parameter", name, "canary", params.Get(name), "contexts", contexts)
+	}
+}
+
+// contextsOf returns the contexts the value of param was reflected in.
+func (r *reflectionTracker) contextsOf(param string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.contexts[param]
+}
+
+// list returns the reflected parameters in alphabetical order.
+func (r *reflectionTracker) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := []string{}
+	for name := range r.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *reflectionTracker) has(param string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.params[param]
+}
+
+// filter returns the parameters of params that were reflected.
+func (r *reflectionTracker) filter(params []string) []string {
+	kept := []string{}
+	for _, param := range params {
+		if r.has(param) {
+			kept = append(kept, param)
+		}
+	}
+	return kept
+}
+
+// buildFindings attributes each valid parameter to every source it was found
+// in, so a form field that is also in the wordlist keeps both origins.
+func buildFindings(request Request, validParams []string, wordlistParams []string, formParams []string) []Finding {
+	inWordlist := make(map[string]bool)
+	for _, param := range wordlistParams {
+		inWordlist[param] = true
+	}
+	inForm := make(map[string]bool)
+	for _, param := range formParams {
+		inForm[param] = true
+	}
+
+	findings := []Finding{}
+	for _, param := range validParams {
+		var sources []string
+		if inWordlist[param] {
+			sources = append(sources, sourceWordlist)
+		}
+		if inForm[param] {
+			sources = append(sources, sourceForm)
+		}
+		heading := titles.get(param)
+		findings = append(findings, Finding{
+			Name:               param,
+			Sources:            sources,
+			Reflected:          reflected.has(param),
+			ReflectionContexts: reflected.contextsOf(param),
+			ErrorTriggering:    serverErrors.has(param),
+			Evidence:           evidence.pathOf(param),
+			Title:              heading.Title,
+			Heading:            heading.Heading,
+			RedirectsTo:        routes.get(param),
+			URL:                request.URL,
+			Method:             request.Method,
+		})
+	}
+	// Error-triggering parameters are the most interesting, they come first
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].ErrorTriggering && !findings[j].ErrorTriggering
+	})
+	return findings
+}
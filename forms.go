@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// scanForms scans the handlers of the forms found on the target page as
+// targets of their own, seeded with the names of the forms' inputs.
+var scanForms bool
+
+// Form is a form found on the scanned page: the absolute URL it submits to,
+// its method and the names of its inputs.
+type Form struct {
+	Action string   `json:"action"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+// extractForms parses the forms of the page at pageURL. A form without an
+// action submits to the page itself and one without a method uses GET.
+func extractForms(body []byte, pageURL string) []Form {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		logger.Error("Failed to parse HTML", "error", err)
+		return nil
+	}
+
+	var forms []Form
+	doc.Find("form").Each(func(_ int, s *goquery.Selection) {
+		action, err := base.Parse(strings.TrimSpace(s.AttrOr("action", "")))
+		if err != nil || (action.Scheme != "http" && action.Scheme != "https") {
+			return
+		}
+		action.Fragment = ""
+		form := Form{
+			Action: action.String(),
+			Method: strings.ToUpper(strings.TrimSpace(s.AttrOr("method", "GET"))),
+			Params: []string{},
+		}
+		if form.Method != "POST" {
+			form.Method = "GET"
+		}
+		s.Find("input, select, textarea").Each(func(_ int, input *goquery.Selection) {
+			if name, exists := input.Attr("name"); exists && name != "" && !slices.Contains(form.Params, name) {
+				form.Params = append(form.Params, name)
+			}
+		})
+		forms = append(forms, form)
+	})
+	return forms
+}
+
+// scanFormTargets scans the handler of every form that submits somewhere
+// else than the scanned request, on the same host, for the form's inputs and
+// the candidates. Forms sharing a handler are scanned once.
+func scanFormTargets(request Request, forms []Form, candidates []Candidate, chunkSize int) []Results {
+	target, err := url.Parse(request.URL)
+	if err != nil {
+		return nil
+	}
+
+	var targets []Results
+	scanned := map[string]bool{request.Method + " " + request.URL: true}
+	for _, form := range forms {
+		key := form.Method + " " + form.Action
+		if scanned[key] {
+			continue
+		}
+		scanned[key] = true
+		action, err := url.Parse(form.Action)
+		if err != nil || action.Host != target.Host {
+			logger.Info("Skipping form submitting to another host", "action", form.Action)
+			continue
+		}
+
+		formRequest := Request{
+			URL:         form.Action,
+			Method:      form.Method,
+			ContentType: "form",
+			Headers:     request.Headers,
+		}
+		seeds := make([]Candidate, 0, len(form.Params)+len(candidates))
+		for _, param := range form.Params {
+			seeds = append(seeds, Candidate{Name: param})
+		}
+		for _, candidate := range candidates {
+			if !slices.Contains(form.Params, candidate.Name) {
+				seeds = append(seeds, candidate)
+			}
+		}
+
+		logger.Info("Scanning form handler", "url", formRequest.URL, "method", formRequest.Method, "inputs", form.Params)
+		results, err := scanCandidates(formRequest, seeds, chunkSize)
+		if err != nil {
+			logger.Warn("Form handler scan failed", "url", formRequest.URL, "method", formRequest.Method, "error", err)
+			continue
+		}
+		results.SchemaVersion = reportSchemaVersion
+		logger.Info("Valid form handler parameters found", "url", formRequest.URL, "count", len(results.Params), "valid", results.Params)
+		targets = append(targets, results)
+	}
+	return targets
+}
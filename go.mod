@@ -3,11 +3,12 @@ module github.com/pyneda/paramsmap
 go 1.22.3
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/PuerkitoBio/goquery v1.9.2
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/sergi/go-diff v1.3.1
+	golang.org/x/net v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/andybalholm/cascadia v1.3.2 // indirect
-	golang.org/x/net v0.24.0 // indirect
-)
+require github.com/andybalholm/cascadia v1.3.2 // indirect
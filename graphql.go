@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// graphqlField is the root query field whose arguments are discovered in
+// graphql mode. When empty, the candidates are tested as root fields instead.
+var graphqlField string
+
+// graphqlName matches the names GraphQL accepts. Other candidates would make
+// the whole document invalid, which looks like a change.
+var graphqlName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// graphqlSuggestions matches the "Did you mean" hints of validation errors,
+// which depend on how close a candidate is to a valid name rather than on the
+// candidate being valid.
+var graphqlSuggestions = regexp.MustCompile(`\s*Did you mean [^?]*\?`)
+
+const graphqlIntrospectionQuery = `query { __schema { queryType { fields { name args { name } } } } }`
+
+// GraphQLField is a root query field and the arguments found for it.
+type GraphQLField struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// GraphQLResults reports what a graphql mode scan found: the fields and
+// arguments from introspection when it is enabled, or the ones brute-forced
+// from the wordlist.
+type GraphQLResults struct {
+	Introspection bool           `json:"introspection"`
+	Fields        []GraphQLField `json:"fields"`
+}
+
+// graphqlRequest returns a copy of request posting query as a JSON GraphQL
+// request.
+func graphqlRequest(request Request, query string) Request {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	request.Method = "POST"
+	request.ContentType = "json"
+	request.Data = string(body)
+	return request
+}
+
+// graphqlQuery returns the query testing name: as an argument of graphqlField,
+// or as a root field.
+func graphqlQuery(name string) string {
+	if graphqlField != "" {
+		return fmt.Sprintf(`query { %s(%s: "%s") { __typename } }`, graphqlField, name, randomString(8))
+	}
+	return fmt.Sprintf(`query { %s { __typename } }`, name)
+}
+
+// makeGraphQLRequest sends the query testing name, removing the quoted name
+// and the suggestions from the response so validation errors about unknown
+// names compare equal whatever the name.
+func makeGraphQLRequest(request Request, name string) ResponseData {
+	response := makeRequest(graphqlRequest(request, graphqlQuery(name)), url.Values{})
+	body := graphqlSuggestions.ReplaceAll(response.Body, nil)
+	body = bytes.ReplaceAll(body, []byte(`\"`+name+`\"`), []byte(`\"\"`))
+	if !bytes.Equal(body, response.Body) {
+		response.Body = body
+		response.BodyHash = sha256.Sum256(body)
+	}
+	return response
+}
+
+// introspectGraphQL asks the endpoint for its root query fields, returning
+// nil when introspection is disabled.
+func introspectGraphQL(request Request) []GraphQLField {
+	response := makeRequest(graphqlRequest(request, graphqlIntrospectionQuery), url.Values{})
+	var introspection struct {
+		Data struct {
+			Schema struct {
+				QueryType struct {
+					Fields []struct {
+						Name string `json:"name"`
+						Args []struct {
+							Name string `json:"name"`
+						} `json:"args"`
+					} `json:"fields"`
+				} `json:"queryType"`
+			} `json:"__schema"`
+		} `json:"data"`
+	}
+	if response.Err != nil || json.Unmarshal(response.Body, &introspection) != nil {
+		return nil
+	}
+	recordBaseline(response)
+
+	var fields []GraphQLField
+	for _, field := range introspection.Data.Schema.QueryType.Fields {
+		args := []string{}
+		for _, arg := range field.Args {
+			args = append(args, arg.Name)
+		}
+		fields = append(fields, GraphQLField{Name: field.Name, Args: args})
+	}
+	return fields
+}
+
+// discoverGraphQLParams discovers the root fields of a GraphQL endpoint, or
+// the arguments of graphqlField, from introspection or else by testing every
+// candidate in a query of its own. GraphQL rejects a whole document over a
+// single unknown name, so candidates can't be chunked. The baselines use
+// random names, so candidates are compared with the error for an unknown one.
+func discoverGraphQLParams(request Request, params []string) (Results, error) {
+	graphqlResults := &GraphQLResults{Fields: []GraphQLField{}}
+	if fields := introspectGraphQL(request); len(fields) > 0 {
+		logger.Info("GraphQL introspection is enabled", "fields", len(fields))
+		graphqlResults.Introspection = true
+		graphqlResults.Fields = fields
+		var validParams []string
+		for _, field := range fields {
+			if graphqlField == "" {
+				validParams = append(validParams, field.Name)
+			} else if field.Name == graphqlField {
+				validParams = append(validParams, field.Args...)
+			}
+		}
+		validParams = appendUnique(nil, validParams)
+		for _, name := range validParams {
+			notifyDiscovered(request, name)
+		}
+		return Results{
+			Params:        validParams,
+			FormParams:    []string{},
+			Findings:      buildFindings(request, validParams, params, nil),
+			GraphQL:       graphqlResults,
+			TotalRequests: scanRequests(),
+			Injection:     "graphql",
+			Request:       request,
+		}, nil
+	}
+
+	var baselineResponses []ResponseData
+	for i := 0; i < numBaselines; i++ {
+		baselineResponses = append(baselineResponses, makeGraphQLRequest(request, "zz"+randomString(12)))
+	}
+	freezeCookies()
+	initialResponses := newInitialResponses(baselineResponses)
+	recordBaseline(initialResponses.Responses[0])
+	if budget.isExceeded() {
+		results := abortedResults(request, "graphql", ErrBudgetExceeded, budgetAbortReason)
+		results.GraphQL = graphqlResults
+		return results, nil
+	}
+	if baselinesFailed(initialResponses.Responses) {
+		return Results{}, errTargetUnreachable(request)
+	}
+	if !initialResponses.AreConsistent {
+		comparisons := compareBaselines(initialResponses.Responses)
+		reason := inconsistentBaselinesReason(comparisons)
+		logger.Warn("Baseline responses differ significantly. The page appears to be too dynamic. Scanning will be skipped.", "reason", reason)
+		results := abortedResults(request, "graphql", ErrInconsistentBaselines, reason)
+		results.BaselineComparisons = comparisons
+		results.GraphQL = graphqlResults
+		return results, nil
+	}
+
+	params = validParamNames(params)
+	var names []string
+	for _, param := range params {
+		if graphqlName.MatchString(param) {
+			names = append(names, param)
+		}
+	}
+	if skipped := len(params) - len(names); skipped > 0 {
+		logger.Info("Skipping candidates that aren't valid GraphQL names", "count", skipped)
+	}
+
+	logger.Info("GraphQL introspection is disabled, testing candidates one per query", "count", len(names), "field", graphqlField)
+	detector.reset()
+	evidence.reset()
+	titles.reset()
+	routes.reset(initialResponses.Responses)
+	serverErrors.reset(initialResponses.Responses)
+	group := newBoundedGroup(filterConcurrency)
+	var mu sync.Mutex
+	validParams := []string{}
+	for _, name := range names {
+		group.run(func() {
+			if scanStopped() {
+				return
+			}
+			response := makeGraphQLRequest(request, name)
+			if budget.isExceeded() {
+				return
+			}
+			changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+			if detector.observe(response, changed) || !changed {
+				return
+			}
+			recordResponse([]string{name}, response, changed)
+			mu.Lock()
+			validParams = append(validParams, name)
+			mu.Unlock()
+			logger.Info("Valid GraphQL name discovered", "name", name, "field", graphqlField)
+			notifyDiscovered(request, name)
+		})
+	}
+	group.wait()
+
+	if detector.isBlocked() {
+		results := abortedResults(request, "graphql", ErrBlocked, wafAbortReason)
+		results.GraphQL = graphqlResults
+		return results, nil
+	}
+	if evidenceDir != "" {
+		if err := evidence.write(evidenceDir, validParams); err != nil {
+			logger.Warn("Failed to save evidence", "error", err)
+		}
+	}
+
+	if graphqlField != "" {
+		graphqlResults.Fields = []GraphQLField{{Name: graphqlField, Args: validParams}}
+	} else {
+		for _, name := range validParams {
+			graphqlResults.Fields = append(graphqlResults.Fields, GraphQLField{Name: name, Args: []string{}})
+		}
+	}
+	results := Results{
+		Params:        validParams,
+		FormParams:    []string{},
+		Findings:      buildFindings(request, validParams, params, nil),
+		ErrorParams:   serverErrors.filter(validParams),
+		GraphQL:       graphqlResults,
+		TotalRequests: scanRequests(),
+		Injection:     "graphql",
+		Request:       request,
+	}
+	if budget.isExceeded() {
+		results.abort(ErrBudgetExceeded, budgetAbortReason)
+	}
+	return results, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// graphqlSchema holds the root fields of the mock GraphQL endpoint and their
+// arguments.
+var graphqlSchema = map[string][]string{
+	"user":  {"id", "name"},
+	"posts": {"limit"},
+}
+
+var graphqlSelection = regexp.MustCompile(`^query \{ (\w+)(?:\((\w+): "\w*"\))? \{ __typename \} \}$`)
+
+// graphqlServer serves a minimal GraphQL endpoint validating the queries sent
+// by paramsmap, with introspection enabled or not.
+func graphqlServer(t *testing.T, introspection bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Query string }
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fail := func(message string) {
+			json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]string{{"message": message}}})
+		}
+
+		if strings.Contains(body.Query, "__schema") {
+			if !introspection {
+				fail("GraphQL introspection is not allowed by Apollo Server")
+				return
+			}
+			var fields []map[string]any
+			for _, name := range []string{"posts", "user"} {
+				var args []map[string]string
+				for _, arg := range graphqlSchema[name] {
+					args = append(args, map[string]string{"name": arg})
+				}
+				fields = append(fields, map[string]any{"name": name, "args": args})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"__schema": map[string]any{"queryType": map[string]any{"fields": fields}}}})
+			return
+		}
+
+		match := graphqlSelection.FindStringSubmatch(body.Query)
+		if match == nil {
+			fail("Syntax Error: Unexpected Name")
+			return
+		}
+		field, arg := match[1], match[2]
+		args, ok := graphqlSchema[field]
+		if !ok {
+			message := fmt.Sprintf("Cannot query field %q on type \"Query\".", field)
+			if strings.HasPrefix(field, "user") {
+				message += ` Did you mean "user"?`
+			}
+			fail(message)
+			return
+		}
+		if arg != "" && !contains(args, arg) {
+			fail(fmt.Sprintf("Unknown argument %q on field \"Query.%s\".", arg, field))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{field: map[string]string{"__typename": "Node"}}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiscoverParamsGraphQL(t *testing.T) {
+	defer func(mode string, field string) { scanMode, graphqlField = mode, field }(scanMode, graphqlField)
+	scanMode = "graphql"
+	server := graphqlServer(t, false)
+	request := Request{URL: server.URL, Method: "GET"}
+
+	results, err := DiscoverParams(request, []string{"user", "users", "posts", "random1", "bad-name", "id"}, 100)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	sort.Strings(results.Params)
+	if !reflect.DeepEqual(results.Params, []string{"posts", "user"}) {
+		t.Errorf("Expected the root fields to be discovered, suggestions aside, got: %v", results.Params)
+	}
+	if results.Injection != "graphql" || results.GraphQL == nil || results.GraphQL.Introspection || len(results.GraphQL.Fields) != 2 {
+		t.Errorf("Expected the brute-forced fields to be reported, got: %+v", results.GraphQL)
+	}
+
+	graphqlField = "user"
+	results, err = DiscoverParams(request, []string{"id", "limit", "name", "random1"}, 100)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	sort.Strings(results.Params)
+	if !reflect.DeepEqual(results.Params, []string{"id", "name"}) {
+		t.Errorf("Expected the arguments of user to be discovered, got: %v", results.Params)
+	}
+	if len(results.GraphQL.Fields) != 1 || results.GraphQL.Fields[0].Name != "user" || len(results.GraphQL.Fields[0].Args) != 2 {
+		t.Errorf("Expected the arguments to be reported under their field, got: %+v", results.GraphQL.Fields)
+	}
+}
+
+func TestDiscoverParamsGraphQLIntrospection(t *testing.T) {
+	defer func(mode string, field string) { scanMode, graphqlField = mode, field }(scanMode, graphqlField)
+	defer func(previous bool) { includeBaselineHeaders = previous }(includeBaselineHeaders)
+	scanMode = "graphql"
+	includeBaselineHeaders = true
+	server := graphqlServer(t, true)
+	request := Request{URL: server.URL, Method: "GET"}
+
+	results, err := DiscoverParams(request, []string{"random1"}, 100)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	expected := []GraphQLField{{Name: "posts", Args: []string{"limit"}}, {Name: "user", Args: []string{"id", "name"}}}
+	if results.GraphQL == nil || !results.GraphQL.Introspection || !reflect.DeepEqual(results.GraphQL.Fields, expected) {
+		t.Fatalf("Expected the schema from introspection, got: %+v", results.GraphQL)
+	}
+	if !reflect.DeepEqual(results.Params, []string{"posts", "user"}) {
+		t.Errorf("Expected the root fields from introspection, got %v", results.Params)
+	}
+	if results.Baseline == nil || results.Baseline.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected the introspection response as the baseline snapshot, got: %+v", results.Baseline)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mineHashParams reports the parameters the inline scripts of the baseline
+// page read from the URL fragment, as single-page apps do for their routes.
+// The server never sees a fragment, so these are client-side candidates that
+// the scan can't verify.
+var mineHashParams bool
+
+// hashHelper matches calls of helpers reading a fragment parameter by name,
+// e.g. getHashParam("tab").
+var hashHelper = regexp.MustCompile(`\bget\w*Hash\w*\(\s*['"]([A-Za-z_$][\w$.-]*)['"]`)
+
+// hashSearchParams matches the URLSearchParams parsing location.hash, with
+// the variable it is assigned to in group 1 and, when it is read right away,
+// the name looked up in group 2: new URLSearchParams(location.hash.slice(1)).
+var hashSearchParams = regexp.MustCompile(`(?:\b([A-Za-z_$][\w$]*)\s*=\s*)?new\s+URLSearchParams\(\s*(?:window\.|document\.)?location\.hash\b(?:\.\w+\([^()]*\))?\s*\)(?:\.get\(\s*['"]([A-Za-z_$][\w$.-]*)['"]\s*\))?`)
+
+// hashPattern matches names in the patterns a script matches location.hash
+// against, e.g. location.hash.match(/[#&]token=([^&]*)/). Only the statements
+// using location.hash are searched.
+var hashPattern = regexp.MustCompile(`(?:#|&|\[#&\]|\(\?:#\|&\))\??([A-Za-z_][\w-]*)=`)
+
+// hashGetter returns the pattern of the lookups of the URLSearchParams
+// variable, e.g. hash.get("tab").
+func hashGetter(variable string) *regexp.Regexp {
+	return regexp.MustCompile(`(?:^|[^\w$.])` + regexp.QuoteMeta(variable) + `\.get\(\s*['"]([A-Za-z_$][\w$.-]*)['"]\s*\)`)
+}
+
+// hashParams returns the fragment parameters read by the inline scripts of an
+// HTML body, in the order they appear.
+func hashParams(body []byte) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	var params []string
+	add := func(matches [][]string) {
+		for _, match := range matches {
+			if match[1] != "" && !slices.Contains(params, match[1]) {
+				params = append(params, match[1])
+			}
+		}
+	}
+	doc.Find("script").Each(func(_ int, script *goquery.Selection) {
+		text := script.Text()
+		add(hashHelper.FindAllStringSubmatch(text, -1))
+		if !strings.Contains(text, "location.hash") {
+			return
+		}
+		for _, match := range hashSearchParams.FindAllStringSubmatch(text, -1) {
+			add([][]string{{match[0], match[2]}})
+			if match[1] != "" {
+				add(hashGetter(match[1]).FindAllStringSubmatch(text, -1))
+			}
+		}
+		for _, statement := range strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == '\n' }) {
+			if strings.Contains(statement, "location.hash") {
+				add(hashPattern.FindAllStringSubmatch(statement, -1))
+			}
+		}
+	})
+	return params
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{"helper", `<script>const tab = getHashParam("tab") || "home";</script>`, []string{"tab"}},
+		{"url search params", `<script>const hash = new URLSearchParams(window.location.hash.slice(1)); show(hash.get('view'), hash.get("id"));</script>`, []string{"view", "id"}},
+		{"regexp", `<script>const match = location.hash.match(/[#&]access_token=([^&]*)/);</script>`, []string{"access_token"}},
+		{"route string", `<script>if (!location.hash) { location.hash = "#page=1"; }</script>`, []string{"page"}},
+		{"several scripts", `<script>getHashParameter('lang')</script><script>var p = new URLSearchParams(location.hash.substring(1)); p.get("lang"); p.get("theme");</script>`, []string{"lang", "theme"}},
+		{"chained", `<script>const tab = new URLSearchParams(location.hash.slice(1)).get("tab");</script>`, []string{"tab"}},
+		{"query only", `<script>const q = new URLSearchParams(location.search).get("q");</script>`, nil},
+		{"search and hash", `<script>
+			const query = new URLSearchParams(location.search);
+			const hash = new URLSearchParams(location.hash.slice(1));
+			const page = query.get("page"), id = location.search.match(/[?&]id=(\d+)/);
+			const view = hash.get("view"), token = location.hash.match(/#token=([^&]*)/);
+			fetch("/api?sort=" + query.get("sort"));
+		</script>`, []string{"view", "token"}},
+		{"outside scripts", `<p>Use location.hash with .get("tab")</p>`, nil},
+	}
+	for _, test := range tests {
+		if params := hashParams([]byte("<html><body>" + test.body + "</body></html>")); !reflect.DeepEqual(params, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, params)
+		}
+	}
+}
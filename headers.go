@@ -0,0 +1,50 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// diffHeaders also compares the response headers when deciding whether a
+// candidate changed the response, like headers in -compare.
+var diffHeaders bool
+
+// ignoredHeaders are left out of the header comparison because they change
+// between any two requests. Content-Length is always left out as well, the
+// body comparison already accounts for it.
+var ignoredHeaders = []string{"Date", "Age", "Expires", "Set-Cookie", "X-Request-Id"}
+
+// addIgnoredHeaders extends ignoredHeaders with a comma separated list.
+func addIgnoredHeaders(list string) error {
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			ignoredHeaders = append(ignoredHeaders, name)
+		}
+	}
+	return nil
+}
+
+// headersMatch reports whether two responses have the same headers apart from
+// the ignored ones, or true when headers aren't compared.
+func headersMatch(a, b ResponseData) bool {
+	if !diffHeaders && !compared[signalHeaders] {
+		return true
+	}
+	return maps.Equal(comparableHeaders(a.Headers), comparableHeaders(b.Headers))
+}
+
+func comparableHeaders(header http.Header) map[string]string {
+	ignored := map[string]bool{"Content-Length": true}
+	for _, name := range ignoredHeaders {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+
+	comparable := make(map[string]string, len(header))
+	for name, values := range header {
+		if name = http.CanonicalHeaderKey(name); !ignored[name] {
+			comparable[name] = strings.Join(values, ", ")
+		}
+	}
+	return comparable
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ignoredStatuses are the status codes of responses that aren't a signal,
+// e.g. the 500s of a backend failing at random during the scan. Such a
+// response is skipped like a failed request instead of being compared.
+var ignoredStatuses = map[int]bool{}
+
+// ignoredResponses counts the candidate responses of the scan that were
+// skipped because of their status code.
+var ignoredResponses atomic.Int64
+
+// ignoredShareWarning is the share of the requests of a scan with an ignored
+// status above which its results are flagged as unreliable.
+const ignoredShareWarning = 0.1
+
+// setIgnoredStatuses parses the comma separated -ignore-status list.
+func setIgnoredStatuses(list string) error {
+	statuses := map[int]bool{}
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		status, err := strconv.Atoi(field)
+		if err != nil || status < 100 || status > 599 {
+			return fmt.Errorf("invalid status code %q", field)
+		}
+		statuses[status] = true
+	}
+	ignoredStatuses = statuses
+	return nil
+}
+
+// isIgnoredStatus reports whether the response has one of ignoredStatuses.
+func isIgnoredStatus(response ResponseData) bool {
+	return response.Err == nil && ignoredStatuses[response.StatusCode]
+}
+
+// tooManyIgnored reports whether so many responses of the scan had an ignored
+// status that the parameters they carried are likely to have been missed.
+func tooManyIgnored(ignored, total int) bool {
+	return ignored > 0 && float64(ignored) > ignoredShareWarning*float64(total)
+}
@@ -0,0 +1,76 @@
+package main
+
+import "sync"
+
+// perHostLimit caps the number of simultaneous requests sent to a single host.
+// A value of zero means no limit.
+var perHostLimit int
+
+// hostLimiter hands out a semaphore per target host so that a slow or fragile
+// host can't be flooded regardless of how many goroutines are in flight.
+type hostLimiter struct {
+	mu         sync.Mutex
+	semaphores map[string]chan struct{}
+}
+
+var limiter = &hostLimiter{semaphores: make(map[string]chan struct{})}
+
+// acquire blocks until a request slot is available for host and returns the
+// function that releases it.
+func (l *hostLimiter) acquire(host string) func() {
+	if perHostLimit <= 0 {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	semaphore, ok := l.semaphores[host]
+	if !ok || cap(semaphore) != perHostLimit {
+		semaphore = make(chan struct{}, perHostLimit)
+		l.semaphores[host] = semaphore
+	}
+	l.mu.Unlock()
+
+	semaphore <- struct{}{}
+	return func() { <-semaphore }
+}
+
+// filterConcurrency and recurseConcurrency cap the goroutines of the two scan
+// phases: requesting every chunk, and narrowing the changed chunks down to
+// their parameters. A value of zero means no limit.
+var (
+	filterConcurrency  = 10
+	recurseConcurrency = 10
+)
+
+// boundedGroup is a WaitGroup that runs at most limit functions at a time.
+type boundedGroup struct {
+	wg    sync.WaitGroup
+	slots chan struct{}
+}
+
+func newBoundedGroup(limit int) *boundedGroup {
+	group := &boundedGroup{}
+	if limit > 0 {
+		group.slots = make(chan struct{}, limit)
+	}
+	return group
+}
+
+// run blocks until a slot is free and calls f in a new goroutine.
+func (g *boundedGroup) run(f func()) {
+	if g.slots != nil {
+		g.slots <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.slots != nil {
+			defer func() { <-g.slots }()
+		}
+		f()
+	}()
+}
+
+func (g *boundedGroup) wait() {
+	g.wg.Wait()
+}
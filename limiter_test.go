@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBoundedGroupLimitsConcurrency(t *testing.T) {
+	group := newBoundedGroup(3)
+	var running, peak, done atomic.Int64
+
+	for i := 0; i < 20; i++ {
+		group.run(func() {
+			current := running.Add(1)
+			for {
+				previous := peak.Load()
+				if current <= previous || peak.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+	}
+	group.wait()
+
+	if done.Load() != 20 {
+		t.Errorf("Expected every function to run, got %d", done.Load())
+	}
+	if peak.Load() > 3 {
+		t.Errorf("Expected at most 3 functions at a time, got %d", peak.Load())
+	}
+}
+
+func TestThrottleSpacesRequests(t *testing.T) {
+	defer func(delay, jitter time.Duration) { requestDelay, requestJitter = delay, jitter }(requestDelay, requestJitter)
+	requestDelay, requestJitter = 20*time.Millisecond, 0
+
+	throttle := &throttle{}
+	start := time.Now()
+	var sent [5]time.Time
+	done := make(chan struct{})
+	for i := range sent {
+		go func() {
+			throttle.wait()
+			sent[i] = time.Now()
+			done <- struct{}{}
+		}()
+	}
+	for range sent {
+		<-done
+	}
+	last := start
+	for _, at := range sent {
+		if at.After(last) {
+			last = at
+		}
+	}
+	// The slots of concurrent callers are spaced out, the first is immediate
+	if elapsed := last.Sub(start); elapsed < 4*requestDelay {
+		t.Errorf("Expected 5 requests to take at least %s, took %s", 4*requestDelay, elapsed)
+	}
+
+	requestJitter = 5 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		if delay := nextDelay(); delay < 15*time.Millisecond || delay > 25*time.Millisecond {
+			t.Fatalf("Expected the delay within the jitter, got %s", delay)
+		}
+	}
+	requestDelay = 0
+	for i := 0; i < 1000; i++ {
+		if delay := nextDelay(); delay < 0 || delay > requestJitter {
+			t.Fatalf("Expected a jittered delay without a base delay to stay positive, got %s", delay)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import "sync"
+
+// linearScan sends every candidate on its own instead of in chunks narrowed
+// down by bisection. It costs a request per candidate, but each finding comes
+// from a response that only that parameter changed, which makes false
+// positives easy to debug.
+var linearScan bool
+
+// linearFilter requests every parameter on its own and keeps those whose
+// response changed, confirmed by a second, uncached request.
+func linearFilter(request Request, params []string, initialResponses InitialResponses) []string {
+	group := newBoundedGroup(filterConcurrency)
+	valid := make([]bool, len(params))
+	var mu sync.Mutex
+
+	for i, param := range params {
+		group.run(func() {
+			if scanStopped() {
+				return
+			}
+			part := []string{param}
+			response := makeRequestRetrying(request, generateParams(part))
+			if budget.isExceeded() {
+				return
+			}
+			changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+			if detector.observe(response, changed) {
+				return
+			}
+			recordResponse(part, response, changed)
+			if !changed || !linearChangePersists(request, part, initialResponses) {
+				return
+			}
+			mu.Lock()
+			valid[i] = true
+			mu.Unlock()
+		})
+	}
+	group.wait()
+
+	var validParams []string
+	for i, param := range params {
+		if valid[i] {
+			validParams = append(validParams, param)
+			logger.Info("Valid parameter discovered", "parameter", param)
+			notifyDiscovered(request, param)
+		}
+	}
+	return validParams
+}
+
+// linearChangePersists re-sends a single parameter, bypassing the cache, and
+// reports whether the response still changed.
+func linearChangePersists(request Request, part []string, initialResponses InitialResponses) bool {
+	response := sendRequest(request, generateParams(part))
+	if budget.isExceeded() {
+		return false
+	}
+	changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+	if detector.observe(response, changed) {
+		return false
+	}
+	if !changed {
+		logger.Debug("Discarding parameter whose change did not persist", "parameter", part[0])
+	}
+	return changed
+}
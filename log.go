@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// logHandler is the handler of logger. It forwards the records to the handler
+// of the logger given to setLogger, which can be replaced while workers are
+// logging. Scans share the package state, so the logger is process wide
+// rather than an option of each scan.
+var logHandler = newSwapHandler(defaultLogHandler())
+
+func defaultLogHandler() slog.Handler {
+	return slog.NewTextHandler(os.Stdout, nil)
+}
+
+// setLogger makes paramsmap log to l, or to stdout in the slog text format
+// when l is nil, and returns the logger it logged to before. It's safe to call
+// while a scan is running, e.g. for a test to capture the logs of a scan.
+func setLogger(l *slog.Logger) *slog.Logger {
+	handler := defaultLogHandler()
+	if l != nil {
+		handler = l.Handler()
+	}
+	if swap, ok := handler.(*swapHandler); ok && swap.current == logHandler.current {
+		return logger // Already logging to it, swapping would make it forward to itself
+	}
+	return slog.New(*logHandler.current.Swap(&handler))
+}
+
+// swapHandler is a slog handler delegating to the handler in current. derive
+// replays the WithAttrs and WithGroup calls made on it onto that handler.
+type swapHandler struct {
+	current *atomic.Pointer[slog.Handler]
+	derive  func(slog.Handler) slog.Handler
+}
+
+func newSwapHandler(handler slog.Handler) *swapHandler {
+	current := &atomic.Pointer[slog.Handler]{}
+	current.Store(&handler)
+	return &swapHandler{current: current}
+}
+
+func (h *swapHandler) handler() slog.Handler {
+	handler := *h.current.Load()
+	if h.derive != nil {
+		handler = h.derive(handler)
+	}
+	return handler
+}
+
+func (h *swapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler().Enabled(ctx, level)
+}
+
+func (h *swapHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler().Handle(ctx, record)
+}
+
+func (h *swapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &swapHandler{current: h.current, derive: func(handler slog.Handler) slog.Handler {
+		if h.derive != nil {
+			handler = h.derive(handler)
+		}
+		return handler.WithAttrs(attrs)
+	}}
+}
+
+func (h *swapHandler) WithGroup(name string) slog.Handler {
+	return &swapHandler{current: h.current, derive: func(handler slog.Handler) slog.Handler {
+		if h.derive != nil {
+			handler = h.derive(handler)
+		}
+		return handler.WithGroup(name)
+	}}
+}
+
+// newLogger returns the logger writing to output at level, colorized for a
+// human reader or in the plain slog text format.
+func newLogger(output io.Writer, level slog.Level, color bool) *slog.Logger {
+	if color {
+		return slog.New(&colorHandler{mu: &sync.Mutex{}, output: output, level: level})
+	}
+	return slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: level}))
+}
+
+// colorEnabled reports whether output is a terminal that should get colors.
+// NO_COLOR turns colors off as well as -no-color.
+func colorEnabled(output *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := output.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiBlue   = "\033[34m"
+	ansiCyan   = "\033[36m"
+)
+
+// colorHandler is a slog handler for terminals: a short timestamp, a colored
+// level and the attributes as key=value pairs with highlighted keys.
+type colorHandler struct {
+	mu     *sync.Mutex
+	output io.Writer
+	level  slog.Level
+	attrs  string // Attributes added with WithAttrs, already formatted
+	group  string // Key prefix of the groups opened with WithGroup
+}
+
+func (h *colorHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *colorHandler) Handle(_ context.Context, record slog.Record) error {
+	var line strings.Builder
+	line.WriteString(ansiDim + record.Time.Format("15:04:05") + ansiReset + " ")
+	line.WriteString(levelColor(record.Level) + fmt.Sprintf("%-5s", record.Level.String()) + ansiReset + " ")
+	line.WriteString(record.Message)
+	line.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		writeColorAttr(&line, h.group, attr)
+		return true
+	})
+	line.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.output, line.String())
+	return err
+}
+
+func (h *colorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var formatted strings.Builder
+	for _, attr := range attrs {
+		writeColorAttr(&formatted, h.group, attr)
+	}
+	clone := *h
+	clone.attrs += formatted.String()
+	return &clone
+}
+
+func (h *colorHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group += name + "."
+	return &clone
+}
+
+func writeColorAttr(line *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			writeColorAttr(line, prefix, member)
+		}
+		return
+	}
+
+	value := attr.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	line.WriteString(" " + ansiCyan + group + attr.Key + "=" + ansiReset + value)
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiGreen
+	}
+	return ansiBlue
+}
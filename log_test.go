@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestQuietSuppressesInfoLogs(t *testing.T) {
+	defer setLogger(setLogger(nil))
+
+	if code := run([]string{"-quiet", "-url", "http://localhost:8181", "-wordlist", t.TempDir(), "-report", ""}); code != exitError {
+		t.Fatalf("Expected the unreadable wordlist to fail the run, got exit code %d", code)
+	}
+	if logger.Enabled(context.Background(), slog.LevelInfo) || !logger.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("Expected -quiet to only log errors")
+	}
+
+	for _, color := range []bool{false, true} {
+		var output bytes.Buffer
+		quiet := newLogger(&output, slog.LevelError, color)
+		quiet.Info("Loaded parameters from wordlist", "count", 3)
+		quiet.Warn("Dropping outlier baseline responses")
+		if output.Len() != 0 {
+			t.Errorf("Expected info and warning logs to be suppressed, got: %q", output.String())
+		}
+		quiet.Error("Scan failed", "error", "target unreachable")
+		if !strings.Contains(output.String(), "Scan failed") || !strings.Contains(output.String(), `"target unreachable"`) {
+			t.Errorf("Expected the error to be logged, got: %q", output.String())
+		}
+	}
+}
+
+func TestColorHandlerFormatsAttributes(t *testing.T) {
+	var output bytes.Buffer
+	colored := newLogger(&output, slog.LevelInfo, true).With("url", "http://a.test/").WithGroup("scan")
+	colored.Info("Valid parameter discovered", "parameter", "debug")
+
+	line := output.String()
+	for _, expected := range []string{ansiGreen + "INFO ", "Valid parameter discovered", "url=" + ansiReset + "http://a.test/", "scan.parameter=" + ansiReset + "debug"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("Expected %q in the colored line %q", expected, line)
+		}
+	}
+}
+
+func TestSetLoggerCapturesScanLogs(t *testing.T) {
+	startMockServer()
+	var output bytes.Buffer
+	defer setLogger(setLogger(slog.New(slog.NewTextHandler(&output, nil))))
+
+	request := Request{URL: "http://localhost:8181", Method: "GET"}
+	if _, err := DiscoverParams(request, []string{"param1", "page", "random1"}, 3); err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !strings.Contains(output.String(), "msg=\"Valid parameter discovered\" parameter=page") {
+		t.Errorf("Expected the scan to log to the logger set, got: %q", output.String())
+	}
+}
+
+func TestSetLoggerWhileLogging(t *testing.T) {
+	defer setLogger(setLogger(nil))
+
+	// Derived loggers follow the logger set after them
+	var swapped syncBuffer
+	scoped := logger.With("url", "http://a.test/").WithGroup("scan")
+	setLogger(slog.New(slog.NewTextHandler(&swapped, nil)))
+	scoped.Info("Valid parameter discovered", "parameter", "debug")
+	if line := swapped.String(); !strings.Contains(line, "url=http://a.test/ scan.parameter=debug") {
+		t.Errorf("Expected the derived logger to log to the new logger with its attributes, got: %q", line)
+	}
+	setLogger(logger)
+	logger.Info("Parameter value reflected", "parameter", "q")
+	if !strings.Contains(swapped.String(), "parameter=q") {
+		t.Errorf("Expected setting logger itself to leave the logger set unchanged")
+	}
+
+	// Every record logged while the logger is swapped reaches one of them
+	var first, second syncBuffer
+	setLogger(slog.New(slog.NewTextHandler(&first, nil)))
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				logger.Info("Parameter value reflected", "parameter", "q")
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		setLogger(slog.New(slog.NewTextHandler(&second, nil)))
+		setLogger(slog.New(slog.NewTextHandler(&first, nil)))
+	}
+	wg.Wait()
+	lines := strings.Count(first.String(), "\n") + strings.Count(second.String(), "\n")
+	if lines != 800 || strings.Count(first.String()+second.String(), "parameter=q\n") != 800 {
+		t.Errorf("Expected the 800 records to be logged whole across the swaps, got %d lines", lines)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written from several goroutines.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
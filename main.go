@@ -1,81 +1,511 @@
 package main
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var totalRequests int
-var logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+// totalRequests counts every request sent by the process, retries included.
+// Workers send requests concurrently, so it's only accessed atomically.
+var totalRequests atomic.Int64
+
+// scanStartRequests is totalRequests when the current scan started.
+var scanStartRequests int64
+
+// scanRequests returns the requests sent since the current scan started, the
+// request count reported in its Results.
+func scanRequests() int {
+	return int(totalRequests.Load() - scanStartRequests)
+}
+
+// logger is what paramsmap logs with. It logs to the logger set with
+// setLogger, by run from the logging flags.
+var logger = slog.New(logHandler)
+
 var ignoreCertErrors bool
+var clientCert, clientKey string
+var tlsMin, tlsMax string
+
+// hostOverride is sent as the Host header and TLS server name, so a virtual
+// host can be scanned while connecting to the address in the URL.
+var hostOverride string
 var numBaselines = 3
+
+// skipForms disables adding the parameters of the baseline's forms to the scan.
+var skipForms bool
 var reportPath string
 
+// httpClient is shared by every request of a scan so connections are pooled.
+var httpClient *http.Client
+
 func main() {
-	var requestURL, method, postData, contentType, wordlist string
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command line tool with the given arguments and returns the
+// process exit code.
+func run(args []string) int {
+	flags := flag.NewFlagSet("paramsmap", flag.ContinueOnError)
+	var requestURL, method, postData, dataFile, contentType, wordlist string
 	var chunkSize int
-	flag.StringVar(&requestURL, "url", "", "The URL to make the request to")
-	flag.StringVar(&method, "method", "GET", "HTTP method to use")
-	flag.StringVar(&postData, "data", "", "Optional POST data")
-	flag.StringVar(&contentType, "type", "form", "Content type: form, json, xml")
-	flag.StringVar(&wordlist, "wordlist", "wordlist.txt", "Path to the wordlist file")
-	flag.StringVar(&reportPath, "report", "report.json", "Path to the output report file")
-	flag.IntVar(&chunkSize, "chunk-size", 1000, "Number of parameters to send in each request")
-	flag.BoolVar(&ignoreCertErrors, "ignore-cert", false, "Ignore SSL certificate errors")
-
-	flag.Parse()
-
-	if requestURL == "" {
+	var debug, quiet, noColor, failOnFound bool
+	var configPath, baselineReportPath, serveAddr string
+	headers := headerFlags{}
+	flags.StringVar(&requestURL, "url", "", "The URL to make the request to")
+	flags.StringVar(&method, "method", "GET", "HTTP method to use")
+	flags.StringVar(&postData, "data", "", "Optional POST data")
+	flags.StringVar(&dataFile, "data-file", "", "Read the request body from a file; a FUZZ placeholder marks where parameters are injected")
+	flags.Var(headers, "H", "Custom header \"Name: value\", can be repeated; a FUZZ placeholder in the value marks where parameters are injected")
+	flags.StringVar(&authCredentials, "auth", "", "Credentials \"user:pass\" sent with every request")
+	flags.StringVar(&authType, "auth-type", authType, "Authentication scheme used with -auth: basic, digest")
+	flags.StringVar(&tokenRefreshCommand, "token-refresh-cmd", "", "Shell command printing a fresh bearer token, run when a 401 is received")
+	flags.StringVar(&tokenRefreshURL, "token-refresh-url", "", "URL returning a fresh bearer token, fetched when a 401 is received")
+	flags.StringVar(&contentType, "type", "form", "Content type: form, json, xml, multipart or a media type such as text/plain")
+	flags.BoolVar(&replaceExisting, "replace-existing", false, "Replace the value of URL query parameters a candidate is named like instead of repeating them")
+	flags.BoolVar(&getBody, "get-body", false, "Send a body with GET requests too")
+	flags.StringVar(&wordlist, "wordlist", "wordlist.txt", "Path to the wordlist file, the built-in wordlist is used when it doesn't exist")
+	flags.StringVar(&appendWordlistPath, "append-wordlist", "", "Append the discovered parameters the given wordlist file doesn't list yet to it after the scan")
+	flags.BoolVar(&wordlistValues, "wordlist-values", false, "Read wordlist lines of the form name=value as a parameter with a fixed value")
+	flags.Func("mutate", "Comma separated parameter name variants to add to the wordlist: case, affix, separator", setMutations)
+	flags.BoolVar(&expandCase, "expand-case", false, "Also test the camelCase, snake_case, kebab-case and upper case variants of every wordlist entry")
+	flags.IntVar(&maxMutations, "mutate-max", maxMutations, "Maximum variants added per wordlist entry with -mutate or -expand-case")
+	flags.IntVar(&maxParamLength, "max-param-length", maxParamLength, "Skip candidate names longer than this (0 for no limit)")
+	flags.StringVar(&paramPrefix, "param-prefix", "", "Prefix added to every candidate name when it is sent, e.g. filter[")
+	flags.StringVar(&paramSuffix, "param-suffix", "", "Suffix added to every candidate name when it is sent, e.g. ]")
+	flags.StringVar(&arrayStyle, "array-style", "", "Send every candidate with two values for array parameters: "+strings.Join(arrayStyles, ", ")+" (empty for a single value)")
+	flags.Func("param-charset", "Regular expression candidate names must match, empty to accept any (default "+defaultParamCharset+")", setParamCharset)
+	flags.BoolVar(&onlyReflected, "only-reflected", false, "Only report parameters whose value is reflected in the response")
+	flags.BoolVar(&skipForms, "no-forms", false, "Don't extract form parameters from the page and add them to the scan")
+	flags.BoolVar(&scanForms, "scan-forms", false, "Also scan the handlers the page's forms submit to, on the same host, as separate targets")
+	flags.StringVar(&reportPath, "report", "report.json", "Path to the output report file, - for stdout")
+	flags.StringVar(&baselineReportPath, "baseline-report", "", "Previous JSON report to compare the discovered parameters with")
+	flags.StringVar(&reportFormat, "report-format", reportFormat, "Report format: json, ndjson (one record per line, streamed), sarif (findings only)")
+	flags.StringVar(&evidenceDir, "save-evidence", "", "Directory to save the request and response proving each discovered parameter")
+	flags.StringVar(&evidenceDir, "evidence-dir", "", "Alias for -save-evidence")
+	flags.Int64Var(&evidenceMaxBytes, "evidence-max-bytes", evidenceMaxBytes, "Maximum total size of the saved evidence in bytes")
+	flags.StringVar(&scanMode, "mode", scanMode, "What candidates are injected as: params, path (one request per candidate), graphql (fields or arguments of a GraphQL endpoint)")
+	flags.StringVar(&graphqlField, "graphql-field", "", "Root query field whose arguments are discovered in graphql mode, instead of the root fields")
+	flags.IntVar(&chunkSize, "chunk-size", 1000, "Number of parameters to send in each request")
+	flags.BoolVar(&shuffleParams, "shuffle", false, "Randomize the order of the parameters before chunking them")
+	flags.Int64Var(&randomSeed, "seed", 0, "Seed of the random values sent and of the -shuffle order; concurrent requests may draw them in a different order (0 for a random seed)")
+	flags.BoolVar(&ignoreCertErrors, "ignore-cert", false, "Ignore SSL certificate errors")
+	flags.StringVar(&clientCert, "client-cert", "", "Path to a PEM client certificate for mutual TLS")
+	flags.StringVar(&clientKey, "client-key", "", "Path to the PEM private key of the client certificate")
+	flags.StringVar(&hostOverride, "host-override", "", "Host header and TLS SNI to send while connecting to the URL's address")
+	flags.StringVar(&hostOverride, "host", "", "Alias of -host-override")
+	flags.StringVar(&tlsMin, "tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2, 1.3")
+	flags.StringVar(&tlsMax, "tls-max", "", "Maximum TLS version: 1.0, 1.1, 1.2, 1.3")
+	flags.StringVar(&resolverAddr, "resolver", "", "DNS server ip:port used to resolve the target host")
+	flags.BoolVar(&forceIPv4, "force-ipv4", false, "Only connect over IPv4")
+	flags.BoolVar(&forceIPv6, "force-ipv6", false, "Only connect over IPv6")
+	flags.DurationVar(&dialTimeout, "dial-timeout", dialTimeout, "Maximum time to establish a connection")
+	flags.DurationVar(&tlsTimeout, "tls-timeout", tlsTimeout, "Maximum time for the TLS handshake")
+	flags.DurationVar(&responseTimeout, "response-timeout", 0, "Maximum time to wait for the response headers once the request is sent (0 for no limit)")
+	flags.DurationVar(&requestTimeout, "timeout", 0, "Maximum time for a whole request, including reading the body (0 for no limit)")
+	flags.Int64Var(&maxResponseBytes, "max-response-bytes", maxResponseBytes, "Read at most this many bytes of a response, comparing longer ones truncated (0 for no limit)")
+	flags.DurationVar(&streamTimeout, "stream-timeout", streamTimeout, "Maximum time to read a response without Content-Length when -timeout isn't set (0 for no limit)")
+	flags.DurationVar(&requestDelay, "delay", 0, "Delay between requests, e.g. 200ms")
+	flags.DurationVar(&requestJitter, "jitter", 0, "Randomize each delay by up to this amount in either direction")
+	flags.IntVar(&filterConcurrency, "filter-concurrency", filterConcurrency, "Maximum chunks requested at the same time (0 for unlimited)")
+	flags.IntVar(&recurseConcurrency, "recurse-concurrency", recurseConcurrency, "Maximum changed chunks narrowed down at the same time (0 for unlimited)")
+	flags.IntVar(&perHostLimit, "per-host", 0, "Maximum simultaneous requests per target host (0 for unlimited)")
+	flags.IntVar(&directThreshold, "direct-threshold", directThreshold, "Changed chunks of at most this many parameters are tested one parameter per request instead of bisected")
+	flags.StringVar(&strategy, "strategy", strategy, "Chunk narrowing strategy: bisect, confirm")
+	flags.BoolVar(&linearScan, "linear", false, "Send every parameter on its own and confirm it with a second request, ignoring -chunk-size and -strategy")
+	flags.BoolVar(&pairsEnabled, "pairs", false, "Test pairs of parameters that only change the response together")
+	flags.IntVar(&maxPairCandidates, "pairs-max", maxPairCandidates, "Maximum parameters combined in the pairwise pass, the most promising first (n*(n-1)/2 requests)")
+	flags.StringVar(&diffAlgo, "diff-algo", diffAlgo, "How response bodies are compared: bytes, dom (element structure only)")
+	flags.Func("compare", "Comma separated signals a response is compared on: status, length, reflections, headers, timing, delta (default status,length,reflections)", setCompared)
+	flags.StringVar(&compareMode, "compare-mode", compareMode, "How many baselines a response must match to be unchanged: any, majority, all")
+	flags.BoolVar(&diffHeaders, "diff-headers", false, "Also compare response headers, apart from volatile ones")
+	flags.Func("diff-ignore-headers", "Comma separated headers to leave out of -diff-headers, added to "+strings.Join(ignoredHeaders, ", "), addIgnoredHeaders)
+	flags.IntVar(&maxDiffBytes, "max-diff-bytes", 0, "Compare bodies larger than this with a cheap prefix and suffix heuristic instead of a full diff (0 for no limit)")
+	flags.Float64Var(&similarityOverride, "similarity", 0, "Body similarity above which a response is unchanged (0 calibrates it against the target)")
+	flags.IntVar(&minLengthDelta, "min-length-delta", minLengthDelta, "Body length difference, in bytes, from which the delta signal of -compare counts a response as changed")
+	flags.Float64Var(&minContentChange, "min-content-change", 0, "Only count responses whose content, ignoring reflected values, changed by at least this fraction (0 disables)")
+	flags.BoolVar(&reflectionShortcut, "reflection-shortcut", reflectionShortcut, "Attribute changed chunks directly to the parameters whose value is reflected")
+	flags.Func("ignore-status", "Comma separated status codes of responses that are skipped like failed requests instead of compared, e.g. 500,503", setIgnoredStatuses)
+	flags.Func("content-types", "Comma separated media types of the baseline that are scanned, empty for any (default "+strings.Join(scannedContentTypes, ",")+")", setContentTypes)
+	flags.BoolVar(&includeBaselineHeaders, "include-headers-in-report", false, "Record the status code, main headers and body length of the baseline response in the report")
+	flags.BoolVar(&probeMethod, "probe-method", false, "Ask the endpoint which methods it allows with an OPTIONS request before scanning")
+	flags.StringVar(&matchString, "match-string", "", "Also count a response as changed when it contains this string and the baselines don't")
+	flags.StringVar(&filterString, "filter-string", "", "Also count a response as changed when the baselines contain this string and it doesn't")
+	flags.BoolVar(&matchOnly, "match-only", false, "Only count a response as changed through -match-string and -filter-string, ignoring the comparison of responses")
+	flags.StringVar(&compareCommand, "compare-command", "", "Shell command reading the baselines and a candidate response as JSON on stdin, exiting 0 when the candidate changed and 1 when it didn't")
+	flags.BoolVar(&comparatorReplaces, "compare-command-only", false, "Only count a response as changed through -compare-command, ignoring the comparison of responses")
+	flags.BoolVar(&mineHashParams, "hash-params", false, "Report the URL fragment parameters read by the page's inline scripts, which the server can't verify")
+	flags.Float64Var(&timingDeviations, "timing-deviations", timingDeviations, "Standard deviations above the mean baseline latency a response must take to be changed when timing is compared")
+	flags.BoolVar(&forceScan, "force", false, "Scan even if the baselines are inconsistent, comparing only status codes and reflections")
+	flags.BoolVar(&reuseCookies, "reuse-cookies", false, "Send the cookies the target sets on the baseline requests with every candidate")
+	flags.BoolVar(&cacheEnabled, "cache", false, "Reuse responses of identical requests within a scan")
+	flags.IntVar(&cacheSize, "cache-size", cacheSize, "Maximum number of responses kept by -cache")
+	flags.IntVar(&maxRecursionDepth, "max-depth", maxRecursionDepth, "Bisect a changed chunk at most this many times, reporting what's left of it as a cluster to review")
+	flags.IntVar(&maxRequests, "max-requests", 0, "Abort the scan once this many requests were sent, keeping what was found (0 for unlimited)")
+	flags.IntVar(&wafThreshold, "waf-threshold", wafThreshold, "Consecutive similar changed responses that are treated as a WAF block (0 disables)")
+
+	flags.StringVar(&serveAddr, "serve", "", "Serve the scan API on this address instead of scanning -url, e.g. :8080 for 127.0.0.1:8080")
+	flags.StringVar(&serveWordlists, "serve-wordlists", serveWordlists, "Directory the wordlists named by API scans are read from")
+	flags.StringVar(&serveToken, "serve-token", "", "Bearer token API clients must send (default $PARAMSMAP_API_TOKEN, or a generated one)")
+	flags.Func("serve-hosts", "Comma separated hosts API scans can target, *.example.com for subdomains (default any)", setServeHosts)
+
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.BoolVar(&quiet, "quiet", false, "Only log errors")
+	flags.BoolVar(&noColor, "no-color", false, "Don't colorize the logs, even on a terminal")
+	flags.StringVar(&webhookURL, "webhook", "", "POST a JSON notification to this URL whenever a parameter is confirmed")
+	flags.BoolVar(&failOnFound, "fail-on-found", false, "Exit with code 2 when any parameter is discovered")
+	flags.Func("replay", "Answer every request from a capture file of recorded responses instead of the network", setReplay)
+	flags.StringVar(&configPath, "config", "", "Load option defaults from a YAML or TOML file, keyed by flag name")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+
+	if configPath != "" {
+		if err := loadConfig(flags, configPath); err != nil {
+			logger.Error("Failed to load config file", "error", err)
+			return exitError
+		}
+	}
+
+	// Keep stdout for the report when it's written there
+	logOutput := os.Stdout
+	if reportPath == stdoutReport {
+		logOutput = os.Stderr
+	}
+	logLevel := slog.LevelInfo
+	if debug {
+		logLevel = slog.LevelDebug
+	}
+	if quiet {
+		logLevel = slog.LevelError
+	}
+	setLogger(newLogger(logOutput, logLevel, colorEnabled(logOutput, noColor)))
+
+	if debug && quiet {
+		logger.Error("Only one of -debug and -quiet can be used")
+		return exitError
+	}
+
+	if requestURL == "" && serveAddr == "" {
 		logger.Error("URL is required")
-		return
+		return exitError
 	}
 
-	params := loadWordlist(wordlist)
-	logger.Info("Loaded parameters from wordlist", "count", len(params))
+	if dataFile != "" {
+		data, err := os.ReadFile(dataFile)
+		if err != nil {
+			logger.Error("Failed to read data file", "error", err)
+			return exitError
+		}
+		postData = string(data)
+	}
+
+	if err := validateData(postData, contentType); err != nil {
+		logger.Error("Invalid data", "error", err)
+		return exitError
+	}
+
+	if resolverAddr != "" {
+		addr, err := resolverAddress(resolverAddr)
+		if err != nil {
+			logger.Error("Invalid resolver", "error", err)
+			return exitError
+		}
+		resolverAddr = addr
+	}
+
+	if scanForms && skipForms {
+		logger.Error("Only one of -scan-forms and -no-forms can be used")
+		return exitError
+	}
+
+	if forceIPv4 && forceIPv6 {
+		logger.Error("Only one of -force-ipv4 and -force-ipv6 can be used")
+		return exitError
+	}
+
+	if err := validateTimeouts(); err != nil {
+		logger.Error("Invalid timeout", "error", err)
+		return exitError
+	}
+
+	if arrayStyle != "" && !slices.Contains(arrayStyles, arrayStyle) {
+		logger.Error("Unsupported array style", "style", arrayStyle, "supported", arrayStyles)
+		return exitError
+	}
+
+	if !slices.Contains(compareModes, compareMode) {
+		logger.Error("Unsupported compare mode", "mode", compareMode, "supported", compareModes)
+		return exitError
+	}
+
+	if matchOnly && !usingMarkers() {
+		logger.Error("-match-only requires -match-string or -filter-string")
+		return exitError
+	}
+	if comparatorReplaces && compareCommand == "" {
+		logger.Error("-compare-command-only requires -compare-command")
+		return exitError
+	}
+	comparator = nil
+	if compareCommand != "" {
+		comparator = commandComparator(compareCommand)
+	}
+
+	if reportFormat != "json" && reportFormat != "ndjson" && reportFormat != "sarif" {
+		logger.Error("Unsupported report format", "format", reportFormat)
+		return exitError
+	}
+
+	if similarityOverride < 0 || similarityOverride > 1 {
+		logger.Error("The similarity threshold must be between 0 and 1", "similarity", similarityOverride)
+		return exitError
+	}
+
+	if minLengthDelta < 1 {
+		logger.Error("The minimum length delta must be at least 1 byte", "min_length_delta", minLengthDelta)
+		return exitError
+	}
+
+	if _, err := buildTLSConfig(); err != nil {
+		logger.Error("Invalid TLS options", "error", err)
+		return exitError
+	}
+
+	if serveAddr != "" {
+		return serve(serveAddr)
+	}
+
+	var baseline Results
+	if baselineReportPath != "" {
+		var err error
+		if baseline, err = loadReport(baselineReportPath); err != nil {
+			logger.Error("Failed to load baseline report", "error", err)
+			return exitError
+		}
+	}
+
+	candidates, err := loadWordlistOrDefault(wordlist)
+	if err != nil {
+		logger.Error("Failed to load wordlist", "error", err)
+		return exitError
+	}
+	logger.Info("Loaded parameters from wordlist", "count", len(candidates))
+	if expandCase {
+		enableMutations("case", "separator")
+	}
+	if len(mutations) > 0 {
+		wordlistSize := len(candidates)
+		candidates = mutateCandidates(candidates)
+		logger.Info("Added parameter name variants", "count", len(candidates)-wordlistSize, "mutations", mutations)
+	}
 	request := Request{
 		URL:         requestURL,
 		Method:      method,
 		Data:        postData,
 		ContentType: contentType,
+		Headers:     headers,
+	}
+	results, err := scanCandidates(request, candidates, chunkSize)
+	if err != nil {
+		logger.Error("Scan failed", "error", err)
+		return exitError
+	}
+	logger.Info("Total requests made", "count", results.TotalRequests)
+	logger.Info("Scan duration", "seconds", results.Timing.DurationSeconds, "requests_per_second", results.Timing.RequestsPerSecond)
+	if strategy == "confirm" {
+		logger.Info("Requests saved by the confirm strategy", "count", results.SavedRequests)
 	}
-	results := DiscoverParams(request, params, chunkSize)
-	logger.Info("Total requests made", "count", totalRequests)
 	logger.Info("Valid parameters found", "count", len(results.Params), "valid", results.Params)
 	logger.Info("Form parameters found", "count", len(results.FormParams), "parameters", results.FormParams)
+	if len(results.ErrorParams) > 0 {
+		logger.Warn("Parameters triggering server errors found", "count", len(results.ErrorParams), "parameters", results.ErrorParams)
+	}
+	if pairsEnabled {
+		logger.Info("Parameter pairs found", "count", len(results.ParamPairs), "pairs", results.ParamPairs)
+	}
+	if scanForms {
+		results.FormTargets = scanFormTargets(request, results.Forms, candidates, chunkSize)
+	}
+	if baselineReportPath != "" {
+		results.Drift = newDrift(baselineReportPath, baseline, results)
+		logger.Info("Parameters changed since the baseline report", "new", results.Drift.NewParams, "removed", results.Drift.RemovedParams)
+	}
 	if reportPath != "" {
-		saveReport(reportPath, results)
+		if err := saveReport(reportPath, results); err != nil {
+			logger.Error("Failed to save report", "error", err)
+			return exitError
+		}
 	}
+	if appendWordlistPath != "" {
+		added, err := appendWordlist(appendWordlistPath, appendUnique(results.AllParams, results.Params))
+		if err != nil {
+			logger.Error("Failed to append the discovered parameters to the wordlist", "error", err, "path", appendWordlistPath)
+			return exitError
+		}
+		logger.Info("Appended new parameters to the wordlist", "path", appendWordlistPath, "count", len(added), "parameters", added)
+	}
+
+	// The report written to stdout already holds everything the summary does
+	if reportPath != stdoutReport {
+		printSummary(results)
+	}
+	if failOnFound && len(results.Params) > 0 {
+		return exitFound
+	}
+	return exitOK
+}
+
+// Exit codes returned by the command line tool.
+const (
+	exitOK    = 0 // The scan completed, or was aborted, without an error
+	exitError = 1 // Invalid options or input files, or the report couldn't be written
+	exitFound = 2 // Parameters were discovered and -fail-on-found is set
+)
+
+// Summary is the single machine-readable line printed when the tool exits.
+type Summary struct {
+	Params        int  `json:"params"`
+	FormParams    int  `json:"form_params"`
+	TotalRequests int  `json:"total_requests"`
+	Aborted       bool `json:"aborted"`
+}
+
+var summaryOutput io.Writer = os.Stdout
 
+func printSummary(results Results) {
+	line, err := json.Marshal(map[string]Summary{"summary": {
+		Params:        len(results.Params),
+		FormParams:    len(results.FormParams),
+		TotalRequests: results.TotalRequests,
+		Aborted:       results.Aborted,
+	}})
+	if err != nil {
+		logger.Error("Error marshalling summary", "error", err)
+		return
+	}
+	fmt.Fprintln(summaryOutput, string(line))
 }
 
 type Request struct {
-	URL         string `json:"url"`
-	Method      string `json:"method"`
-	Data        string `json:"data"`
-	ContentType string `json:"content_type"`
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	Data        string            `json:"data"`
+	ContentType string            `json:"content_type"`
+	Headers     map[string]string `json:"headers,omitempty"`
 }
 
 type Results struct {
-	Params        []string `json:"params"`
-	FormParams    []string `json:"form_params"`
-	TotalRequests int      `json:"total_requests"`
-	Aborted       bool     `json:"aborted"`
-	AbortReason   string   `json:"abort_reason"`
-	Request       Request  `json:"request"`
+	SchemaVersion       int                  `json:"schema_version"`
+	Params              []string             `json:"params"`
+	FormParams          []string             `json:"form_params"`
+	Forms               []Form               `json:"forms,omitempty"`
+	TotalRequests       int                  `json:"total_requests"`
+	SavedRequests       int                  `json:"saved_requests"`
+	FailedRequests      int                  `json:"failed_requests"`
+	IgnoredResponses    int                  `json:"ignored_responses"`
+	AllParams           []string             `json:"all_params,omitempty"`
+	Findings            []Finding            `json:"findings"`
+	ReflectedParams     []string             `json:"reflected_params"`
+	ErrorParams         []string             `json:"error_params"`
+	Clusters            [][]string           `json:"clusters,omitempty"`
+	ParamPairs          [][2]string          `json:"param_pairs,omitempty"`
+	Injection           string               `json:"injection"`
+	Degraded            bool                 `json:"degraded"`
+	CatchAll            string               `json:"catch_all"`
+	DuplicateParams     string               `json:"duplicate_params,omitempty"`
+	QueryCollisions     string               `json:"query_collisions,omitempty"`
+	ParamPrefix         string               `json:"param_prefix,omitempty"`
+	ParamSuffix         string               `json:"param_suffix,omitempty"`
+	HashParams          []string             `json:"hash_params_unverified,omitempty"`
+	SimilarityThreshold float64              `json:"similarity_threshold"`
+	Timing              Timing               `json:"timing"`
+	Baseline            *BaselineSnapshot    `json:"baseline,omitempty"`
+	ClientRedirect      *ClientRedirect      `json:"client_redirect,omitempty"`
+	FinalURL            string               `json:"final_url,omitempty"`
+	RedirectChain       []string             `json:"redirect_chain,omitempty"`
+	AllowedMethods      []string             `json:"allowed_methods,omitempty"`
+	Aborted             bool                 `json:"aborted"`
+	AbortReason         string               `json:"abort_reason"`
+	BaselineComparisons []BaselineComparison `json:"baseline_comparisons,omitempty"`
+	Warnings            []Warning            `json:"warnings,omitempty"`
+	Drift               *Drift               `json:"drift,omitempty"`
+	GraphQL             *GraphQLResults      `json:"graphql,omitempty"`
+	FormTargets         []Results            `json:"form_targets,omitempty"`
+	Request             Request              `json:"request"`
+
+	// abortErr is the condition the scan was aborted on, returned by Err.
+	abortErr error
+}
+
+// Timing summarizes how long a scan took and the request rate it achieved.
+type Timing struct {
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	DurationSeconds   float64   `json:"duration_seconds"`
+	Requests          int       `json:"requests"`
+	RequestsPerSecond float64   `json:"requests_per_second"`
+}
+
+func newTiming(start, end time.Time, requests int) Timing {
+	timing := Timing{
+		StartTime:       start,
+		EndTime:         end,
+		DurationSeconds: end.Sub(start).Seconds(),
+		Requests:        requests,
+	}
+	if timing.DurationSeconds > 0 {
+		timing.RequestsPerSecond = float64(requests) / timing.DurationSeconds
+	}
+	return timing
 }
 
 type ResponseData struct {
-	Body        []byte
-	StatusCode  int
-	Reflections int
+	Body            []byte
+	BodyHash        [sha256.Size]byte
+	StatusCode      int
+	Headers         http.Header
+	Reflections     int
+	ReflectedParams []string
+	ReflectedValues []string
+	RawRequest      []byte
+	// Elapsed is how long the response took to arrive, up to its headers.
+	Elapsed time.Duration
+	// FinalURL is the URL the response was served from, after the Redirects
+	// that were followed from the request URL, in order.
+	FinalURL  string
+	Redirects []string
+	// Truncated is set when the body was cut at maxResponseBytes or when its
+	// stream stalled past streamTimeout.
+	Truncated bool
+	// Err is set when the request failed or its body couldn't be read. Such a
+	// response says nothing about the parameters and is never compared.
+	Err error
+}
+
+// hash returns the SHA-256 of the body, computing it when the response wasn't
+// built by makeRequest.
+func (r ResponseData) hash() [sha256.Size]byte {
+	if r.BodyHash == ([sha256.Size]byte{}) {
+		return sha256.Sum256(r.Body)
+	}
+	return r.BodyHash
 }
 
 type InitialResponses struct {
@@ -84,91 +514,320 @@ type InitialResponses struct {
 	AreConsistent bool
 }
 
-func DiscoverParams(request Request, params []string, chunkSize int) Results {
+// scanCandidates scans the request for the wordlist candidates. Candidates can
+// ask for another method than the request's, each method is its own scan and
+// the results of all of them are merged.
+func scanCandidates(request Request, candidates []Candidate, chunkSize int) (Results, error) {
+	var results Results
+	for i, group := range applyCandidates(candidates, request.Method) {
+		groupRequest := request
+		groupRequest.Method = group.Method
+		groupResults, err := DiscoverParams(groupRequest, group.Params, chunkSize)
+		if err != nil {
+			return results, fmt.Errorf("%s scan: %w", group.Method, err)
+		}
+		if i == 0 {
+			results = groupResults
+		} else {
+			results = results.Merge(groupResults)
+		}
+	}
+	return results, nil
+}
+
+// DiscoverParams scans the request for the valid parameters among params. Scans
+// aborted because of the target's behaviour are reported in the Results, while
+// conditions that make the scan impossible are returned as an error.
+func DiscoverParams(request Request, params []string, chunkSize int) (Results, error) {
+	start := time.Now()
+	startRequests := totalRequests.Load()
+	if webhookURL != "" {
+		webhook = startWebhook(webhookURL)
+		defer func() {
+			webhook.close()
+			webhook = nil
+		}()
+	}
+	results, err := discoverParams(request, params, chunkSize)
+	if err != nil {
+		return results, err
+	}
+	results.Timing = newTiming(start, time.Now(), int(totalRequests.Load()-startRequests))
+	results.ClientRedirect = followedRedirect
+	results.AllowedMethods = allowedMethods
+	if includeBaselineHeaders && scanBaseline != nil {
+		results.Baseline = newBaselineSnapshot(*scanBaseline)
+	}
+	if scanBaseline != nil && len(scanBaseline.Redirects) > 0 {
+		results.FinalURL = scanBaseline.FinalURL
+		results.RedirectChain = scanBaseline.Redirects
+	}
+	results.Warnings = scanWarnings(results)
+	return results, nil
+}
+
+func discoverParams(request Request, params []string, chunkSize int) (Results, error) {
+	parsedURL, err := url.Parse(request.URL)
+	if err != nil {
+		return Results{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	if parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return Results{}, fmt.Errorf("invalid URL %q: scheme and host are required", request.URL)
+	}
+	if chunkSize < 1 {
+		return Results{}, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	httpClient = createHTTPClient()
+	budget.reset()
+	scanStartRequests = totalRequests.Load()
+	resetRandom()
+	failedRequests.Store(0)
+	ignoredResponses.Store(0)
+	catchAll = ""
+	duplicateParams = ""
+	followedRedirect = nil
+	allowedMethods = nil
+	scanBaseline = nil
+	formFieldNames = nil
+	similarityThreshold = defaultSimilarityThreshold
+	if similarityOverride > 0 {
+		similarityThreshold = similarityOverride
+	}
+	if probeMethod {
+		allowedMethods = probeAllowedMethods(request)
+	}
+	if scanMode == "path" {
+		return discoverPathParams(request, params)
+	}
+	if scanMode == "graphql" {
+		return discoverGraphQLParams(request, params)
+	}
+	primeCookies(request)
 	initialResponses := makeInitialRequests(request)
+	if followedRedirect = clientRedirect(request, initialResponses.Responses[0]); followedRedirect != nil {
+		logger.Info("Following the client-side redirect of the baseline page", "from", followedRedirect.From, "to", followedRedirect.To, "type", followedRedirect.Type)
+		request.URL = followedRedirect.To
+		initialResponses = makeInitialRequests(request)
+	}
+	recordBaseline(initialResponses.Responses[0])
+	freezeCookies()
+	if budget.isExceeded() {
+		return abortedResults(request, "", ErrBudgetExceeded, budgetAbortReason), nil
+	}
+	if baselinesFailed(initialResponses.Responses) {
+		return Results{}, errTargetUnreachable(request)
+	}
+	if contentType := unsupportedContentType(initialResponses.Responses[0]); contentType != "" {
+		logger.Warn("The baseline content type isn't scanned, skipping the scan", "content_type", contentType, "scanned", scannedContentTypes)
+		return abortedResults(request, "", ErrUnsupportedContentType, contentTypeAbortReason+" "+contentType), nil
+	}
 
 	// Check if baseline responses are consistent
-	if !initialResponses.AreConsistent {
-		logger.Warn("Baseline responses differ significantly. The page appears to be too dynamic. Scanning will be skipped.")
-		return Results{
-			Params:        []string{},
-			FormParams:    []string{},
-			Aborted:       true,
-			AbortReason:   "Baseline responses differ significantly",
-			TotalRequests: totalRequests,
-			Request:       request,
-		}
+	degradedMode = false
+	if !initialResponses.AreConsistent && forceScan {
+		logger.Warn("Baseline responses differ significantly. Forcing the scan in degraded mode, only status codes and reflections are compared.")
+		degradedMode = true
+	} else if !initialResponses.AreConsistent {
+		comparisons := compareBaselines(initialResponses.Responses)
+		reason := inconsistentBaselinesReason(comparisons)
+		logger.Warn("Baseline responses differ significantly. The page appears to be too dynamic. Scanning will be skipped.", "reason", reason)
+		results := abortedResults(request, "", ErrInconsistentBaselines, reason)
+		results.BaselineComparisons = comparisons
+		return results, nil
 	}
 
-	formsParams := extractFormParams(initialResponses.Responses[0].Body)
-	logger.Info("Extracted form parameters", "count", len(formsParams), "parameters", formsParams)
+	formsParams := []string{}
+	var forms []Form
+	if !skipForms {
+		formsParams = extractFormParams(initialResponses.Responses[0].Body)
+		logger.Info("Extracted form parameters", "count", len(formsParams), "parameters", formsParams)
+		forms = extractForms(initialResponses.Responses[0].Body, request.URL)
+	}
+	var fragmentParams []string
+	if mineHashParams {
+		fragmentParams = hashParams(initialResponses.Responses[0].Body)
+		logger.Info("Extracted URL fragment parameters, read client-side and not verified against the server", "count", len(fragmentParams), "parameters", fragmentParams)
+	}
 
-	params = append(params, formsParams...)
+	catchAll, initialResponses = detectCatchAll(request, initialResponses)
+	if !replaceExisting {
+		duplicateParams = detectDuplicateParams(request)
+	}
+	if hasQueryCollisions(request) {
+		logger.Info("Candidates named like a URL query parameter are sent with it", "mode", queryCollisions())
+	}
+	if similarityOverride == 0 && !initialResponses.SameBody && !degradedMode && catchAll != catchAllReflects && compared[signalLength] {
+		similarityThreshold = calibrateSimilarity(request, initialResponses)
+	}
+
+	wordlistParams := params
+	formFieldNames = make(map[string]bool)
+	for _, field := range formsParams {
+		formFieldNames[field] = !slices.Contains(wordlistParams, field)
+	}
+	params = validParamNames(appendUnique(params, formsParams))
+	detector.reset()
+	reflected.reset()
+	evidence.reset()
+	titles.reset()
+	routes.reset(initialResponses.Responses)
+	clusters.reset()
+	nearMisses.reset()
+	serverErrors.reset(initialResponses.Responses)
+	responseCache = newRequestCache()
+	atomic.StoreInt64(&savedRequests, 0)
 	validParams := discoverValidParams(request, params, initialResponses, chunkSize)
-	return Results{
-		Params:        validParams,
-		FormParams:    formsParams,
-		TotalRequests: totalRequests,
-		Request:       request,
+	if cacheEnabled {
+		logger.Info("Requests served from cache", "count", responseCache.hits.Load())
+	}
+	var paramPairs [][2]string
+	if pairsEnabled && !scanStopped() {
+		candidates := pairCandidates(formsParams, params, validParams)
+		paramPairs = discoverParamPairs(request, candidates, initialResponses)
+	}
+	if detector.isBlocked() {
+		results := abortedResults(request, "", ErrBlocked, wafAbortReason)
+		results.FormParams = formsParams
+		return results, nil
+	}
+	if evidenceDir != "" {
+		if err := evidence.write(evidenceDir, validParams); err != nil {
+			logger.Warn("Failed to save evidence", "error", err)
+		}
 	}
+	results := Results{
+		Params:              validParams,
+		FormParams:          formsParams,
+		Forms:               forms,
+		TotalRequests:       scanRequests(),
+		SavedRequests:       int(atomic.LoadInt64(&savedRequests)),
+		FailedRequests:      int(failedRequests.Load()),
+		IgnoredResponses:    int(ignoredResponses.Load()),
+		Findings:            buildFindings(request, validParams, wordlistParams, formsParams),
+		ReflectedParams:     reflected.list(),
+		ErrorParams:         serverErrors.filter(validParams),
+		Clusters:            clusters.list(),
+		ParamPairs:          paramPairs,
+		Injection:           injectionPoint(request),
+		Degraded:            degradedMode,
+		CatchAll:            catchAll,
+		DuplicateParams:     duplicateParams,
+		ParamPrefix:         paramPrefix,
+		ParamSuffix:         paramSuffix,
+		HashParams:          fragmentParams,
+		SimilarityThreshold: similarityThreshold,
+		Request:             request,
+	}
+	if tooManyIgnored(results.IgnoredResponses, results.TotalRequests) {
+		logger.Warn("Many responses had an ignored status code, the backend may be too unstable to scan", "ignored", results.IgnoredResponses, "total", results.TotalRequests)
+	}
+	if hasQueryCollisions(request) {
+		results.QueryCollisions = queryCollisions()
+	}
+	if budget.isExceeded() {
+		results.abort(ErrBudgetExceeded, budgetAbortReason)
+	}
+	if onlyReflected {
+		results.AllParams = validParams
+		results.Params = reflected.filter(validParams)
+	}
+	return results, nil
 }
 
 func discoverValidParams(request Request, params []string, initialResponses InitialResponses, chunkSize int) []string {
+	if shuffleParams {
+		params = shuffledParams(params)
+	}
+	if linearScan {
+		return linearFilter(request, params, initialResponses)
+	}
 	parts := chunkParams(params, chunkSize)
 	validParts := filterParts(request, parts, initialResponses)
 
+	// filterParts has drained before any part is narrowed down, so the two
+	// phases never add up their concurrency
 	paramSet := make(map[string]bool)
 	var validParams []string
-	var wg sync.WaitGroup
+	group := newBoundedGroup(recurseConcurrency)
 	var mu sync.Mutex
 
 	for _, part := range validParts {
-		wg.Add(1)
-		go func(part []string) {
-			defer wg.Done()
-			for _, param := range recursiveFilter(request, part, initialResponses) {
+		group.run(func() {
+			for _, param := range filterPart(request, part, initialResponses) {
 				mu.Lock()
 				if !paramSet[param] {
 					paramSet[param] = true
 					validParams = append(validParams, param)
 					logger.Info("Valid parameter discovered", "parameter", param)
+					notifyDiscovered(request, param)
 				}
 				mu.Unlock()
 			}
-		}(part)
+		})
 	}
 
-	wg.Wait()
+	group.wait()
 	return validParams
 }
 
 func filterParts(request Request, parts [][]string, initialResponses InitialResponses) [][]string {
-	var wg sync.WaitGroup
+	group := newBoundedGroup(filterConcurrency)
 	var mu sync.Mutex
 	var validParts [][]string
 
 	for _, part := range parts {
-		wg.Add(1)
-		go func(part []string) {
-			defer wg.Done()
+		group.run(func() {
+			if scanStopped() {
+				return
+			}
 			params := generateParams(part)
-			response := makeRequest(request, params)
+			response := makeRequestRetrying(request, params)
+			if budget.isExceeded() {
+				return
+			}
 
-			if responseChanged(initialResponses.Responses, response, initialResponses.SameBody) {
+			changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+			if detector.observe(response, changed) {
+				return
+			}
+			recordResponse(part, response, changed)
+			if changed {
 				mu.Lock()
-				validParts = append(validParts, part)
+				validParts = append(validParts, splitReflectedPart(part, response, initialResponses)...)
 				mu.Unlock()
 			}
-		}(part)
+		})
 	}
-	wg.Wait()
+	group.wait()
 	return validParts
 }
 
-func recursiveFilter(request Request, params []string, initialResponses InitialResponses) []string {
+// maxRecursionDepth bounds how many times recursiveFilter bisects a chunk. The
+// parameters of a chunk still changing the response at that depth are
+// reported as a cluster instead of single parameters.
+var maxRecursionDepth = 20
+
+// directThreshold is the chunk size at or below which recursiveFilter tests
+// every parameter on its own instead of bisecting. Bisecting a chunk of two
+// already costs a request per parameter, so that is the default.
+var directThreshold = 2
+
+func recursiveFilter(request Request, params []string, initialResponses InitialResponses, depth int) []string {
+	if scanStopped() {
+		return nil
+	}
 	if len(params) == 1 {
 		return params
 	}
+	if len(params) <= directThreshold {
+		return directFilter(request, params, initialResponses)
+	}
+	if depth >= maxRecursionDepth {
+		logger.Warn("Maximum recursion depth reached, reporting the parameters as a cluster to review", "depth", depth, "parameters", params)
+		clusters.add(params)
+		return nil
+	}
 	mid := len(params) / 2
 	left := params[:mid]
 	right := params[mid:]
@@ -176,110 +835,281 @@ func recursiveFilter(request Request, params []string, initialResponses InitialR
 	leftParams := generateParams(left)
 	rightParams := generateParams(right)
 
-	leftResponse := makeRequest(request, leftParams)
-	rightResponse := makeRequest(request, rightParams)
+	leftResponse := makeRequestRetrying(request, leftParams)
+	rightResponse := makeRequestRetrying(request, rightParams)
+	if budget.isExceeded() {
+		return nil
+	}
+
+	leftChanged := responseChanged(initialResponses.Responses, leftResponse, initialResponses.SameBody)
+	rightChanged := responseChanged(initialResponses.Responses, rightResponse, initialResponses.SameBody)
+	if detector.observe(leftResponse, leftChanged) || detector.observe(rightResponse, rightChanged) {
+		return nil
+	}
+	recordResponse(left, leftResponse, leftChanged)
+	recordResponse(right, rightResponse, rightChanged)
 
+	if !leftChanged && !rightChanged {
+		nearMisses.add(params)
+		return nil
+	}
 	var validParams []string
-	if responseChanged(initialResponses.Responses, leftResponse, initialResponses.SameBody) {
-		validParams = append(validParams, recursiveFilter(request, left, initialResponses)...)
+	if leftChanged && changePersists(request, left, initialResponses) {
+		validParams = append(validParams, recursiveFilter(request, left, initialResponses, depth+1)...)
+	}
+	if rightChanged && changePersists(request, right, initialResponses) {
+		validParams = append(validParams, recursiveFilter(request, right, initialResponses, depth+1)...)
+	}
+	return validParams
+}
+
+// directFilter sends a request per parameter of a changed chunk, keeping the
+// ones whose response changed.
+func directFilter(request Request, params []string, initialResponses InitialResponses) []string {
+	var validParams []string
+	for _, param := range params {
+		if scanStopped() {
+			return validParams
+		}
+		part := []string{param}
+		response := makeRequestRetrying(request, generateParams(part))
+		if budget.isExceeded() {
+			return validParams
+		}
+		changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+		if detector.observe(response, changed) {
+			return nil
+		}
+		recordResponse(part, response, changed)
+		if changed {
+			validParams = append(validParams, param)
+		}
 	}
-	if responseChanged(initialResponses.Responses, rightResponse, initialResponses.SameBody) {
-		validParams = append(validParams, recursiveFilter(request, right, initialResponses)...)
+	if len(validParams) == 0 {
+		nearMisses.add(params)
 	}
 	return validParams
 }
 
+// changePersists re-requests a flagged group of parameters before recursing
+// into it. On dynamic pages, where the baselines aren't identical, a single
+// changed response can be jitter, and recursing into both halves on noise
+// multiplies requests and false positives. Single parameters and static
+// pages are trusted without the extra request.
+func changePersists(request Request, params []string, initialResponses InitialResponses) bool {
+	if len(params) == 1 || initialResponses.SameBody {
+		return true
+	}
+	// Bypass the cache, the point is to get a fresh response
+	response := sendRequest(request, generateParams(params))
+	if budget.isExceeded() {
+		return false
+	}
+	changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+	if detector.observe(response, changed) {
+		return false
+	}
+	if !changed {
+		logger.Debug("Discarding parameter group whose change did not persist", "parameters", params)
+	}
+	return changed
+}
+
+func errTargetUnreachable(request Request) error {
+	return fmt.Errorf("%w: every baseline request to %s failed", ErrTargetUnreachable, request.URL)
+}
+
+// baselinesFailed reports whether no baseline request got a response.
+func baselinesFailed(responses []ResponseData) bool {
+	for _, response := range responses {
+		if response.StatusCode != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func makeInitialRequests(request Request) InitialResponses {
 	var baselineResponses []ResponseData
 	for i := 0; i < numBaselines; i++ {
 		resp := makeRequest(request, url.Values{})
 		baselineResponses = append(baselineResponses, resp)
 	}
+	return newInitialResponses(baselineResponses)
+}
+
+// newInitialResponses keeps the stable majority of the baseline responses and
+// determines how candidates should be compared with them.
+func newInitialResponses(baselineResponses []ResponseData) InitialResponses {
+	if compared[signalTiming] {
+		latencyThreshold = newLatencyThreshold(baselineResponses)
+	}
+	stable := stableBaselines(baselineResponses, responsesAreSimilar)
+	if stable == nil {
+		return InitialResponses{
+			Responses:     baselineResponses,
+			SameBody:      false,
+			AreConsistent: false,
+		}
+	}
+	if dropped := len(baselineResponses) - len(stable); dropped > 0 {
+		logger.Warn("Dropping outlier baseline responses", "dropped", dropped, "kept", len(stable))
+	}
 
 	return InitialResponses{
-		Responses:     baselineResponses,
-		SameBody:      baselineResponsesAreConsistent(baselineResponses, responsesAreEqual),
-		AreConsistent: baselineResponsesAreConsistent(baselineResponses, responsesAreSimilar),
+		Responses:     stable,
+		SameBody:      baselineResponsesAreConsistent(stable, responsesAreEqual),
+		AreConsistent: true,
 	}
 }
 
+// makeRequest sends the request with params, reusing the response of an
+// identical request when the cache is enabled. Baselines are never cached.
 func makeRequest(request Request, params url.Values) ResponseData {
+	if !cacheEnabled || len(params) == 0 {
+		return sendRequest(request, params)
+	}
+	return responseCache.get(cacheKey(request, params), func() ResponseData {
+		return sendRequest(request, params)
+	})
+}
+
+func sendRequest(request Request, params url.Values) ResponseData {
 	var req *http.Request
 	var err error
-	totalRequests++
-	parsedURL, err := url.Parse(request.URL)
+	if !budget.spend() {
+		return ResponseData{Err: ErrBudgetExceeded}
+	}
+	totalRequests.Add(1)
+	injection := injectionPoint(request)
+	injected, located := locateParams(params, request.Method)
+	injected = decorateParams(injected)
+	rawURL := request.URL
+	if injection == "url" {
+		rawURL = strings.Replace(rawURL, fuzzMarker, injected.Encode(), 1)
+	}
+	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
-		logger.Error("Failed to parse request URL", "error", err)
-		return ResponseData{}
+		logger.Warn("Failed to parse request URL", "error", err)
+		return ResponseData{Err: err}
 	}
 
-	existingParams := parsedURL.Query()
-	for key, values := range params {
-		for _, value := range values {
-			existingParams.Add(key, value)
+	if injection == "query" || len(located["query"]) > 0 {
+		existingParams := parsedURL.Query()
+		if injection == "query" {
+			addQueryParams(existingParams, injected)
 		}
+		addQueryParams(existingParams, located["query"])
+		parsedURL.RawQuery = existingParams.Encode()
 	}
-	parsedURL.RawQuery = existingParams.Encode()
 	requestURL := parsedURL.String()
-	if request.Method == "GET" {
-		req, err = http.NewRequest(request.Method, requestURL, nil)
+	var contentType string
+	if methodHasBody(request.Method) {
+		var body string
+		body, contentType = buildBody(request, injected, injection, located["body"])
+		req, err = http.NewRequest(request.Method, requestURL, strings.NewReader(body))
 	} else {
-		var body []byte
-		if request.ContentType == "json" {
-			body = []byte(request.Data)
-			req, err = http.NewRequest(request.Method, requestURL, bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-		} else if request.ContentType == "xml" {
-			body = []byte(request.Data)
-			req, err = http.NewRequest(request.Method, requestURL, bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/xml")
-		} else {
-			req, err = http.NewRequest(request.Method, requestURL, strings.NewReader(params.Encode()))
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		}
+		req, err = http.NewRequest(request.Method, requestURL, nil)
+	}
+	if err != nil {
+		logger.Warn("Failed to create request", "error", err)
+		return ResponseData{Err: err}
 	}
 
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if hostOverride != "" {
+		req.Host = hostOverride
+	}
 	req.Header.Set("User-Agent", randomUserAgent())
-	if err != nil {
-		logger.Error("Failed to create request", "error", err)
+	for name, value := range request.Headers {
+		if injection == "header:"+name {
+			value = renderHeaderValue(name, value, injected)
+		}
+		req.Header.Set(name, value)
+	}
+	for name := range located["header"] {
+		req.Header.Set(name, located["header"].Get(name))
+	}
+	for name := range located["cookie"] {
+		req.AddCookie(&http.Cookie{Name: name, Value: located["cookie"].Get(name)})
 	}
 
-	client := createHTTPClient()
-	resp, err := client.Do(req)
+	if httpClient == nil {
+		httpClient = createHTTPClient()
+	}
+	release := limiter.acquire(parsedURL.Host)
+	defer release()
+	requestThrottle.wait()
+	applyAuth(req)
+	generation := tokens.apply(req)
+	var rawRequest []byte
+	if evidenceDir != "" {
+		rawRequest, _ = httputil.DumpRequestOut(req, true)
+	}
+	resp, elapsed, err := timedDo(req)
+	if err == nil && needsDigestRetry(resp) {
+		resp, elapsed, err = retryRequest(req, resp)
+	}
+	if err == nil && needsTokenRefresh(resp, generation) {
+		resp, elapsed, err = retryRequest(req, resp)
+	}
 	if err != nil {
-		logger.Error("Failed to make request", "error", err)
-		return ResponseData{}
+		logger.Warn("Failed to make request", "error", err)
+		return ResponseData{Err: err}
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("Failed to read response body", "error", err)
+	body, truncated, readErr := readResponseBody(resp)
+	if readErr != nil {
+		logger.Warn("Failed to read response body", "error", readErr)
 	}
 
-	reflections := countReflections(params, body)
-	return ResponseData{Body: body, StatusCode: resp.StatusCode, Reflections: reflections}
+	finalURL, redirects := redirectChain(resp)
+	reflectedNames := reflectedParams(params, body)
+	reflected.add(params, reflectedNames, body, resp.Header.Get("Content-Type"))
+	reflections := len(reflectedNames)
+	var reflectedValues []string
+	for _, name := range reflectedNames {
+		reflectedValues = append(reflectedValues, params[name]...)
+	}
+	return ResponseData{Body: body, BodyHash: sha256.Sum256(body), StatusCode: resp.StatusCode, Headers: resp.Header, Reflections: reflections, ReflectedParams: reflectedNames, ReflectedValues: reflectedValues, RawRequest: rawRequest, Elapsed: elapsed, FinalURL: finalURL, Redirects: redirects, Truncated: truncated, Err: readErr}
 }
 
-func saveReport(reportPath string, results Results) {
-	jsonData, err := json.MarshalIndent(results, "", "  ")
-	if err != nil {
-		logger.Error("Error marshalling results to JSON", slog.String("error", err.Error()))
-		return
+// makeRequestRetrying sends the request again, bypassing the cache, when it
+// failed or got an ignored status code, so a transient error doesn't cost the
+// parameters it carried.
+func makeRequestRetrying(request Request, params url.Values) ResponseData {
+	response := makeRequest(request, params)
+	if response.Err != nil && response.Err != ErrBudgetExceeded {
+		logger.Warn("Retrying failed request", "parameters", len(params), "error", response.Err)
+		response = sendRequest(request, params)
+	} else if isIgnoredStatus(response) {
+		logger.Debug("Retrying response with an ignored status code", "parameters", len(params), "status", response.StatusCode)
+		response = sendRequest(request, params)
 	}
+	return response
+}
 
-	file, err := os.Create(reportPath)
-	if err != nil {
-		logger.Error("Error creating/opening report file", slog.String("error", err.Error()), slog.String("path", reportPath))
-		return
-	}
-	defer file.Close()
-
-	_, err = file.Write(jsonData)
-	if err != nil {
-		logger.Error("Error writing JSON to file", slog.String("error", err.Error()), slog.String("path", reportPath))
-		return
+// retryRequest discards resp and sends req again with refreshed credentials.
+func retryRequest(req *http.Request, resp *http.Response) (*http.Response, time.Duration, error) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retry.Body, _ = req.GetBody()
 	}
+	applyAuth(retry)
+	tokens.apply(retry)
+	totalRequests.Add(1)
+	return timedDo(retry)
+}
 
-	logger.Info("Report saved successfully", slog.String("path", reportPath))
+// timedDo sends req, returning how long its response took to arrive, up to
+// its headers.
+func timedDo(req *http.Request) (*http.Response, time.Duration, error) {
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	return resp, time.Since(start), err
 }
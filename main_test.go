@@ -1,12 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"log/slog"
+	"math/big"
 	"math/rand"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -21,6 +45,26 @@ var hiddenParams = map[string]string{
 }
 
 var serverOnce sync.Once
+
+const aboutPage = `<html><head><title>About</title></head><body><h1>About us</h1></body></html>`
+
+var wafRequests atomic.Int32
+var flakyRequests atomic.Int32
+var sessionRequests atomic.Int32
+var duplicateQueries atomic.Int32
+
+// flakyChunks records the parameter sets /flaky-chunks has already failed once.
+var flakyChunks = struct {
+	sync.Mutex
+	seen map[string]bool
+}{seen: map[string]bool{}}
+
+// oauthState issues bearer tokens that expire after a few uses.
+var oauthState struct {
+	sync.Mutex
+	issued int
+	uses   int
+}
 var wg sync.WaitGroup
 var loremIpsum = "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Donec blandit quam quis odio interdum, ac bibendum elit tincidunt. Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vestibulum nec justo a lacus egestas tincidunt. Curabitur nec nisi laoreet enim tempus vulputate ut et felis. Fusce hendrerit urna lacus, sit amet auctor metus varius id. Curabitur luctus sem vitae ante dapibus ornare. Maecenas dignissim ultrices odio a viverra. Donec fermentum risus ac rutrum fermentum. Pellentesque eu quam iaculis, imperdiet sem ac, posuere dui. Suspendisse consequat dolor nisi, eu semper ligula porttitor ut. Nulla tempus eros erat, ut facilisis enim eleifend non. Praesent accumsan metus est, sed gravida purus placerat in. Curabitur et faucibus arcu. Proin velit urna, vehicula id lacus non, luctus semper diam. Ut porttitor mollis elit, et auctor felis.\nMorbi consequat malesuada mi quis bibendum. Curabitur sed arcu eros. Donec id nunc enim. Sed blandit libero sed sodales viverra. Aenean viverra vitae metus nec finibus. Pellentesque viverra pretium turpis, quis feugiat lacus. Cras aliquet eros augue, at dignissim orci accumsan nec. Pellentesque arcu orci, scelerisque eu congue non, aliquet sit amet elit. Cras pretium metus efficitur velit fringilla, id maximus mi euismod."
 
@@ -98,9 +142,521 @@ func mockServer() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
+	http.HandleFunc("/waf", func(w http.ResponseWriter, r *http.Request) {
+		// Serve the normal page for the baselines and the first few candidates, then block everything
+		if wafRequests.Add(1) > int32(numBaselines+2) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<html><body><h1>Access Denied</h1><p>Your request has been blocked.</p></body></html>`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`<html><body><h1>Normal</h1></body></html>`))
+	})
+	http.HandleFunc("/flaky-baseline", func(w http.ResponseWriter, r *http.Request) {
+		// The second request fails transiently, every other one behaves like the root page
+		if flakyRequests.Add(1) == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`<html><body><h1>Internal Server Error</h1></body></html>`))
+			return
+		}
+		response := `<html><body><h1>Normal</h1></body></html>`
+		for key, value := range hiddenParams {
+			if r.URL.Query().Get(key) != "" {
+				response = `<html><body><h1>Hidden Parameter Detected</h1>` + value + `</body></html>`
+				break
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/pair", func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Normal</h1></body></html>`
+		if r.URL.Query().Get("action") != "" && r.URL.Query().Get("id") != "" {
+			response = `<html><body><h1>Action performed</h1><p>The requested action was executed on the item.</p></body></html>`
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/template", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			User  string            `json:"user"`
+			Extra map[string]string `json:"extra"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid body"}`))
+			return
+		}
+		response := `{"user":"` + payload.User + `","role":"guest"}`
+		if _, ok := payload.Extra["debug"]; ok {
+			response = `{"user":"` + payload.User + `","role":"guest","debug":{"queries":12,"cache":"miss","node":"app-3"}}`
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/resource", func(w http.ResponseWriter, r *http.Request) {
+		response := `{"id":7,"name":"guest"}`
+		switch r.Method {
+		case "PATCH":
+			var payload map[string]any
+			if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid body"}`))
+				return
+			}
+			if _, ok := payload["role"]; ok {
+				response = `{"id":7,"name":"guest","role":"admin","permissions":["read","write","delete"]}`
+			}
+		case "DELETE":
+			body, _ := io.ReadAll(r.Body)
+			values, err := url.ParseQuery(string(body))
+			if err != nil || values.Get("id") != "7" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"missing id"}`))
+				return
+			}
+			if values.Get("force") != "" {
+				response = `{"id":7,"deleted":true,"cascade":["sessions","tokens","uploads"]}`
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Normal</h1></body></html>`
+		if values, err := url.ParseQuery(r.Header.Get("X-Params")); err == nil && values.Get("debug") != "" {
+			response = `<html><body><h1>Debug</h1><pre>Request handled by node app-3 in 12ms</pre></body></html>`
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/form", func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Search</h1><form><input name="page" value="1"><input name="test"/></form></body></html>`
+		if page := r.URL.Query().Get("page"); page != "" {
+			response = `<html><body><h1>Results page</h1><p>No results found on this page.</p></body></html>`
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/reflect", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		response := `<html><body><h1>Search</h1><p>You searched for: ` + html.EscapeString(query.Get("q")) + `</p>`
+		if lang := query.Get("lang"); lang != "" {
+			response += `<p>Language: ` + html.EscapeString(lang) + `</p>`
+		}
+		if query.Get("admin") != "" {
+			response += `<div>Administration menu</div><ul><li>Users</li><li>Settings</li><li>Logs</li></ul>`
+		}
+		response += `</body></html>`
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/catch-all", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var filters []string
+		for _, values := range query {
+			filters = append(filters, html.EscapeString(values[0]))
+		}
+		sort.Strings(filters)
+		response := `<html><head><title>Product catalog</title></head><body><h1>Products</h1><p>Showing all products matching: ` + strings.Join(filters, " ") + `</p><ul><li>Keyboard</li><li>Mouse</li><li>Monitor</li><li>Headphones</li><li>Webcam</li><li>Microphone</li><li>Speakers</li><li>Laptop stand</li></ul>`
+		if query.Get("id") != "" {
+			response += `<div>Product details</div><table><tr><td>Price</td><td>49.99</td></tr><tr><td>Stock</td><td>12</td></tr></table>`
+		}
+		response += `</body></html>`
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	})
+	authenticated := func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Welcome back, admin</h1></body></html>`
+		for key, value := range hiddenParams {
+			if r.URL.Query().Get(key) != "" {
+				response = `<html><body><h1>Hidden Parameter Detected</h1>` + value + `</body></html>`
+				break
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	}
+	http.HandleFunc("/basic", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`<html><body><h1>Login required</h1></body></html>`))
+			return
+		}
+		authenticated(w, r)
+	})
+	http.HandleFunc("/digest", func(w http.ResponseWriter, r *http.Request) {
+		if !validDigest(r, "admin", "secret") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", nonce="dcd98b7102dd2f0e", qop="auth", opaque="5ccc069c"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`<html><body><h1>Login required</h1></body></html>`))
+			return
+		}
+		authenticated(w, r)
+	})
+	http.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		oauthState.Lock()
+		oauthState.issued++
+		oauthState.uses = 0
+		token := "token-" + strconv.Itoa(oauthState.issued)
+		oauthState.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"` + token + `","expires_in":1}`))
+	})
+	http.HandleFunc("/oauth", func(w http.ResponseWriter, r *http.Request) {
+		oauthState.Lock()
+		valid := oauthState.issued > 0 && r.Header.Get("Authorization") == "Bearer token-"+strconv.Itoa(oauthState.issued) && oauthState.uses < 8
+		if valid {
+			oauthState.uses++
+		}
+		oauthState.Unlock()
+		if !valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_token"}`))
+			return
+		}
+		authenticated(w, r)
+	})
+	http.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/users/")
+		if name == "admin" || name == "root" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`<html><body><h1>Profile</h1><p>Privileged account with full access to the system.</p></body></html>`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<html><body><h1>Not found</h1><p>User ` + html.EscapeString(name) + ` does not exist</p></body></html>`))
+	})
+	http.HandleFunc("/vhost", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><h1>` + html.EscapeString(r.Host) + `</h1></body></html>`))
+	})
+	http.HandleFunc("/hints", func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Normal</h1></body></html>`
+		if cookie, err := r.Cookie("role"); err == nil && cookie.Value == "admin" {
+			response = `<html><body><h1>Administration</h1><p>Signed in with elevated privileges.</p></body></html>`
+		} else if r.Header.Get("X-Debug") != "" {
+			response = `<html><body><h1>Debug</h1><pre>Request handled by node app-3 in 12ms</pre></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		// Every request without the session cookie is greeted as a new visitor
+		if cookie, err := r.Cookie("sid"); err != nil || cookie.Value != "s3ss10n" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s3ss10n"})
+			greetings := []string{
+				`<html><body><h1>Welcome</h1><p>First time here? Have a look around.</p></body></html>`,
+				`<html><body><div class="banner"><h2>New visitor offer</h2><ul><li>Free shipping</li><li>10% off</li></ul></div></body></html>`,
+				`<html><body><table><tr><td>Tour</td><td>Step 1 of 5: create an account to save your preferences</td></tr></table></body></html>`,
+			}
+			w.Write([]byte(greetings[sessionRequests.Add(1)%3]))
+			return
+		}
+		response := `<html><body><h1>Dashboard</h1></body></html>`
+		if r.URL.Query().Get("debug") != "" {
+			response = `<html><body><h1>Debug</h1><pre>Session state dumped by node app-3</pre></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/flaky-chunks", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		names := make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		key := strings.Join(names, "&")
+
+		// The first request for every set of parameters is cut off mid-body
+		flakyChunks.Lock()
+		seen := flakyChunks.seen[key]
+		flakyChunks.seen[key] = true
+		flakyChunks.Unlock()
+		if len(names) > 0 && !seen {
+			w.Header().Set("Content-Length", "1000")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`<html><body><h1>Err`))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+
+		response := `<html><body><h1>Normal</h1></body></html>`
+		if query.Get("debug") != "" {
+			response = `<html><body><h1>Debug</h1><pre>Request handled by node app-3 in 12ms</pre></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/crash", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("id") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`<html><body><h1>Internal Server Error</h1><pre>Traceback (most recent call last): ValueError</pre></body></html>`))
+			return
+		}
+		response := `<html><body><h1>Normal</h1></body></html>`
+		if query.Get("debug") != "" {
+			response = `<html><body><h1>Debug</h1><pre>Request handled by node app-3 in 12ms</pre></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	// Both show the debug value they honour, the first or the last given
+	http.HandleFunc("/first-wins", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body><p>debug: %s</p></body></html>", r.URL.Query().Get("debug"))
+	})
+	http.HandleFunc("/last-wins", func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()["debug"]
+		fmt.Fprintf(w, "<html><body><p>debug: %s</p></body></html>", values[len(values)-1])
+	})
+	http.HandleFunc("/strict-query", func(w http.ResponseWriter, r *http.Request) {
+		for _, values := range r.URL.Query() {
+			if len(values) > 1 {
+				duplicateQueries.Add(1)
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("<html><body><p>Repeated parameter</p></body></html>"))
+				return
+			}
+		}
+		fmt.Fprintf(w, "<html><body><p>debug: %s</p></body></html>", r.URL.Query().Get("debug"))
+	})
+	// Only answers differently to format=json, any other value is ignored
+	http.HandleFunc("/format", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"format":"json","items":[]}`))
+			return
+		}
+		w.Write([]byte(`<html><body><ul class="items"></ul><p>Also available as json</p></body></html>`))
+	})
+	http.HandleFunc("/naming", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("user_id") != "" {
+			w.Write([]byte("<html><body><h1>Profile</h1><p>Viewing another user's profile</p></body></html>"))
+			return
+		}
+		w.Write([]byte("<html><body><h1>Profile</h1></body></html>"))
+	})
+	http.HandleFunc("/login-page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>
+<form action="/login" method="post"><input name="username"><input type="password" name="password"><input type="hidden" name="csrf" value="x"></form>
+<form action="https://search.example/find#results"><input name="q"></form>
+<form><input name="lang"></form>
+</body></html>`))
+	})
+	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		switch {
+		case r.PostForm.Get("username") != "":
+			w.Write([]byte("<html><body><p>Invalid password for this account</p></body></html>"))
+		case r.PostForm.Get("remember") != "":
+			w.Write([]byte("<html><body><p>Session will be remembered</p></body></html>"))
+		default:
+			w.Write([]byte("<html><body><p>Please log in</p></body></html>"))
+		}
+	})
+	// Impersonating with "as" only flips a field of a long JSON document
+	http.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		account := map[string]any{
+			"id":          42,
+			"request_id":  rand.Intn(1000000),
+			"role":        "user",
+			"description": strings.Repeat("Account settings and preferences of the current user. ", 20),
+		}
+		if r.URL.Query().Get("as") != "" {
+			account["role"] = "admin"
+		}
+		if r.URL.Query().Get("verbose") != "" {
+			account["history"] = strings.Repeat("Signed in from a new device. ", 40)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(account)
+	})
+	http.HandleFunc("/strict-names", func(w http.ResponseWriter, r *http.Request) {
+		for name := range r.URL.Query() {
+			if len(name) > 64 || strings.ContainsAny(name, "<> ") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("<html><body><h1>Bad Request</h1></body></html>"))
+				return
+			}
+		}
+		response := "<html><body><h1>Welcome</h1></body></html>"
+		if r.URL.Query().Get("debug") != "" {
+			response = "<html><body><h1>Welcome</h1><pre>debug enabled</pre></body></html>"
+		}
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj << /Type /Catalog >> endobj\n%%EOF"))
+	})
+	// The backend behind lb is down, whatever parameter the request carries
+	http.HandleFunc("/unstable-backend", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Has("lb") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`<html><body><h1>Service Unavailable</h1></body></html>`))
+			return
+		}
+		fmt.Fprintf(w, "<html><body><h1>Item %s</h1></body></html>", query.Get("id"))
+	})
+	http.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil || r.PostFormValue("id") != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<html><body><h1>Bad Request</h1></body></html>`))
+			return
+		}
+		response := `<html><body><h1>Upload 7</h1></body></html>`
+		if r.PostFormValue("debug") != "" {
+			response = `<html><body><h1>Upload 7</h1><pre>storage=s3 bucket=uploads-internal</pre></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	http.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		w.Header().Set("X-Powered-By", "PHP/8.2.12")
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "s3cr3t"})
+		response := aboutPage
+		if r.URL.Query().Get("lang") != "" {
+			response = strings.Replace(response, "About us", "Qui sommes-nous", 1)
+		}
+		w.Write([]byte(response))
+	})
+	// Rails style, only the filter[...] parameters are read
+	http.HandleFunc("/articles", func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Articles</h1><ul><li>Hello world</li><li>Second post</li></ul></body></html>`
+		if r.URL.Query().Get("filter[draft]") != "" {
+			response = `<html><body><h1>Articles</h1><ul><li>Unpublished: roadmap 2025</li></ul></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	// Only array input changes the listing: repeated ids, tag[] or a comma separated status
+	http.HandleFunc("/bulk", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		response := `<html><body><h1>Orders</h1><p>Showing the latest order</p></body></html>`
+		switch {
+		case len(query["id"]) > 1:
+			response = `<html><body><h1>Orders</h1><table><tr><td>#1</td><td>#2</td></tr></table></body></html>`
+		case query.Has("tag[]"):
+			response = `<html><body><h1>Orders</h1><p>Filtered by tags</p><ul><li>urgent</li></ul></body></html>`
+		case strings.Contains(query.Get("status"), ","):
+			response = `<html><body><h1>Orders</h1><p>Status: shipped or pending</p><ol><li>#7</li></ol></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	// Redirect shims sending the browser to the application at /
+	http.HandleFunc("/meta-shim", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><meta http-equiv="Refresh" content="0; URL='/'"></head><body>Redirecting...</body></html>`))
+	})
+	http.HandleFunc("/js-shim", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><script>window.location.replace("/");</script></body></html>`))
+	})
+	// Lists its methods on OPTIONS and refuses those it doesn't allow
+	http.HandleFunc("/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		switch r.Method {
+		case "OPTIONS":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "GET", "POST":
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		response := `<html><body><h1>Comments</h1><p>Newest first</p></body></html>`
+		if r.FormValue("sort") != "" {
+			response = `<html><body><h1>Comments</h1><p>Oldest first</p><ul><li>First!</li></ul></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	// Moved to /, and /go sends the next parameter to / as well
+	http.HandleFunc("/old-home", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusMovedPermanently)
+	})
+	http.HandleFunc("/go", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("next") {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Write([]byte(`<html><body><h1>Leaving the site</h1><p>Pick a destination</p></body></html>`))
+	})
+	// The store keeps the query across its redirect and only reacts to category
+	http.HandleFunc("/shop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/store?"+r.URL.RawQuery, http.StatusMovedPermanently)
+	})
+	http.HandleFunc("/store", func(w http.ResponseWriter, r *http.Request) {
+		response := `<html><body><h1>Store</h1><p>All products</p></body></html>`
+		if r.URL.Query().Has("category") {
+			response = `<html><body><h1>Store</h1><p>Products of the category</p><ul><li>Lamp</li></ul></body></html>`
+		}
+		w.Write([]byte(response))
+	})
+	// Access is granted with debug, admin adds a greeting and sort reorders the page
+	http.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		status, greeting, items := "Access Denied", "", "<li>Orders</li><li>Invoices</li><li>Settings</li>"
+		if query.Has("debug") {
+			status = "Access granted"
+		}
+		if query.Has("admin") {
+			greeting = "<p>Welcome, administrator</p>"
+		}
+		if query.Has("sort") {
+			items = "<table><tr><td>Settings</td></tr><tr><td>Invoices</td></tr><tr><td>Orders</td></tr></table>"
+		}
+		w.Write([]byte("<html><body><h1>Dashboard</h1><p>" + status + "</p>" + greeting + "<ul>" + items + "</ul></body></html>"))
+	})
+	// A single-page app reading its view from the URL fragment
+	http.HandleFunc("/spa", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><div id="app"></div><script>const hash = new URLSearchParams(location.hash.slice(1)); render(hash.get("view"), hash.get("item"));</script></body></html>`))
+	})
+	// The same page, only slower when the wait parameter is sent
+	http.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("wait") {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(fmt.Sprintf(`<html><body><h1>Lookup</h1><p>Served at %d</p></body></html>`, time.Now().UnixNano())))
+	})
+	http.HandleFunc("/always-changes", func(w http.ResponseWriter, r *http.Request) {
+		// Every candidate changes the page, so every chunk is narrowed down to its end
+		for key := range r.URL.Query() {
+			if strings.HasPrefix(key, "param") {
+				w.Write([]byte(`<html><body><h1>Changed</h1></body></html>`))
+				return
+			}
+		}
+		w.Write([]byte(`<html><body><h1>Normal</h1></body></html>`))
+	})
 	wg.Done()
 	log.Fatal(http.ListenAndServe(":8181", nil))
 }
+
+// validDigest verifies a qop=auth MD5 digest Authorization header.
+func validDigest(r *http.Request, user, pass string) bool {
+	scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if scheme != "Digest" {
+		return false
+	}
+	params := map[string]string{}
+	for _, part := range splitChallenge(rest) {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[key] = strings.Trim(value, `"`)
+	}
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := md5Hex(user + ":test:" + pass)
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	expected := md5Hex(strings.Join([]string{ha1, "dcd98b7102dd2f0e", params["nc"], params["cnonce"], "auth", ha2}, ":"))
+	return params["username"] == user && params["uri"] == r.URL.RequestURI() && params["response"] == expected
+}
+
 func startMockServer() {
 	serverOnce.Do(func() {
 		wg.Add(1)
@@ -131,7 +687,10 @@ func TestDiscoverParams(t *testing.T) {
 		ContentType: "",
 	}
 
-	results := DiscoverParams(request, params, 5)
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
 
 	expectedParams := []string{"page", "query", "session", "user", "token", "mode"}
 
@@ -152,6 +711,142 @@ func TestDiscoverParams(t *testing.T) {
 	}
 }
 
+func TestDiscoverParamsDirectThreshold(t *testing.T) {
+	startMockServer()
+	defer func(previous int) { directThreshold = previous }(directThreshold)
+
+	params := []string{"param1", "param2", "page", "query", "random1", "session", "user", "random2", "token", "mode", "random3", "player"}
+	request := Request{
+		URL:    "http://localhost:8181",
+		Method: "GET",
+	}
+
+	found := make(map[int][]string)
+	for _, threshold := range []int{0, 3, 12} {
+		directThreshold = threshold
+		results, err := DiscoverParams(request, params, 12)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		sort.Strings(results.Params)
+		found[threshold] = results.Params
+	}
+	if expected := []string{"mode", "page", "query", "session", "token", "user"}; !reflect.DeepEqual(found[0], expected) {
+		t.Fatalf("Expected bisection to find %v, got %v", expected, found[0])
+	}
+	for _, threshold := range []int{3, 12} {
+		if !reflect.DeepEqual(found[threshold], found[0]) {
+			t.Errorf("Expected direct testing below %d to find %v like bisection, got %v", threshold, found[0], found[threshold])
+		}
+	}
+}
+
+func TestDiscoverParamsFindingHeadings(t *testing.T) {
+	startMockServer()
+
+	request := Request{
+		URL:    "http://localhost:8181",
+		Method: "GET",
+	}
+	results, err := DiscoverParams(request, []string{"param1", "page", "random1", "session", "random2"}, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Findings) != 2 {
+		t.Fatalf("Expected two findings, got: %+v", results.Findings)
+	}
+	for _, finding := range results.Findings {
+		if finding.Heading != "Hidden Parameter Detected" || finding.Title != "" {
+			t.Errorf("Expected the heading of the page %s leads to, got title %q and heading %q", finding.Name, finding.Title, finding.Heading)
+		}
+	}
+
+	heading := extractHeading([]byte("<html><head><title>\n  Admin   Panel\n</title></head><body><h1>Users</h1><h1>Roles</h1></body></html>"))
+	if heading.Title != "Admin Panel" || heading.Heading != "Users" {
+		t.Errorf("Expected the normalized title and first heading, got %+v", heading)
+	}
+}
+
+func TestDiscoverParamsNoForms(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { skipForms = previous }(skipForms)
+	skipForms = true
+
+	params := []string{"param1", "page", "random1"}
+
+	request := Request{
+		URL:         "http://localhost:8181",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if len(results.FormParams) != 0 {
+		t.Errorf("Expected no form parameters when extraction is disabled, got: %v", results.FormParams)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "page" {
+		t.Errorf("Expected only page to be discovered, got: %v", results.Params)
+	}
+}
+
+func TestSeedReproducesGeneratedParams(t *testing.T) {
+	defer func(seed int64) { randomSeed = seed; resetRandom() }(randomSeed)
+	names := []string{"param1", "param2", "param3", "param4"}
+	generate := func(seed int64) (url.Values, string) {
+		randomSeed = seed
+		resetRandom()
+		return generateParams(names), randomUserAgent()
+	}
+
+	params, userAgent := generate(42)
+	again, againUserAgent := generate(42)
+	if !reflect.DeepEqual(params, again) || userAgent != againUserAgent {
+		t.Errorf("Expected the same seed to generate the same values, got %v and %v", params, again)
+	}
+	if other, _ := generate(43); reflect.DeepEqual(params, other) {
+		t.Errorf("Expected another seed to generate other values, got %v twice", params)
+	}
+}
+
+func TestDiscoverParamsShuffle(t *testing.T) {
+	startMockServer()
+	defer func(shuffle bool, seed int64) { shuffleParams, randomSeed = shuffle, seed }(shuffleParams, randomSeed)
+	shuffleParams = true
+	randomSeed = 42
+
+	params := []string{"param1", "page", "param2", "query", "param3", "session", "param4", "user", "param5", "token", "param6", "mode"}
+	shuffled := shuffledParams(params)
+	if !reflect.DeepEqual(shuffled, shuffledParams(params)) {
+		t.Errorf("Expected the same seed to give the same order")
+	}
+	if reflect.DeepEqual(shuffled, params) || params[0] != "param1" {
+		t.Errorf("Expected a shuffled copy leaving the original order untouched, got: %v", shuffled)
+	}
+
+	request := Request{
+		URL:    "http://localhost:8181",
+		Method: "GET",
+	}
+
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	for _, param := range []string{"page", "query", "session", "user", "token", "mode"} {
+		if !contains(results.Params, param) {
+			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
+		}
+	}
+	if contains(results.Params, "param1") {
+		t.Errorf("Reported parameter 'param1' which does not modify the content as valid")
+	}
+}
+
 func TestDiscoverParamsDynamic(t *testing.T) {
 	startMockServer()
 
@@ -164,7 +859,10 @@ func TestDiscoverParamsDynamic(t *testing.T) {
 		ContentType: "",
 	}
 
-	results := DiscoverParams(request, params, 5)
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
 
 	expectedParams := []string{"page", "query", "session", "user", "token", "mode"}
 
@@ -185,57 +883,2335 @@ func TestDiscoverParamsDynamic(t *testing.T) {
 	}
 }
 
-func TestDiscoverParamsDynamicReflected(t *testing.T) {
+func TestDiscoverParamsSimilarityCalibration(t *testing.T) {
 	startMockServer()
 
-	params := []string{"param1", "param2", "param3", "param4", "param5", "param6", "page", "query", "session", "user", "token", "mode", "random1", "random2", "random3", "random4", "random5", "player", "team", "score"}
+	params := []string{"param1", "page", "random1", "user"}
 
 	request := Request{
-		URL:         "http://localhost:8181/dynamic-reflected",
+		URL:         "http://localhost:8181/dynamic",
 		Method:      "GET",
 		Data:        "",
 		ContentType: "",
 	}
 
-	results := DiscoverParams(request, params, 5)
-
-	expectedParams := []string{"page", "query", "session", "user", "token", "mode"}
-	for _, param := range expectedParams {
-		if !contains(results.Params, param) {
-			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
-		}
+	results, err := DiscoverParams(request, params, 4)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
 	}
-
-	if contains(results.Params, "random1") {
-		t.Errorf("Reported form parameter 'random1' which does not modify the content as valid")
+	if results.SimilarityThreshold < minCalibratedThreshold || results.SimilarityThreshold > maxCalibratedThreshold {
+		t.Errorf("Expected a calibrated similarity threshold, got: %v", results.SimilarityThreshold)
 	}
-	if contains(results.Params, "param6") {
-		t.Errorf("Reported form parameter 'param6' which does not modify the content as valid")
+	if len(results.Params) != 2 || !contains(results.Params, "page") || !contains(results.Params, "user") {
+		t.Errorf("Expected the page and user parameters, got: %v", results.Params)
 	}
-	if contains(results.Params, "team") {
-		t.Errorf("Reported form parameter 'team' which does not modify the content as valid")
+
+	defer func(previous float64) { similarityOverride = previous }(similarityOverride)
+	similarityOverride = 0.8
+	results, err = DiscoverParams(request, params, 4)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.SimilarityThreshold != 0.8 {
+		t.Errorf("Expected -similarity to override the calibrated threshold, got: %v", results.SimilarityThreshold)
 	}
 }
 
-func TestDiscoverParamsDynamicUnstable(t *testing.T) {
+func TestDiscoverParamsDynamicDOMDiff(t *testing.T) {
 	startMockServer()
+	defer func(previous string) { diffAlgo = previous }(diffAlgo)
+	diffAlgo = "dom"
 
-	params := []string{"param1", "param2", "param3", "random1", "random2", "user", "token"}
+	params := []string{"param1", "param2", "param3", "param4", "param5", "param6", "page", "query", "session", "user", "token", "mode", "random1", "random2", "random3", "random4", "random5", "player", "team", "score"}
 
 	request := Request{
-		URL:         "http://localhost:8181/unstable",
+		URL:         "http://localhost:8181/dynamic",
 		Method:      "GET",
 		Data:        "",
 		ContentType: "",
 	}
 
-	results := DiscoverParams(request, params, 5)
-
-	if !results.Aborted {
-		t.Errorf("Expected the scan to be aborted due to inconsistent responses, but it was not.")
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
 	}
 
-	if len(results.Params) > 0 || len(results.FormParams) > 0 {
-		t.Errorf("Expected no parameters to be reported since the scan was aborted, but found Params: %v, FormParams: %v", results.Params, results.FormParams)
+	expectedParams := []string{"page", "query", "session", "user", "token", "mode"}
+	for _, param := range expectedParams {
+		if !contains(results.Params, param) {
+			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
+		}
+	}
+	if len(results.Params) != len(expectedParams) {
+		t.Errorf("Expected exactly %d parameters, got: %v", len(expectedParams), results.Params)
+	}
+}
+
+func TestDiscoverParamsDynamicReflected(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "param3", "param4", "param5", "param6", "page", "query", "session", "user", "token", "mode", "random1", "random2", "random3", "random4", "random5", "player", "team", "score"}
+
+	request := Request{
+		URL:         "http://localhost:8181/dynamic-reflected",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	expectedParams := []string{"page", "query", "session", "user", "token", "mode"}
+	for _, param := range expectedParams {
+		if !contains(results.Params, param) {
+			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
+		}
+	}
+
+	if contains(results.Params, "random1") {
+		t.Errorf("Reported form parameter 'random1' which does not modify the content as valid")
+	}
+	if contains(results.Params, "param6") {
+		t.Errorf("Reported form parameter 'param6' which does not modify the content as valid")
+	}
+	if contains(results.Params, "team") {
+		t.Errorf("Reported form parameter 'team' which does not modify the content as valid")
+	}
+}
+
+func TestChangePersistsOnDynamicPage(t *testing.T) {
+	startMockServer()
+	request := Request{URL: "http://localhost:8181/dynamic-reflected", Method: "GET"}
+	initialResponses := makeInitialRequests(request)
+	if initialResponses.SameBody {
+		t.Fatalf("Expected the baselines of the dynamic page to differ")
+	}
+
+	if !changePersists(request, []string{"random1", "page", "random2"}, initialResponses) {
+		t.Errorf("Expected a group with a hidden parameter to keep changing the response")
+	}
+	if changePersists(request, []string{"random1", "random2", "random3"}, initialResponses) {
+		t.Errorf("Expected a group of unknown parameters to be discarded as jitter")
+	}
+	sent := totalRequests.Load()
+	if !changePersists(request, []string{"random1"}, initialResponses) || totalRequests.Load() != sent {
+		t.Errorf("Expected a single parameter to be trusted without another request")
+	}
+}
+
+func TestDiscoverParamsDynamicUnstable(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "param3", "random1", "random2", "user", "token"}
+
+	request := Request{
+		URL:         "http://localhost:8181/unstable",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if !results.Aborted {
+		t.Errorf("Expected the scan to be aborted due to inconsistent responses, but it was not.")
+	}
+	if err := results.Err(); !errors.Is(err, ErrInconsistentBaselines) {
+		t.Errorf("Expected ErrInconsistentBaselines, got %v", err)
+	}
+	if pairs := numBaselines * (numBaselines - 1) / 2; len(results.BaselineComparisons) != pairs {
+		t.Errorf("Expected the %d pairs of baselines to be compared, got: %+v", pairs, results.BaselineComparisons)
+	}
+	if !strings.HasPrefix(results.AbortReason, "Baseline responses differ significantly: lowest similarity ") {
+		t.Errorf("Expected the abort reason to give the lowest similarity, got %q", results.AbortReason)
+	}
+
+	if len(results.Params) > 0 || len(results.FormParams) > 0 {
+		t.Errorf("Expected no parameters to be reported since the scan was aborted, but found Params: %v, FormParams: %v", results.Params, results.FormParams)
+	}
+}
+
+func TestDiscoverParamsDynamicUnstableForced(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { forceScan = previous }(forceScan)
+	forceScan = true
+
+	params := []string{"param1", "param2", "param3", "random1", "random2", "user", "token"}
+
+	request := Request{
+		URL:         "http://localhost:8181/unstable",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if results.Aborted {
+		t.Errorf("Expected the forced scan not to be aborted, but it was: %s", results.AbortReason)
+	}
+	if !results.Degraded {
+		t.Errorf("Expected the forced scan to be reported as degraded")
+	}
+	if len(results.Params) > 0 {
+		t.Errorf("Expected no parameters on a page that ignores them, but found: %v", results.Params)
+	}
+}
+
+func TestDiscoverParamsWAFBlock(t *testing.T) {
+	startMockServer()
+	wafRequests.Store(0)
+
+	var params []string
+	for i := 0; i < 100; i++ {
+		params = append(params, "param"+strconv.Itoa(i))
+	}
+
+	request := Request{
+		URL:         "http://localhost:8181/waf",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 2)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if !results.Aborted || results.AbortReason != "possible WAF block" {
+		t.Errorf("Expected the scan to be aborted as a possible WAF block, got aborted=%v reason=%q", results.Aborted, results.AbortReason)
+	}
+	if err := results.Err(); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected ErrBlocked, got %v", err)
+	}
+
+	if len(results.Params) > 0 {
+		t.Errorf("Expected no parameters to be reported when blocked, but found: %v", results.Params)
+	}
+}
+
+func TestDiscoverParamsRequestBudget(t *testing.T) {
+	startMockServer()
+	defer func(threshold int) { wafThreshold, maxRequests = threshold, 0 }(wafThreshold)
+	wafThreshold = 0
+	maxRequests = 50
+
+	var params []string
+	for i := 0; i < 100; i++ {
+		params = append(params, "param"+strconv.Itoa(i))
+	}
+
+	request := Request{
+		URL:    "http://localhost:8181/always-changes",
+		Method: "GET",
+	}
+
+	startRequests := totalRequests.Load()
+	results, err := DiscoverParams(request, params, 10)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if !results.Aborted || results.AbortReason != "request budget exceeded" {
+		t.Errorf("Expected the scan to be aborted by the request budget, got aborted=%v reason=%q", results.Aborted, results.AbortReason)
+	}
+	if err := results.Err(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if sent := totalRequests.Load() - startRequests; sent > int64(maxRequests) {
+		t.Errorf("Expected at most %d requests, got %d", maxRequests, sent)
+	}
+	if len(results.Params) >= len(params) {
+		t.Errorf("Expected the budget to stop the scan before every parameter was narrowed down, got %d", len(results.Params))
+	}
+}
+
+func TestDiscoverParamsReuseCookies(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { reuseCookies, httpClient = previous, nil }(reuseCookies)
+
+	params := []string{"param1", "debug", "random1", "random2"}
+	request := Request{
+		URL:    "http://localhost:8181/session",
+		Method: "GET",
+	}
+
+	reuseCookies = false
+	results, err := DiscoverParams(request, params, 2)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !results.Aborted {
+		t.Errorf("Expected the scan to be skipped when every request starts a new session")
+	}
+
+	reuseCookies = true
+	results, err = DiscoverParams(request, params, 2)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.Aborted || len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected debug to be discovered within the session, got aborted=%v params=%v", results.Aborted, results.Params)
+	}
+
+	// Two baselines leave no outlier to drop if the first one started the session
+	defer func(mode string, baselines int) { scanMode, numBaselines = mode, baselines }(scanMode, numBaselines)
+	scanMode, numBaselines = "path", 2
+	results, err = DiscoverParams(Request{URL: "http://localhost:8181/session?debug=FUZZ", Method: "GET"}, params, 2)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.Aborted || results.BaselineComparisons != nil {
+		t.Errorf("Expected the path mode baselines to be requested within the session, got aborted=%v: %s", results.Aborted, results.AbortReason)
+	}
+}
+
+func TestDiscoverParamsFlakyChunks(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "debug", "random1", "random2", "random3", "random4", "random5"}
+	request := Request{
+		URL:    "http://localhost:8181/flaky-chunks",
+		Method: "GET",
+	}
+
+	results, err := DiscoverParams(request, params, 4)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected failed requests to be retried rather than reported, got: %v", results.Params)
+	}
+
+	if responseChanged([]ResponseData{{StatusCode: 200}}, ResponseData{Err: io.ErrUnexpectedEOF}, true) {
+		t.Errorf("Expected a failed response not to be reported as changed")
+	}
+}
+
+func TestDiscoverParamsErrorTriggering(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "debug", "random1", "id", "random2", "random3"}
+	request := Request{
+		URL:    "http://localhost:8181/crash",
+		Method: "GET",
+	}
+
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 2 || !contains(results.Params, "id") || !contains(results.Params, "debug") {
+		t.Fatalf("Expected id and debug to be discovered, got: %v", results.Params)
+	}
+	if len(results.ErrorParams) != 1 || results.ErrorParams[0] != "id" {
+		t.Errorf("Expected only id to be tagged as error-triggering, got: %v", results.ErrorParams)
+	}
+	if len(results.Findings) != 2 || results.Findings[0].Name != "id" || !results.Findings[0].ErrorTriggering || results.Findings[1].ErrorTriggering {
+		t.Errorf("Expected the error-triggering finding to be tagged and listed first, got: %+v", results.Findings)
+	}
+}
+
+func TestDiscoverParamsIgnoreStatus(t *testing.T) {
+	startMockServer()
+	defer func(previous map[int]bool) { ignoredStatuses = previous }(ignoredStatuses)
+
+	params := []string{"param1", "id", "random1", "lb", "random2", "random3"}
+	request := Request{
+		URL:    "http://localhost:8181/unstable-backend",
+		Method: "GET",
+	}
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !contains(results.Params, "lb") {
+		t.Fatalf("Expected the 503s to look like a change without -ignore-status, got: %v", results.Params)
+	}
+
+	if err := setIgnoredStatuses("500, 503"); err != nil {
+		t.Fatalf("Unexpected error parsing the status codes: %v", err)
+	}
+	results, err = DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "id" {
+		t.Errorf("Expected only id to be discovered, got: %v", results.Params)
+	}
+	if results.IgnoredResponses == 0 {
+		t.Errorf("Expected the 503 responses to be counted as ignored")
+	}
+	if results.FailedRequests != 0 {
+		t.Errorf("Expected ignored responses not to count as failed requests, got %d", results.FailedRequests)
+	}
+
+	if err := setIgnoredStatuses("500,oops"); err == nil {
+		t.Errorf("Expected an invalid status code to be rejected")
+	}
+}
+
+func TestDiscoverParamsBaselineSnapshot(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { includeBaselineHeaders = previous }(includeBaselineHeaders)
+
+	request := Request{
+		URL:    "http://localhost:8181/about",
+		Method: "GET",
+	}
+	params := []string{"param1", "lang", "random1"}
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.Baseline != nil {
+		t.Errorf("Expected no baseline snapshot by default, got: %+v", results.Baseline)
+	}
+
+	includeBaselineHeaders = true
+	results, err = DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	expected := &BaselineSnapshot{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Server":       "nginx/1.25.3",
+			"X-Powered-By": "PHP/8.2.12",
+			"Content-Type": "text/html; charset=UTF-8",
+		},
+		BodyLength: len(aboutPage),
+	}
+	if !reflect.DeepEqual(results.Baseline, expected) {
+		t.Errorf("Expected the baseline snapshot %+v, got: %+v", expected, results.Baseline)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "lang" {
+		t.Errorf("Expected only lang to be discovered, got: %v", results.Params)
+	}
+
+	request.URL = "http://localhost:8181/unstable"
+	results, err = DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !results.Aborted || results.Baseline == nil || results.Baseline.StatusCode != http.StatusOK {
+		t.Errorf("Expected an aborted scan to have the baseline snapshot, got aborted=%v: %+v", results.Aborted, results.Baseline)
+	}
+}
+
+func TestDiscoverParamsParamPrefix(t *testing.T) {
+	startMockServer()
+	defer func(prefix, suffix string) { paramPrefix, paramSuffix = prefix, suffix }(paramPrefix, paramSuffix)
+
+	params := []string{"param1", "draft", "random1", "random2", "page"}
+	request := Request{
+		URL:    "http://localhost:8181/articles",
+		Method: "GET",
+	}
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 0 {
+		t.Errorf("Expected no parameter to be read without the prefix, got: %v", results.Params)
+	}
+
+	paramPrefix, paramSuffix = "filter[", "]"
+	results, err = DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "draft" {
+		t.Errorf("Expected draft to be discovered and reported without the prefix, got: %v", results.Params)
+	}
+	if len(results.Findings) != 1 || results.Findings[0].Name != "draft" || results.ParamPrefix != "filter[" || results.ParamSuffix != "]" {
+		t.Errorf("Expected the finding to keep the wordlist name and the report the decoration, got: %+v", results)
+	}
+
+	// Form fields are sent with the name the page gives them
+	results, err = DiscoverParams(Request{URL: "http://localhost:8181/form", Method: "GET"}, []string{"param1", "random1"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "page" {
+		t.Errorf("Expected the page form field to be discovered undecorated, got: %v", results.Params)
+	}
+}
+
+func TestDiscoverParamsArrayStyle(t *testing.T) {
+	startMockServer()
+	defer func(previous string) { arrayStyle = previous }(arrayStyle)
+
+	params := []string{"param1", "id", "random1", "tag", "random2", "status"}
+	request := Request{
+		URL:    "http://localhost:8181/bulk",
+		Method: "GET",
+	}
+	for style, expected := range map[string][]string{"": nil, arrayRepeat: {"id"}, arrayBrackets: {"tag"}, arrayComma: {"status"}} {
+		arrayStyle = style
+		results, err := DiscoverParams(request, params, 3)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		if len(results.Params) != len(expected) || (len(expected) > 0 && results.Params[0] != expected[0]) {
+			t.Errorf("Style %q: expected %v to be discovered, got: %v", style, expected, results.Params)
+		}
+	}
+
+	arrayStyle = arrayRepeat
+	body := renderBodyTemplate(`{"user":"guest",FUZZ}`, "json", url.Values{"id": {"a", "b"}, "page": {"c"}})
+	if body != `{"user":"guest","id":["a","b"],"page":"c"}` {
+		t.Errorf("Expected repeated values to become a JSON array, got %s", body)
+	}
+}
+
+func TestDiscoverParamsClientRedirect(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "page", "random1"}
+	for path, kind := range map[string]string{"/meta-shim": "meta", "/js-shim": "javascript"} {
+		request := Request{
+			URL:    "http://localhost:8181" + path,
+			Method: "GET",
+		}
+		results, err := DiscoverParams(request, params, 3)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		expected := &ClientRedirect{From: request.URL, To: "http://localhost:8181/", Type: kind}
+		if !reflect.DeepEqual(results.ClientRedirect, expected) {
+			t.Errorf("%s: expected the redirect %+v to be reported, got %+v", path, expected, results.ClientRedirect)
+		}
+		if len(results.Params) != 1 || results.Params[0] != "page" || results.Request.URL != expected.To {
+			t.Errorf("%s: expected page to be discovered on the destination, got %v on %s", path, results.Params, results.Request.URL)
+		}
+	}
+
+	results, err := DiscoverParams(Request{URL: "http://localhost:8181/", Method: "GET"}, params, 3)
+	if err != nil || results.ClientRedirect != nil {
+		t.Errorf("Expected no redirect to be reported for a regular page, got %+v (%v)", results.ClientRedirect, err)
+	}
+
+	request := Request{URL: "http://localhost:8181/shim", Method: "GET"}
+	for _, body := range []string{
+		`<html><head><meta http-equiv="refresh" content="0;url=https://sso.example.com/login"></head></html>`,
+		`<html><body><script>location.href = 'javascript:void(0)';</script></body></html>`,
+		`<html><body><p>location = "/elsewhere"</p></body></html>`,
+		`<html><head><meta http-equiv="refresh" content="300; url=/"></head><body>Redirecting...</body></html>`,
+		`<html><body><h1>Welcome back</h1><p>` + strings.Repeat("Read the latest news from our team. ", 10) + `</p><script>if (expired) location.href = "/login";</script></body></html>`,
+	} {
+		if redirect := clientRedirect(request, ResponseData{Body: []byte(body)}); redirect != nil {
+			t.Errorf("Expected no redirect to be followed for %s, got %+v", body, redirect)
+		}
+	}
+}
+
+func TestDiscoverParamsProbeMethod(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { probeMethod = previous }(probeMethod)
+
+	params := []string{"param1", "sort", "random1"}
+	request := Request{URL: "http://localhost:8181/comments", Method: "GET"}
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.AllowedMethods != nil {
+		t.Errorf("Expected no allowed methods without -probe-method, got %v", results.AllowedMethods)
+	}
+
+	probeMethod = true
+	results, err = DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if expected := []string{"GET", "POST", "OPTIONS"}; !reflect.DeepEqual(results.AllowedMethods, expected) {
+		t.Errorf("Expected the allowed methods %v, got %v", expected, results.AllowedMethods)
+	}
+	if !reflect.DeepEqual(results.Params, []string{"sort"}) {
+		t.Errorf("Expected sort to be discovered, got %v", results.Params)
+	}
+
+	var logs bytes.Buffer
+	defer setLogger(setLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	results, err = DiscoverParams(Request{URL: request.URL, Method: "PUT"}, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "isn't allowed by the endpoint") || len(results.AllowedMethods) != 3 {
+		t.Errorf("Expected a warning that PUT isn't allowed, got %v and logs:\n%s", results.AllowedMethods, logs.String())
+	}
+}
+
+func TestDiscoverParamsLinear(t *testing.T) {
+	defer func(previous bool) { linearScan = previous }(linearScan)
+	linearScan = true
+
+	candidates := []string{"param0", "debug", "param2", "admin", "param4"}
+	var mu sync.Mutex
+	sent := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var names []string
+		for _, name := range candidates {
+			if query.Has(name) {
+				names = append(names, name)
+			}
+		}
+		mu.Lock()
+		for _, name := range names {
+			sent[name]++
+		}
+		mu.Unlock()
+		if len(names) > 1 {
+			t.Errorf("Expected a single candidate per request, got %v", names)
+		}
+		if len(names) == 1 && (names[0] == "debug" || names[0] == "admin") {
+			w.Write([]byte("<html><body><h1>" + names[0] + " mode</h1><p>Enabled</p></body></html>"))
+			return
+		}
+		w.Write([]byte("<html><body><h1>Home</h1><p>Welcome</p></body></html>"))
+	}))
+	defer server.Close()
+
+	results, err := DiscoverParams(Request{URL: server.URL, Method: "GET"}, candidates, 50)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !reflect.DeepEqual(results.Params, []string{"debug", "admin"}) {
+		t.Errorf("Expected debug and admin to be discovered in wordlist order, got %v", results.Params)
+	}
+	expected := map[string]int{"param0": 1, "debug": 2, "param2": 1, "admin": 2, "param4": 1}
+	if !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Expected a request per candidate plus a confirmation per finding, got %v", sent)
+	}
+}
+
+func TestDiscoverParamsRedirectChain(t *testing.T) {
+	startMockServer()
+
+	results, err := DiscoverParams(Request{URL: "http://localhost:8181/old-home", Method: "GET"}, []string{"param1", "page", "random1"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.FinalURL != "http://localhost:8181/" || !reflect.DeepEqual(results.RedirectChain, []string{"http://localhost:8181/old-home"}) {
+		t.Errorf("Expected the baseline redirect from /old-home to / to be reported, got %q via %v", results.FinalURL, results.RedirectChain)
+	}
+
+	results, err = DiscoverParams(Request{URL: "http://localhost:8181/go", Method: "GET"}, []string{"param1", "next", "random1"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.FinalURL != "" || results.RedirectChain != nil {
+		t.Errorf("Expected no redirect for a baseline served directly, got %q via %v", results.FinalURL, results.RedirectChain)
+	}
+	if len(results.Findings) != 1 || results.Findings[0].Name != "next" || results.Findings[0].RedirectsTo != "http://localhost:8181/" {
+		t.Errorf("Expected next to be reported as redirecting to /, got %+v", results.Findings)
+	}
+
+	results, err = DiscoverParams(Request{URL: "http://localhost:8181/shop", Method: "GET"}, []string{"param1", "category", "random1"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Findings) != 1 || results.Findings[0].Name != "category" || results.Findings[0].RedirectsTo != "" {
+		t.Errorf("Expected category not to redirect elsewhere than the baseline when the redirect keeps the query, got %+v", results.Findings)
+	}
+
+	defer func(mode string) { scanMode = mode }(scanMode)
+	scanMode = "path"
+	results, err = DiscoverParams(Request{URL: "http://localhost:8181/old-home?from=FUZZ", Method: "GET"}, []string{"random1"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.FinalURL == "" || len(results.RedirectChain) != 1 {
+		t.Errorf("Expected the baseline redirect to be reported in path mode, got %q via %v", results.FinalURL, results.RedirectChain)
+	}
+}
+
+func TestDiscoverParamsMarkers(t *testing.T) {
+	startMockServer()
+	defer func(match, filter string, only bool) {
+		matchString, filterString, matchOnly = match, filter, only
+	}(matchString, filterString, matchOnly)
+
+	params := []string{"param1", "debug", "param2", "admin", "param3", "sort"}
+	request := Request{URL: "http://localhost:8181/dashboard", Method: "GET"}
+	for _, test := range []struct {
+		match, filter string
+		only          bool
+		expected      []string
+	}{
+		{filter: "Access Denied", only: true, expected: []string{"debug"}},
+		{match: "Welcome", only: true, expected: []string{"admin"}},
+		{match: "Welcome", filter: "Access Denied", only: true, expected: []string{"admin", "debug"}},
+		{match: "Welcome", expected: []string{"admin", "debug", "sort"}},
+	} {
+		matchString, filterString, matchOnly = test.match, test.filter, test.only
+		results, err := DiscoverParams(request, params, 3)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		sort.Strings(results.Params)
+		if !reflect.DeepEqual(results.Params, test.expected) {
+			t.Errorf("match %q, filter %q, only %v: expected %v, got %v", test.match, test.filter, test.only, test.expected, results.Params)
+		}
+	}
+}
+
+func TestDiscoverParamsHashParams(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { mineHashParams = previous }(mineHashParams)
+
+	request := Request{URL: "http://localhost:8181/spa", Method: "GET"}
+	params := []string{"param1", "view", "random1"}
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil || results.HashParams != nil {
+		t.Errorf("Expected no fragment parameters without -hash-params, got %v (%v)", results.HashParams, err)
+	}
+
+	mineHashParams = true
+	results, err = DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !reflect.DeepEqual(results.HashParams, []string{"view", "item"}) {
+		t.Errorf("Expected the fragment parameters read by the app, got %v", results.HashParams)
+	}
+	if len(results.Params) != 0 {
+		t.Errorf("Expected the fragment parameters not to be reported as server parameters, got %v", results.Params)
+	}
+}
+
+func TestDiscoverParamsCompareTiming(t *testing.T) {
+	startMockServer()
+	defer func(signals map[string]bool, delay time.Duration) { compared, timingMinDelay = signals, delay }(compared, timingMinDelay)
+	if err := setCompared("timing"); err != nil {
+		t.Fatal(err)
+	}
+	timingMinDelay = 100 * time.Millisecond
+
+	params := []string{"param1", "page", "param2", "wait", "param3", "param4"}
+	results, err := DiscoverParams(Request{URL: "http://localhost:8181/lookup", Method: "GET"}, params, 6)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.Aborted || !reflect.DeepEqual(results.Params, []string{"wait"}) {
+		t.Errorf("Expected only the delaying wait parameter despite the changing bodies, got %v (aborted: %q)", results.Params, results.AbortReason)
+	}
+}
+
+func TestLatencyThreshold(t *testing.T) {
+	defer func(deviations float64, delay time.Duration) {
+		timingDeviations, timingMinDelay = deviations, delay
+	}(timingDeviations, timingMinDelay)
+	timingDeviations, timingMinDelay = 2, 0
+
+	baselines := []ResponseData{{Elapsed: 100 * time.Millisecond}, {Elapsed: 200 * time.Millisecond}, {Elapsed: 300 * time.Millisecond}, {Err: errors.New("failed")}}
+	mean, stddev := latencyStats(baselines)
+	if mean != 200*time.Millisecond || stddev < 81*time.Millisecond || stddev > 82*time.Millisecond {
+		t.Errorf("Expected a mean of 200ms and a deviation of about 81.6ms, got %s and %s", mean, stddev)
+	}
+	if threshold := newLatencyThreshold(baselines); threshold != mean+2*stddev {
+		t.Errorf("Expected the threshold to be two deviations above the mean, got %s", threshold)
+	}
+	timingMinDelay = time.Second
+	if threshold := newLatencyThreshold(baselines); threshold != mean+time.Second {
+		t.Errorf("Expected the minimum delay to raise the threshold, got %s", threshold)
+	}
+}
+
+func TestDiscoverParamsMaxDepth(t *testing.T) {
+	defer func(previous int) { maxRecursionDepth = previous }(maxRecursionDepth)
+	defer func(previous string) { strategy = previous }(strategy)
+
+	params := []string{"param0", "debug", "param2", "param3", "param4", "param5", "admin", "param7"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range []string{"debug", "admin"} {
+			if r.URL.Query().Has(name) {
+				w.Write([]byte("<html><body><h1>" + name + " mode</h1><p>Enabled</p></body></html>"))
+				return
+			}
+		}
+		w.Write([]byte("<html><body><h1>Home</h1><p>Welcome</p></body></html>"))
+	}))
+	defer server.Close()
+	request := Request{URL: server.URL, Method: "GET"}
+
+	// The confirm strategy must keep the depth cap and the direct test of
+	// small chunks that bisection applies.
+	for _, name := range []string{"bisect", "confirm"} {
+		strategy = name
+		maxRecursionDepth = 1
+		results, err := DiscoverParams(request, params, len(params))
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		sort.Slice(results.Clusters, func(i, j int) bool { return results.Clusters[i][0] < results.Clusters[j][0] })
+		expected := [][]string{{"param0", "debug", "param2", "param3"}, {"param4", "param5", "admin", "param7"}}
+		if !reflect.DeepEqual(results.Clusters, expected) || len(results.Params) != 0 {
+			t.Errorf("%s: Expected the halves to be reported as clusters after a single bisection, got %v and params %v", name, results.Clusters, results.Params)
+		}
+		if len(results.Warnings) == 0 || !strings.Contains(results.Warnings[0].Message, "clusters") {
+			t.Errorf("%s: Expected a warning about the clusters, got %v", name, results.Warnings)
+		}
+
+		maxRecursionDepth = 2
+		results, err = DiscoverParams(request, params, len(params))
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		sort.Strings(results.Params)
+		if results.Clusters != nil || !reflect.DeepEqual(results.Params, []string{"admin", "debug"}) {
+			t.Errorf("%s: Expected the parameters to be isolated within the depth, got %v and clusters %v", name, results.Params, results.Clusters)
+		}
+	}
+}
+
+func TestDiscoverParamsWebhook(t *testing.T) {
+	startMockServer()
+	defer func(previous string) { webhookURL = previous }(webhookURL)
+
+	var mu sync.Mutex
+	var events []WebhookEvent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event WebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected webhook payload: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+	webhookURL = receiver.URL
+
+	request := Request{URL: "http://localhost:8181/reflect", Method: "GET"}
+	results, err := DiscoverParams(request, []string{"param1", "q", "param2", "admin", "param3"}, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	mu.Lock()
+	sort.Slice(events, func(i, j int) bool { return events[i].Parameter < events[j].Parameter })
+	expected := []WebhookEvent{
+		{Parameter: "admin", URL: request.URL, Method: "GET", Reflected: false},
+		{Parameter: "q", URL: request.URL, Method: "GET", Reflected: true},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected a notification per discovered parameter, got %+v", events)
+	}
+	mu.Unlock()
+
+	// Path segments are notified too
+	defer func(mode string) { scanMode = mode }(scanMode)
+	scanMode = "path"
+	mu.Lock()
+	events = nil
+	mu.Unlock()
+	if _, err := DiscoverParams(Request{URL: "http://localhost:8181/users", Method: "GET"}, []string{"guest", "admin"}, 5); err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	mu.Lock()
+	if len(events) != 1 || events[0].Parameter != "admin" {
+		t.Errorf("Expected a notification for the discovered path segment, got %+v", events)
+	}
+	mu.Unlock()
+	scanMode = "params"
+
+	// An unreachable receiver doesn't fail the scan
+	receiver.Close()
+	failed, err := DiscoverParams(request, []string{"param1", "q", "param2", "admin", "param3"}, 5)
+	if err != nil || len(failed.Params) != len(results.Params) {
+		t.Errorf("Expected the scan to go on despite the webhook failing, got %v (%v)", failed.Params, err)
+	}
+
+	// Nor does a receiver that never answers hold up its end
+	defer func(previous time.Duration) { webhookDrainTimeout = previous }(webhookDrainTimeout)
+	webhookDrainTimeout = 100 * time.Millisecond
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+	webhookURL = hanging.URL
+	start := time.Now()
+	if _, err := DiscoverParams(request, []string{"param1", "q", "param2", "admin", "param3"}, 5); err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the queued notifications to be abandoned after the drain timeout, the scan took %s", elapsed)
+	}
+}
+
+func TestDiscoverParamsDuplicateParams(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "debug", "random1", "random2"}
+	for path, expected := range map[string]string{"/first-wins": duplicateFirstWins, "/last-wins": duplicateLastWins} {
+		request := Request{
+			URL:    "http://localhost:8181" + path + "?debug=0",
+			Method: "GET",
+		}
+		results, err := DiscoverParams(request, params, 1)
+		if err != nil {
+			t.Fatalf("Unexpected scan error on %s: %v", path, err)
+		}
+		if results.DuplicateParams != expected {
+			t.Errorf("Expected %s to be detected as %q, got %q", path, expected, results.DuplicateParams)
+		}
+		// The candidate collides with the debug=0 already in the URL
+		if len(results.Params) != 1 || results.Params[0] != "debug" {
+			t.Errorf("Expected the colliding debug parameter to be discovered on %s, got: %v", path, results.Params)
+		}
+	}
+
+	results, err := DiscoverParams(Request{URL: "http://localhost:8181/first-wins", Method: "GET"}, params, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.DuplicateParams != "" {
+		t.Errorf("Expected no duplicate parameter probe without a query in the URL, got %q", results.DuplicateParams)
+	}
+}
+
+func TestDiscoverParamsReplaceExisting(t *testing.T) {
+	startMockServer()
+	defer func() { replaceExisting = false }()
+	replaceExisting = true
+	duplicateQueries.Store(0)
+
+	request := Request{
+		URL:    "http://localhost:8181/strict-query?debug=0",
+		Method: "GET",
+	}
+	results, err := DiscoverParams(request, []string{"param1", "debug", "random1"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected the colliding debug parameter to be discovered, got: %v", results.Params)
+	}
+	if results.QueryCollisions != collisionsReplace || results.DuplicateParams != "" {
+		t.Errorf("Expected the replace mode to be reported without a duplicate probe, got %q and %q", results.QueryCollisions, results.DuplicateParams)
+	}
+	if duplicates := duplicateQueries.Load(); duplicates != 0 {
+		t.Errorf("Expected no request to repeat a query parameter, got %d", duplicates)
+	}
+}
+
+func TestDiscoverParamsInvalidNames(t *testing.T) {
+	startMockServer()
+	defer func(length int) { maxParamLength = length }(maxParamLength)
+	defer setParamCharset(defaultParamCharset)
+
+	params := []string{"param1", strings.Repeat("a", 500), "debug", "<script>", "user[id]", "na me", "random1"}
+	if valid := validParamNames(append(params, "new\nline")); !reflect.DeepEqual(valid, []string{"param1", "debug", "<script>", "user[id]", "na me", "random1"}) {
+		t.Errorf("Expected only the long name and the control characters to be skipped by default, got: %v", valid)
+	}
+
+	request := Request{
+		URL:    "http://localhost:8181/strict-names",
+		Method: "GET",
+	}
+	if err := setParamCharset(`^[\w.\-\[\]$:@]+$`); err != nil {
+		t.Fatal(err)
+	}
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected the garbage names to be skipped instead of reported, got: %v", results.Params)
+	}
+
+	maxParamLength = 0
+	if err := setParamCharset(""); err != nil {
+		t.Fatal(err)
+	}
+	if valid := validParamNames(params); len(valid) != len(params) {
+		t.Errorf("Expected every name to be accepted without limits, got: %v", valid)
+	}
+	maxParamLength = 8
+	if err := setParamCharset(`^[a-z]+$`); err != nil {
+		t.Fatal(err)
+	}
+	if valid := validParamNames(params); !reflect.DeepEqual(valid, []string{"debug"}) {
+		t.Errorf("Expected only short lower case names, got: %v", valid)
+	}
+	if err := setParamCharset("["); err == nil {
+		t.Errorf("Expected an invalid charset pattern to be rejected")
+	}
+}
+
+func TestDiscoverParamsUnsupportedContentType(t *testing.T) {
+	startMockServer()
+	defer func(previous []string) { scannedContentTypes = previous }(scannedContentTypes)
+
+	request := Request{
+		URL:    "http://localhost:8181/report.pdf",
+		Method: "GET",
+	}
+	start := totalRequests.Load()
+	results, err := DiscoverParams(request, []string{"param1", "page", "random1"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !results.Aborted || results.AbortReason != "unsupported content type application/pdf" {
+		t.Errorf("Expected the scan to be aborted over the PDF baseline, got aborted=%v reason=%q", results.Aborted, results.AbortReason)
+	}
+	var abort *AbortError
+	if err := results.Err(); !errors.Is(err, ErrUnsupportedContentType) || !errors.As(err, &abort) || abort.Reason != results.AbortReason {
+		t.Errorf("Expected an AbortError for ErrUnsupportedContentType, got %v", err)
+	}
+	if sent := totalRequests.Load() - start; sent > int64(numBaselines) {
+		t.Errorf("Expected no candidate to be requested, %d requests were sent", sent)
+	}
+
+	setContentTypes("application/pdf")
+	results, err = DiscoverParams(request, []string{"param1", "page", "random1"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.Aborted {
+		t.Errorf("Expected the PDF to be scanned once allowed, got: %q", results.AbortReason)
+	}
+
+	setContentTypes("application/json")
+	ldJSON := ResponseData{Headers: http.Header{"Content-Type": {"application/ld+json; charset=utf-8"}}}
+	if contentType := unsupportedContentType(ldJSON); contentType != "" {
+		t.Errorf("Expected a +json media type to be scanned as JSON, got %q", contentType)
+	}
+}
+
+func TestDiscoverParamsFlakyBaseline(t *testing.T) {
+	startMockServer()
+	flakyRequests.Store(0)
+	defer func(previous int) { numBaselines = previous }(numBaselines)
+	numBaselines = 4
+
+	params := []string{"param1", "param2", "page", "query", "random1", "random2"}
+
+	request := Request{
+		URL:         "http://localhost:8181/flaky-baseline",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 3)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if results.Aborted {
+		t.Fatalf("Expected the scan to proceed despite a single outlier baseline, but it was aborted: %s", results.AbortReason)
+	}
+
+	for _, param := range []string{"page", "query"} {
+		if !contains(results.Params, param) {
+			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
+		}
+	}
+}
+
+func TestDiscoverParamsPairs(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { pairsEnabled = previous }(pairsEnabled)
+	pairsEnabled = true
+
+	params := []string{"param1", "action", "param2", "id", "random1"}
+
+	request := Request{
+		URL:         "http://localhost:8181/pair",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if len(results.Params) > 0 {
+		t.Errorf("Expected no single parameter to be valid, but found: %v", results.Params)
+	}
+	if len(results.ParamPairs) != 1 || results.ParamPairs[0] != [2]string{"action", "id"} {
+		t.Errorf("Expected the pair [action id] to be discovered, got: %v", results.ParamPairs)
+	}
+
+	// The chunk that changed until it was split outranks the wordlist order
+	defer func(previous int) { maxPairCandidates = previous }(maxPairCandidates)
+	maxPairCandidates = 4
+	params = nil
+	for i := 0; i < 20; i++ {
+		params = append(params, fmt.Sprintf("filler%d", i))
+	}
+	params = append(params, "param1", "action", "param2", "id")
+	results, err = DiscoverParams(request, params, 4)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.ParamPairs) != 1 || results.ParamPairs[0] != [2]string{"action", "id"} {
+		t.Errorf("Expected the near-miss chunk to be paired first, got: %v", results.ParamPairs)
+	}
+}
+
+func TestDiscoverParamPairsBoundsConcurrency(t *testing.T) {
+	var running, peak, sent atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			previous := peak.Load()
+			if current <= previous || peak.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		sent.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("<html><body><h1>Normal</h1></body></html>"))
+	}))
+	defer target.Close()
+	defer func(previous int) { filterConcurrency = previous }(filterConcurrency)
+	filterConcurrency = 2
+	httpClient = createHTTPClient()
+
+	request := Request{URL: target.URL, Method: "GET"}
+	initialResponses := newInitialResponses([]ResponseData{makeRequest(request, url.Values{}), makeRequest(request, url.Values{})})
+	sent.Store(0)
+	peak.Store(0)
+	discoverParamPairs(request, []string{"a", "b", "c", "d", "e", "f"}, initialResponses)
+	if sent.Load() != 15 || peak.Load() > 2 {
+		t.Errorf("Expected the 15 pairs at most 2 at a time, got %d requests and %d at once", sent.Load(), peak.Load())
+	}
+}
+
+func TestDiscoverParamsBodyTemplate(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "debug", "random1", "random2"}
+
+	request := Request{
+		URL:         "http://localhost:8181/template",
+		Method:      "POST",
+		Data:        `{"user":"guest","extra":{FUZZ}}`,
+		ContentType: "json",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if results.Aborted {
+		t.Fatalf("Expected the scan to complete, but it was aborted: %s", results.AbortReason)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected only parameter debug to be discovered through the body template, got: %v", results.Params)
+	}
+}
+
+// bodyWithoutParams returns body, of the content type, without the params, in
+// a canonical form that can be compared with the body of the baseline.
+func bodyWithoutParams(t *testing.T, contentType string, body string, params url.Values) string {
+	t.Helper()
+	switch contentType {
+	case "json":
+		var decoded any
+		if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+			t.Fatalf("Invalid JSON body %q: %v", body, err)
+		}
+		var strip func(value any)
+		strip = func(value any) {
+			if object, ok := value.(map[string]any); ok {
+				for key, member := range object {
+					if params.Has(key) {
+						delete(object, key)
+					} else {
+						strip(member)
+					}
+				}
+			}
+		}
+		strip(decoded)
+		canonical, _ := json.Marshal(decoded)
+		return string(canonical)
+	case "xml":
+		for key := range params {
+			body = strings.Replace(body, "<"+key+">"+params.Get(key)+"</"+key+">", "", 1)
+		}
+		return body
+	case "multipart":
+		form, err := multipart.NewReader(strings.NewReader(body), multipartBoundary).ReadForm(1 << 20)
+		if err != nil {
+			t.Fatalf("Invalid multipart body %q: %v", body, err)
+		}
+		values := url.Values(form.Value)
+		for key := range params {
+			values.Del(key)
+		}
+		return values.Encode()
+	default:
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			t.Fatalf("Invalid form body %q: %v", body, err)
+		}
+		for key := range params {
+			values.Del(key)
+		}
+		return values.Encode()
+	}
+}
+
+func TestBuildBodyBaselineParity(t *testing.T) {
+	params := url.Values{"debug": {"qWeRtYuI"}, "role": {"aSdFgHjK"}}
+	tests := []struct {
+		contentType string
+		data        string
+	}{
+		{"form", "id=7"},
+		{"form", "id=7&FUZZ"},
+		{"form", "FUZZ&id=7"},
+		{"json", `{"id":7}`},
+		{"json", `{"id":7,FUZZ}`},
+		{"json", `{ FUZZ, "id":7 }`},
+		{"json", `{"id":7,"extra":{FUZZ}}`},
+		{"xml", "<request><id>7</id>FUZZ</request>"},
+		{"multipart", "id=7"},
+		{"multipart", "id=7&FUZZ"},
+	}
+	for _, test := range tests {
+		request := Request{URL: "http://localhost:8181/", Method: "POST", Data: test.data, ContentType: test.contentType}
+		injection := injectionPoint(request)
+		baseline, baselineType := buildBody(request, url.Values{}, injection, nil)
+		candidate, candidateType := buildBody(request, params, injection, nil)
+		if baselineType != candidateType {
+			t.Errorf("%s %s: expected the same Content-Type, got %q and %q", test.contentType, test.data, baselineType, candidateType)
+		}
+		if injection == "query" && test.contentType != "form" && test.contentType != "multipart" {
+			// The candidates go in the query string, the body is left as is
+			if baseline != candidate || baseline != test.data {
+				t.Errorf("%s %s: expected the static body for both, got %q and %q", test.contentType, test.data, baseline, candidate)
+			}
+			continue
+		}
+		if candidate == baseline {
+			t.Errorf("%s %s: expected the candidates in the body, got %q", test.contentType, test.data, candidate)
+		}
+		if expected, got := bodyWithoutParams(t, test.contentType, baseline, nil), bodyWithoutParams(t, test.contentType, candidate, params); got != expected {
+			t.Errorf("%s %s: expected the candidate body to be the baseline plus the candidates, got %q for baseline %q", test.contentType, test.data, candidate, baseline)
+		}
+	}
+}
+
+func TestMergeBody(t *testing.T) {
+	located := url.Values{"debug": {"1"}, "role": {"<admin>"}}
+	tests := []struct {
+		contentType, body, expected string
+	}{
+		{"form", "id=7", "id=7&debug=1&role=%3Cadmin%3E"},
+		{"json", `{"id":7}`, `{"id":7,"debug":"1","role":"\u003cadmin\u003e"}`},
+		{"json", "{ }\n", `{"debug":"1","role":"\u003cadmin\u003e"}`},
+		{"json", "", `{"debug":"1","role":"\u003cadmin\u003e"}`},
+		{"xml", "<request><id>7</id></request>", "<request><id>7</id><debug>1</debug><role>&lt;admin&gt;</role></request>"},
+		{"xml", "", "<debug>1</debug><role>&lt;admin&gt;</role>"},
+	}
+	for _, test := range tests {
+		if got := mergeBody(test.body, test.contentType, located); got != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", test.contentType, test.body, test.expected, got)
+		}
+	}
+
+	// Located params join the injected ones in a body template
+	request := Request{Method: "POST", Data: `{"id":7,FUZZ}`, ContentType: "json"}
+	body, _ := buildBody(request, url.Values{"page": {"2"}}, "body", located)
+	if expected := `{"id":7,"debug":"1","page":"2","role":"\u003cadmin\u003e"}`; body != expected {
+		t.Errorf("Expected the located params in the template, got %q", body)
+	}
+}
+
+func TestDiscoverParamsMultipart(t *testing.T) {
+	startMockServer()
+
+	request := Request{
+		URL:         "http://localhost:8181/upload",
+		Method:      "POST",
+		Data:        "id=7",
+		ContentType: "multipart",
+	}
+	results, err := DiscoverParams(request, []string{"param1", "debug", "random1", "random2"}, 4)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.Aborted || len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected only debug to be discovered in the multipart body, got: %v (aborted: %q)", results.Params, results.AbortReason)
+	}
+}
+
+func TestDiscoverParamsMethodBodies(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "role", "force", "random1"}
+
+	tests := []struct {
+		request  Request
+		expected string
+	}{
+		{Request{URL: "http://localhost:8181/resource", Method: "PATCH", Data: `{"name":"guest",FUZZ}`, ContentType: "json"}, "role"},
+		{Request{URL: "http://localhost:8181/resource", Method: "DELETE", Data: "id=7", ContentType: "form"}, "force"},
+	}
+
+	for _, test := range tests {
+		results, err := DiscoverParams(test.request, params, 5)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+		if results.Aborted {
+			t.Fatalf("%s: expected the scan to complete, but it was aborted: %s", test.request.Method, results.AbortReason)
+		}
+		if len(results.Params) != 1 || results.Params[0] != test.expected {
+			t.Errorf("%s: expected only parameter %s, got: %v", test.request.Method, test.expected, results.Params)
+		}
+	}
+}
+
+func TestDiscoverParamsHeaderMarker(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "debug", "random1", "random2"}
+
+	request := Request{
+		URL:         "http://localhost:8181/header",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+		Headers:     map[string]string{"X-Params": fuzzMarker},
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if results.Injection != "header:X-Params" {
+		t.Errorf("Expected the injection to be reported as header:X-Params, got: %s", results.Injection)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "debug" {
+		t.Errorf("Expected only parameter debug to be discovered through the header, got: %v", results.Params)
+	}
+}
+
+func TestDiscoverParamsJSONLinesWordlist(t *testing.T) {
+	startMockServer()
+	defer func() { paramValues, paramLocations = map[string]string{}, map[string]string{} }()
+
+	wordlist := filepath.Join(t.TempDir(), "params.jsonl")
+	lines := `{"name":"role","value":"admin","in":"cookie"}
+{"name":"X-Debug","in":"header"}
+{"name":"page"}
+
+{"name":"debug","value":"1","in":"query","method":"post"}
+`
+	if err := os.WriteFile(wordlist, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := loadWordlist(wordlist)
+	if err != nil {
+		t.Fatalf("Failed to load the wordlist: %v", err)
+	}
+	if len(candidates) != 4 || *candidates[0].Value != "admin" || candidates[1].Value != nil || candidates[3].Method != "POST" {
+		t.Fatalf("Unexpected candidates: %+v", candidates)
+	}
+
+	groups := applyCandidates(candidates, "GET")
+	if len(groups) != 2 || groups[0].Method != "GET" || len(groups[0].Params) != 3 || groups[1].Method != "POST" {
+		t.Fatalf("Expected the candidates to be grouped by method, got: %+v", groups)
+	}
+
+	request := Request{
+		URL:    "http://localhost:8181/hints",
+		Method: groups[0].Method,
+	}
+	results, err := DiscoverParams(request, groups[0].Params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if !contains(results.Params, "role") || !contains(results.Params, "X-Debug") || contains(results.Params, "page") {
+		t.Errorf("Expected the cookie and header parameters to be discovered, got: %v", results.Params)
+	}
+
+	// Each method is a scan of its own, whose requests are only counted once
+	start := totalRequests.Load()
+	results, err = scanCandidates(request, candidates, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if sent := int(totalRequests.Load() - start); results.TotalRequests != sent || results.Timing.Requests != sent {
+		t.Errorf("Expected the %d requests sent to be reported, got %d (timing %d)", sent, results.TotalRequests, results.Timing.Requests)
+	}
+
+	if err := os.WriteFile(wordlist, []byte(`{"name":"debug","in":"fragment"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadWordlist(wordlist); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected an unsupported location to be rejected with its line, got: %v", err)
+	}
+}
+
+func TestLoadWordlistOrDefault(t *testing.T) {
+	candidates, err := loadWordlistOrDefault(filepath.Join(t.TempDir(), "missing.txt"))
+	if err != nil {
+		t.Fatalf("Expected a missing wordlist to fall back to the built-in one, got: %v", err)
+	}
+	var names []string
+	for _, candidate := range candidates {
+		names = append(names, candidate.Name)
+	}
+	for _, name := range []string{"id", "debug", "redirect", "callback", "token"} {
+		if !contains(names, name) {
+			t.Errorf("Expected the built-in wordlist to include %s", name)
+		}
+	}
+	if defaults, err := loadWordlistOrDefault(""); err != nil || !reflect.DeepEqual(defaults, candidates) {
+		t.Errorf("Expected the built-in wordlist when none is given, got %d candidates and error %v", len(defaults), err)
+	}
+
+	wordlist := filepath.Join(t.TempDir(), "wordlist.jsonl")
+	if err := os.WriteFile(wordlist, []byte("not json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadWordlistOrDefault(wordlist); err == nil {
+		t.Errorf("Expected an invalid wordlist to fail rather than fall back")
+	}
+}
+
+func TestAppendWordlist(t *testing.T) {
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("id\nformat=json\ndebug"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	added, err := appendWordlist(wordlist, []string{"debug", "format", "page", "user", "page"})
+	if err != nil || !reflect.DeepEqual(added, []string{"page", "user"}) {
+		t.Errorf("Expected only the new names to be appended, got %v (%v)", added, err)
+	}
+	if data, _ := os.ReadFile(wordlist); string(data) != "id\nformat=json\ndebug\npage\nuser\n" {
+		t.Errorf("Unexpected wordlist after appending: %q", data)
+	}
+	if added, err := appendWordlist(wordlist, []string{"page", "user"}); err != nil || added != nil {
+		t.Errorf("Expected nothing to be appended twice, got %v (%v)", added, err)
+	}
+
+	jsonWordlist := filepath.Join(dir, "new", "wordlist.jsonl")
+	if _, err := appendWordlist(jsonWordlist, []string{"debug", "page"}); err != nil {
+		t.Fatalf("Unexpected error creating the wordlist: %v", err)
+	}
+	if data, _ := os.ReadFile(jsonWordlist); string(data) != "{\"name\":\"debug\"}\n{\"name\":\"page\"}\n" {
+		t.Errorf("Unexpected JSON lines wordlist: %q", data)
+	}
+	if candidates, err := loadWordlist(jsonWordlist); err != nil || len(candidates) != 2 {
+		t.Errorf("Expected the appended JSON lines wordlist to load, got %v (%v)", candidates, err)
+	}
+}
+
+func TestRunAppendWordlist(t *testing.T) {
+	startMockServer()
+	defer func(previous io.Writer) { summaryOutput = previous }(summaryOutput)
+	defer func(previous string) { appendWordlistPath = previous }(appendWordlistPath)
+	summaryOutput = io.Discard
+
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("param1\npage\nrandom1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	personal := filepath.Join(dir, "personal.txt")
+	if err := os.WriteFile(personal, []byte("id\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"-quiet", "-url", "http://localhost:8181", "-wordlist", wordlist, "-report", "", "-append-wordlist", personal}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if data, _ := os.ReadFile(personal); string(data) != "id\npage\n" {
+		t.Errorf("Expected the discovered page parameter to be appended, got %q", data)
+	}
+}
+
+func TestRunExpandCase(t *testing.T) {
+	startMockServer()
+	defer func(previous io.Writer) { summaryOutput = previous }(summaryOutput)
+	defer func() { mutations = nil }()
+
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("userId\npage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "report.json")
+
+	summaryOutput = io.Discard
+	if code := run([]string{"-url", "http://localhost:8181/naming", "-wordlist", wordlist, "-report", report, "-expand-case"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	results, err := loadReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "user_id" {
+		t.Errorf("Expected the snake case variant of userId to be discovered, got: %v", results.Params)
+	}
+}
+
+func TestRunReportWriteFailure(t *testing.T) {
+	startMockServer()
+	defer func(previous io.Writer) { summaryOutput = previous }(summaryOutput)
+	summaryOutput = io.Discard
+
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("page\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"-quiet", "-url", "http://localhost:8181", "-wordlist", wordlist, "-report", dir}); code != exitError {
+		t.Errorf("Expected exit code %d when the report can't be written, got %d", exitError, code)
+	}
+}
+
+func TestRunScanForms(t *testing.T) {
+	startMockServer()
+	defer func(previous io.Writer) { summaryOutput = previous }(summaryOutput)
+
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("remember\npage\nrandom1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "report.json")
+
+	summaryOutput = io.Discard
+	if code := run([]string{"-url", "http://localhost:8181/login-page", "-wordlist", wordlist, "-report", report, "-scan-forms"}); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	results, err := loadReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedForms := []Form{
+		{Action: "http://localhost:8181/login", Method: "POST", Params: []string{"username", "password", "csrf"}},
+		{Action: "https://search.example/find", Method: "GET", Params: []string{"q"}},
+		{Action: "http://localhost:8181/login-page", Method: "GET", Params: []string{"lang"}},
+	}
+	if !reflect.DeepEqual(results.Forms, expectedForms) {
+		t.Errorf("Expected forms %+v, got %+v", expectedForms, results.Forms)
+	}
+	// The form on another host and the one submitting to the page itself aren't scanned
+	if len(results.FormTargets) != 1 {
+		t.Fatalf("Expected only the login handler to be scanned, got %d targets", len(results.FormTargets))
+	}
+	login := results.FormTargets[0]
+	if login.Request.URL != "http://localhost:8181/login" || login.Request.Method != "POST" {
+		t.Errorf("Unexpected form target request: %+v", login.Request)
+	}
+	if len(login.Params) != 2 || !contains(login.Params, "username") || !contains(login.Params, "remember") {
+		t.Errorf("Expected the form input and the wordlist parameter to be discovered on the handler, got: %v", login.Params)
+	}
+}
+
+func TestDiscoverParamsWordlistValues(t *testing.T) {
+	startMockServer()
+	defer func() { wordlistValues, paramValues = false, map[string]string{} }()
+
+	wordlist := filepath.Join(t.TempDir(), "params.txt")
+	if err := os.WriteFile(wordlist, []byte("param1\nformat=json\nrandom1\n=orphan\nquery\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	request := Request{
+		URL:    "http://localhost:8181/format",
+		Method: "GET",
+	}
+
+	candidates, err := loadWordlist(wordlist)
+	if err != nil {
+		t.Fatalf("Failed to load the wordlist: %v", err)
+	}
+	groups := applyCandidates(candidates, request.Method)
+	results, err := DiscoverParams(request, groups[0].Params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 0 {
+		t.Errorf("Expected nothing to be discovered with name=value lines read as names, got: %v", results.Params)
+	}
+
+	wordlistValues = true
+	candidates, err = loadWordlist(wordlist)
+	if err != nil {
+		t.Fatalf("Failed to load the wordlist: %v", err)
+	}
+	if len(candidates) != 5 || candidates[1].Name != "format" || *candidates[1].Value != "json" || candidates[3].Name != "=orphan" || candidates[0].Value != nil {
+		t.Fatalf("Unexpected candidates: %+v", candidates)
+	}
+	groups = applyCandidates(candidates, request.Method)
+	results, err = DiscoverParams(request, groups[0].Params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "format" {
+		t.Errorf("Expected only format to be discovered with its predefined value, got: %v", results.Params)
+	}
+	// "json" is in every page, so the fixed value must not count as reflected
+	if len(results.ReflectedParams) != 0 {
+		t.Errorf("Expected predefined values not to be reported as reflected, got: %v", results.ReflectedParams)
+	}
+}
+
+func TestRunWordlistValuesFlag(t *testing.T) {
+	startMockServer()
+	defer func(previous io.Writer) { summaryOutput = previous }(summaryOutput)
+	defer func() { wordlistValues, paramValues = false, map[string]string{} }()
+	summaryOutput = io.Discard
+
+	dir := t.TempDir()
+	wordlist := filepath.Join(dir, "params.txt")
+	if err := os.WriteFile(wordlist, []byte("param1\nformat=json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := filepath.Join(dir, "report.json")
+	for _, values := range []bool{false, true} {
+		args := []string{"-quiet", "-url", "http://localhost:8181/format", "-wordlist", wordlist, "-report", report}
+		if values {
+			args = append(args, "-wordlist-values")
+		}
+		if code := run(args); code != exitOK {
+			t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+		}
+		results, err := loadReport(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := contains(results.Params, "format"); found != values {
+			t.Errorf("-wordlist-values %v: expected format to be discovered only with its value, got: %v", values, results.Params)
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	startMockServer()
+	defer func(previous io.Writer) { summaryOutput = previous }(summaryOutput)
+
+	wordlist := filepath.Join(t.TempDir(), "wordlist.txt")
+	if err := os.WriteFile(wordlist, []byte("param1\npage\nrandom1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"missing url", []string{"-wordlist", wordlist}, exitError},
+		{"invalid multipart data", []string{"-url", "http://localhost:8181", "-wordlist", wordlist, "-type", "multipart", "-data", "name=%zz"}, exitError},
+		{"found", []string{"-url", "http://localhost:8181", "-wordlist", wordlist, "-report", ""}, exitOK},
+		{"fail on found", []string{"-url", "http://localhost:8181", "-wordlist", wordlist, "-report", "", "-fail-on-found"}, exitFound},
+	}
+
+	for _, tt := range tests {
+		var output bytes.Buffer
+		summaryOutput = &output
+		if code := run(tt.args); code != tt.code {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.code, code)
+		}
+		if tt.code == exitError {
+			continue
+		}
+
+		var line map[string]Summary
+		if err := json.Unmarshal(output.Bytes(), &line); err != nil {
+			t.Fatalf("%s: summary is not valid JSON: %v", tt.name, err)
+		}
+		if summary := line["summary"]; summary.Params != 1 || summary.Aborted {
+			t.Errorf("%s: unexpected summary %+v", tt.name, summary)
+		}
+	}
+}
+
+func TestDiscoverParamsSourceAttribution(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "page", "random1"}
+
+	request := Request{
+		URL:         "http://localhost:8181/form",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if len(results.Findings) != 1 {
+		t.Fatalf("Expected a single finding, got: %+v", results.Findings)
+	}
+	finding := results.Findings[0]
+	if finding.Name != "page" || len(finding.Sources) != 2 || finding.Sources[0] != sourceWordlist || finding.Sources[1] != sourceForm {
+		t.Errorf("Expected page to be attributed to both the wordlist and the form, got: %+v", finding)
+	}
+	if finding.Method != "GET" || finding.Reflected {
+		t.Errorf("Expected an unreflected GET finding, got: %+v", finding)
+	}
+}
+
+func TestDiscoverParamsReflectionShortcut(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "q", "param2", "lang", "param3", "admin", "param4", "param5"}
+
+	request := Request{
+		URL:         "http://localhost:8181/reflect",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	start := totalRequests.Load()
+	results, err := DiscoverParams(request, params, 8)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	requests := int(totalRequests.Load() - start)
+
+	for _, param := range []string{"q", "lang", "admin"} {
+		if !contains(results.Params, param) {
+			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
+		}
+	}
+	if len(results.Params) != 3 {
+		t.Errorf("Expected exactly 3 parameters, got: %v", results.Params)
+	}
+
+	// Baselines, the catch-all probe, the chunk and bisecting the 6 unreflected params down to admin
+	if max := numBaselines + 1 + 1 + 6; requests > max {
+		t.Errorf("Expected at most %d requests with the reflection shortcut, got %d", max, requests)
+	}
+}
+
+func TestDiscoverParamsReflectedAttribution(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "q", "param2", "admin", "param3"}
+
+	request := Request{
+		URL:         "http://localhost:8181/reflect",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if len(results.ReflectedParams) != 1 || results.ReflectedParams[0] != "q" {
+		t.Errorf("Expected only q to be attributed as reflected, got: %v", results.ReflectedParams)
+	}
+	for _, finding := range results.Findings {
+		if finding.Reflected != (finding.Name == "q") {
+			t.Errorf("Unexpected reflection attribution for %s: %v", finding.Name, finding.Reflected)
+		}
+		if finding.Name == "q" && !reflect.DeepEqual(finding.ReflectionContexts, []string{contextHTMLText}) {
+			t.Errorf("Expected q to be reflected in a text node, got: %v", finding.ReflectionContexts)
+		}
+	}
+}
+
+func TestDiscoverParamsOnlyReflected(t *testing.T) {
+	startMockServer()
+	defer func(previous bool) { onlyReflected = previous }(onlyReflected)
+	onlyReflected = true
+
+	params := []string{"param1", "q", "param2", "lang", "param3", "admin", "param4", "param5"}
+
+	request := Request{
+		URL:         "http://localhost:8181/reflect",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 8)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if len(results.Params) != 2 || !contains(results.Params, "q") || !contains(results.Params, "lang") {
+		t.Errorf("Expected only the reflected q and lang parameters, got: %v", results.Params)
+	}
+	if len(results.AllParams) != 3 || !contains(results.AllParams, "admin") {
+		t.Errorf("Expected the full set of parameters to still be reported, got: %v", results.AllParams)
+	}
+}
+
+func TestDiscoverParamsCatchAll(t *testing.T) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "id", "param3", "param4", "param5", "param6", "param7"}
+
+	request := Request{
+		URL:         "http://localhost:8181/catch-all",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 8)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	if results.CatchAll != catchAllReflects {
+		t.Errorf("Expected the catch-all reflection to be detected, got: %q", results.CatchAll)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "id" {
+		t.Errorf("Expected only the id parameter, got: %v", results.Params)
+	}
+
+	request.URL = "http://localhost:8181/reflect"
+	results, err = DiscoverParams(request, []string{"q", "param1"}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if results.CatchAll != catchAllNone {
+		t.Errorf("Expected no catch-all on the reflect endpoint, got: %q", results.CatchAll)
+	}
+}
+
+func TestDiscoverParamsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"unparseable url", "http://local host:8181/%zz"},
+		{"missing scheme", "localhost:8181"},
+		{"unreachable target", "http://127.0.0.1:1"},
+	}
+
+	for _, tt := range tests {
+		_, err := DiscoverParams(Request{URL: tt.url, Method: "GET"}, []string{"page"}, 5)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if unreachable := errors.Is(err, ErrTargetUnreachable); unreachable != (tt.name == "unreachable target") {
+			t.Errorf("%s: unexpected ErrTargetUnreachable match for %v", tt.name, err)
+		}
+	}
+}
+
+func TestDiscoverParamsAuthentication(t *testing.T) {
+	startMockServer()
+	defer func(credentials, scheme string) { authCredentials, authType = credentials, scheme }(authCredentials, authType)
+	authCredentials = "admin:secret"
+
+	params := []string{"param1", "page", "random1"}
+
+	for _, scheme := range []string{"basic", "digest"} {
+		authType = scheme
+		request := Request{
+			URL:         "http://localhost:8181/" + scheme,
+			Method:      "GET",
+			Data:        "",
+			ContentType: "",
+		}
+
+		results, err := DiscoverParams(request, params, 3)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+
+		if len(results.Params) != 1 || results.Params[0] != "page" {
+			t.Errorf("%s: expected only page to be discovered behind authentication, got: %v", scheme, results.Params)
+		}
+	}
+}
+
+func TestDiscoverParamsTokenRefresh(t *testing.T) {
+	startMockServer()
+	defer func(previous string) { tokenRefreshURL = previous }(tokenRefreshURL)
+	defer func(previous *tokenRefresher) { tokens = previous }(tokens)
+	tokenRefreshURL = "http://localhost:8181/oauth/token"
+	tokens = &tokenRefresher{}
+
+	params := []string{"param1", "param2", "param3", "param4", "param5", "param6", "page", "query", "session", "user", "token", "mode", "random1", "random2", "random3", "random4", "random5", "player", "team", "score"}
+
+	request := Request{
+		URL:         "http://localhost:8181/oauth",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+		Headers:     map[string]string{"Authorization": "Bearer expired"},
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	for _, param := range []string{"page", "query", "session", "user", "token", "mode"} {
+		if !contains(results.Params, param) {
+			t.Errorf("Expected parameter %s not found. Detected: %s", param, results.Params)
+		}
+	}
+	if len(results.Params) != 6 {
+		t.Errorf("Expected 401 responses not to be reported as changes, got: %v", results.Params)
+	}
+	if tokens.generation < 2 {
+		t.Errorf("Expected the token to be refreshed several times, got %d refreshes", tokens.generation)
+	}
+}
+
+func TestDiscoverParamsPathMode(t *testing.T) {
+	startMockServer()
+	defer func(previous string) { scanMode = previous }(scanMode)
+	scanMode = "path"
+
+	params := []string{"guest", "admin", "test", "root", "john"}
+
+	for _, url := range []string{"http://localhost:8181/users", "http://localhost:8181/users/FUZZ"} {
+		request := Request{
+			URL:         url,
+			Method:      "GET",
+			Data:        "",
+			ContentType: "",
+		}
+
+		results, err := DiscoverParams(request, params, 5)
+		if err != nil {
+			t.Fatalf("Unexpected scan error: %v", err)
+		}
+
+		if len(results.Params) != 2 || !contains(results.Params, "admin") || !contains(results.Params, "root") {
+			t.Errorf("%s: expected the admin and root path segments, got: %v", url, results.Params)
+		}
+	}
+
+	defer func(length int) { maxParamLength = length }(maxParamLength)
+	maxParamLength = 4
+	results, err := DiscoverParams(Request{URL: "http://localhost:8181/users", Method: "GET"}, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	if len(results.Params) != 1 || results.Params[0] != "root" {
+		t.Errorf("Expected the segments longer than the limit to be skipped, got: %v", results.Params)
+	}
+
+	// Each segment keeps the method of the scan that found it
+	maxParamLength = 32
+	candidates := []Candidate{{Name: "guest"}, {Name: "root"}, {Name: "admin", Method: "POST"}}
+	results, err = scanCandidates(Request{URL: "http://localhost:8181/users", Method: "GET"}, candidates, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+	methods := make(map[string]string)
+	for _, finding := range results.Findings {
+		methods[finding.Name] = finding.Method
+	}
+	if !reflect.DeepEqual(methods, map[string]string{"root": "GET", "admin": "POST"}) {
+		t.Errorf("Expected a finding per segment with the method it was found with, got: %+v", results.Findings)
+	}
+}
+
+func TestStripEchoedPath(t *testing.T) {
+	tests := []struct {
+		body, expected string
+	}{
+		{`<p>User admin not found</p>`, `<p>User  not found</p>`},
+		{`<a href="/users/admin">Retry</a>`, `<a href="">Retry</a>`},
+		{`<p>http://example.com/users/admin?x=1 is gone</p>`, `<p>?x=1 is gone</p>`},
+		{`<p>Contact the administrators, admin-panel or sysadmin</p>`, `<p>Contact the administrators, admin-panel or sysadmin</p>`},
+		{`<p>admin</p><p>admin.</p>`, `<p></p><p>.</p>`},
+	}
+	for _, test := range tests {
+		body := stripEchoedPath([]byte(test.body), "http://example.com/users/admin", "admin")
+		if string(body) != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.body, test.expected, body)
+		}
+	}
+}
+
+func TestDiscoverParamsSaveEvidence(t *testing.T) {
+	startMockServer()
+	defer func(previous string) { evidenceDir = previous }(evidenceDir)
+	evidenceDir = t.TempDir()
+
+	params := []string{"param1", "q", "param2", "admin", "param3"}
+
+	request := Request{
+		URL:         "http://localhost:8181/reflect",
+		Method:      "GET",
+		Data:        "",
+		ContentType: "",
+	}
+
+	results, err := DiscoverParams(request, params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected scan error: %v", err)
+	}
+
+	for _, param := range results.Params {
+		raw, err := os.ReadFile(filepath.Join(evidenceDir, param+".request.txt"))
+		if err != nil {
+			t.Fatalf("Expected request evidence for %s: %v", param, err)
+		}
+		if !strings.Contains(string(raw), param+"=") {
+			t.Errorf("Expected the %s request evidence to contain the parameter, got: %s", param, raw)
+		}
+		if _, err := os.Stat(filepath.Join(evidenceDir, param+".response.txt")); err != nil {
+			t.Errorf("Expected response evidence for %s: %v", param, err)
+		}
+	}
+	for _, finding := range results.Findings {
+		if expected := filepath.Join(evidenceDir, finding.Name+".response.txt"); finding.Evidence != expected {
+			t.Errorf("Expected the %s finding to point to %s, got %q", finding.Name, expected, finding.Evidence)
+		}
+	}
+	if len(results.Params) != 2 {
+		t.Errorf("Expected evidence for 2 parameters, got: %v", results.Params)
+	}
+
+	if name := evidenceFilename("../etc/pass wd"); name != "_.._etc_pass_wd" {
+		t.Errorf("Unexpected sanitized evidence filename: %s", name)
+	}
+}
+
+func TestEvidenceSizeLimit(t *testing.T) {
+	defer func(previous string) { evidenceDir = previous }(evidenceDir)
+	defer func(previous int64) { evidenceMaxBytes = previous }(evidenceMaxBytes)
+	defer evidence.reset()
+	evidenceDir = t.TempDir()
+	evidenceMaxBytes = 100
+
+	evidence.reset()
+	evidence.record([]string{"first"}, ResponseData{RawRequest: []byte("GET /?first=1"), Body: make([]byte, 60)}, true)
+	evidence.record([]string{"second"}, ResponseData{RawRequest: []byte("GET /?second=1"), Body: make([]byte, 60)}, true)
+	if err := evidence.write(evidenceDir, []string{"first", "second"}); err != nil {
+		t.Fatalf("Unexpected error writing evidence: %v", err)
+	}
+	if evidence.pathOf("first") == "" || evidence.pathOf("second") != "" {
+		t.Errorf("Expected only the first parameter to fit in the limit, got %q and %q", evidence.pathOf("first"), evidence.pathOf("second"))
+	}
+	if _, err := os.Stat(filepath.Join(evidenceDir, "second.response.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected no evidence file past the limit, got %v", err)
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	startMockServer()
+	defer func(previous string) { hostOverride, httpClient = previous, nil }(hostOverride)
+	if code := run([]string{"-host", "admin.internal", "-url", "http://localhost:8181/vhost", "-wordlist", t.TempDir(), "-report", ""}); code != exitError {
+		t.Fatalf("Expected the unreadable wordlist to fail the run, got exit code %d", code)
+	}
+	if hostOverride != "admin.internal" {
+		t.Fatalf("Expected -host to set the host override, got %q", hostOverride)
+	}
+	httpClient = createHTTPClient()
+
+	response := sendRequest(Request{URL: "http://localhost:8181/vhost", Method: "GET"}, url.Values{})
+	if !strings.Contains(string(response.Body), "<h1>admin.internal</h1>") {
+		t.Errorf("Expected the request to carry the overridden Host, got: %s", response.Body)
+	}
+
+	config, err := buildTLSConfig()
+	if err != nil || config == nil || config.ServerName != "admin.internal" {
+		t.Errorf("Expected the TLS server name to be overridden, got %+v (%v)", config, err)
+	}
+}
+
+func TestHostOverrideSendsSNI(t *testing.T) {
+	var mu sync.Mutex
+	var serverName, host string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		serverName, host = r.TLS.ServerName, r.Host
+		mu.Unlock()
+	}))
+	defer server.Close()
+	defer func(override string, ignore bool) {
+		hostOverride, ignoreCertErrors, httpClient = override, ignore, nil
+	}(hostOverride, ignoreCertErrors)
+
+	if code := run([]string{"-host-override", "tenant.example", "-ignore-cert", "-url", server.URL, "-wordlist", t.TempDir(), "-report", ""}); code != exitError {
+		t.Fatalf("Expected the unreadable wordlist to fail the run, got exit code %d", code)
+	}
+	httpClient = createHTTPClient()
+	response := sendRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	if response.StatusCode != 200 {
+		t.Fatalf("Expected the TLS request to succeed, got %d (%v)", response.StatusCode, response.Err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if serverName != "tenant.example" || host != "tenant.example" {
+		t.Errorf("Expected the SNI and Host to be tenant.example, got %q and %q", serverName, host)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected uint16
+		valid    bool
+	}{
+		{"", 0, true},
+		{"1.0", tls.VersionTLS10, true},
+		{"1.1", tls.VersionTLS11, true},
+		{"1.2", tls.VersionTLS12, true},
+		{"1.3", tls.VersionTLS13, true},
+		{"1.4", 0, false},
+		{"TLS1.2", 0, false},
+		{"1", 0, false},
+	}
+	for _, test := range tests {
+		version, err := parseTLSVersion(test.version)
+		if version != test.expected || (err == nil) != test.valid {
+			t.Errorf("%q: expected %#x (valid: %v), got %#x (%v)", test.version, test.expected, test.valid, version, err)
+		}
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	defer func(cert, key, min, max string) {
+		clientCert, clientKey, tlsMin, tlsMax = cert, key, min, max
+	}(clientCert, clientKey, tlsMin, tlsMax)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	tests := []struct {
+		cert, key, min, max string
+		err                 string
+	}{
+		{"", "", "1.2", "1.3", ""},
+		{"", "", "1.3", "1.2", "is greater than maximum"},
+		{"", "", "1.2", "1.5", "unsupported TLS version"},
+		{certFile, "", "", "", "both a client certificate and key are required"},
+		{"", keyFile, "", "", "both a client certificate and key are required"},
+		{keyFile, certFile, "", "", "loading client certificate"},
+		{certFile, keyFile, "", "", ""},
+	}
+	for _, test := range tests {
+		clientCert, clientKey, tlsMin, tlsMax = test.cert, test.key, test.min, test.max
+		config, err := buildTLSConfig()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%+v: expected an error containing %q, got %v", test, test.err, err)
+			}
+			continue
+		}
+		if err != nil || config == nil {
+			t.Errorf("%+v: unexpected error %v", test, err)
+			continue
+		}
+		if test.cert != "" && len(config.Certificates) != 1 {
+			t.Errorf("Expected the client certificate to be loaded, got %d", len(config.Certificates))
+		}
+		if test.min == "1.2" && (config.MinVersion != tls.VersionTLS12 || config.MaxVersion != tls.VersionTLS13) {
+			t.Errorf("Expected TLS 1.2 to 1.3, got %#x to %#x", config.MinVersion, config.MaxVersion)
+		}
+	}
+}
+
+func TestResponseChangedDebugLogsSimilarity(t *testing.T) {
+	var output bytes.Buffer
+	defer setLogger(setLogger(slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	baselines := []ResponseData{
+		{Body: []byte("<html><body><h1>Normal</h1></body></html>"), StatusCode: 200},
+		{Body: []byte("<html><body><h1>Normal page</h1></body></html>"), StatusCode: 200},
+	}
+	candidate := ResponseData{Body: []byte("<html><body><h1>Normal page!</h1></body></html>"), StatusCode: 200}
+
+	responseChanged(baselines, candidate, false)
+
+	line := output.String()
+	if !strings.Contains(line, "similarity=0.9") || !strings.Contains(line, "baseline=1") {
+		t.Errorf("Expected the debug line to contain the best matching baseline and its similarity, got: %s", line)
+	}
+}
+
+func BenchmarkStrategies(b *testing.B) {
+	startMockServer()
+
+	params := []string{"param1", "param2", "param3", "param4", "param5", "param6", "page", "query", "session", "user", "token", "mode", "random1", "random2", "random3", "random4", "random5", "player", "team", "score"}
+	request := Request{
+		URL:    "http://localhost:8181",
+		Method: "GET",
+	}
+
+	for _, name := range []string{"bisect", "confirm"} {
+		b.Run(name, func(b *testing.B) {
+			defer func(previous string) { strategy = previous }(strategy)
+			strategy = name
+
+			start := totalRequests.Load()
+			for i := 0; i < b.N; i++ {
+				DiscoverParams(request, params, 10)
+			}
+			b.ReportMetric(float64(totalRequests.Load()-start)/float64(b.N), "requests/op")
+		})
+	}
+}
+
+func BenchmarkMakeRequest(b *testing.B) {
+	startMockServer()
+
+	request := Request{
+		URL:    "http://localhost:8181",
+		Method: "GET",
+	}
+
+	b.Run("per-request-client", func(b *testing.B) {
+		defer func(previous *http.Client) { httpClient = previous }(httpClient)
+		for i := 0; i < b.N; i++ {
+			httpClient = createHTTPClient()
+			makeRequest(request, generateParams([]string{"param1"}))
+			httpClient.CloseIdleConnections()
+		}
+	})
+
+	b.Run("shared-client", func(b *testing.B) {
+		defer func(previous *http.Client) { httpClient = previous }(httpClient)
+		httpClient = createHTTPClient()
+		for i := 0; i < b.N; i++ {
+			makeRequest(request, generateParams([]string{"param1"}))
+		}
+	})
+}
+
+// benchmarkBodies are page bodies of the sizes the benchmarks cover.
+var benchmarkBodies = map[string]int{"small": 1 << 10, "large": 64 << 10}
+
+// benchmarkPage returns an HTML page of about size bytes whose paragraph
+// number marker differs between variants.
+func benchmarkPage(size int, marker string) []byte {
+	var page strings.Builder
+	page.WriteString("<html><body><h1>" + marker + "</h1>")
+	for i := 0; page.Len() < size; i++ {
+		page.WriteString("<p>" + strconv.Itoa(i) + " " + loremIpsum[:80] + "</p>")
+	}
+	page.WriteString("</body></html>")
+	return []byte(page.String())
+}
+
+func BenchmarkReflectedParams(b *testing.B) {
+	var names []string
+	for i := 0; i < 500; i++ {
+		names = append(names, "param"+strconv.Itoa(i))
+	}
+	params := generateParams(names)
+	for _, name := range []string{"small", "large"} {
+		body := benchmarkPage(benchmarkBodies[name], params.Get("param250"))
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				reflectedParams(params, body)
+			}
+		})
+	}
+}
+
+func BenchmarkDiscoverParams(b *testing.B) {
+	defer setLogger(setLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	var params []string
+	for i := 0; i < 1000; i++ {
+		params = append(params, "param"+strconv.Itoa(i))
+	}
+	params = append(params, "debug")
+
+	for _, name := range []string{"small", "large"} {
+		normal, changed := benchmarkPage(benchmarkBodies[name], "Normal"), benchmarkPage(benchmarkBodies[name], "Debug")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("debug") != "" {
+				w.Write(changed)
+				return
+			}
+			w.Write(normal)
+		}))
+		request := Request{URL: server.URL, Method: "GET"}
+
+		b.Run(name, func(b *testing.B) {
+			start := totalRequests.Load()
+			for i := 0; i < b.N; i++ {
+				results, err := DiscoverParams(request, params, 100)
+				if err != nil || len(results.Params) != 1 {
+					b.Fatalf("Unexpected scan results: %v (%v)", results.Params, err)
+				}
+			}
+			b.ReportMetric(float64(totalRequests.Load()-start)/float64(b.N), "requests/op")
+		})
+		server.Close()
+	}
+}
+
+func BenchmarkDirectThreshold(b *testing.B) {
+	defer setLogger(setLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer func(previous int) { directThreshold = previous }(directThreshold)
+
+	// A chunk of 8 with 3 valid parameters, the common small scan
+	params := []string{"param0", "debug", "param2", "verbose", "param4", "param5", "admin", "param7"}
+	normal := benchmarkPage(benchmarkBodies["small"], "Normal")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range []string{"debug", "verbose", "admin"} {
+			if r.URL.Query().Get(name) != "" {
+				w.Write(benchmarkPage(benchmarkBodies["small"], name))
+				return
+			}
+		}
+		w.Write(normal)
+	}))
+	defer server.Close()
+	request := Request{URL: server.URL, Method: "GET"}
+
+	for _, threshold := range []int{0, 2, 8} {
+		b.Run("threshold-"+strconv.Itoa(threshold), func(b *testing.B) {
+			directThreshold = threshold
+			start := totalRequests.Load()
+			for i := 0; i < b.N; i++ {
+				results, err := DiscoverParams(request, params, len(params))
+				if err != nil || len(results.Params) != 3 {
+					b.Fatalf("Unexpected scan results: %v (%v)", results.Params, err)
+				}
+			}
+			b.ReportMetric(float64(totalRequests.Load()-start)/float64(b.N), "requests/op")
+		})
 	}
 }
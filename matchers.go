@@ -0,0 +1,34 @@
+package main
+
+import "bytes"
+
+// matchString and filterString are markers whose appearance or disappearance
+// makes a candidate response changed: a response is changed when it contains
+// matchString and the baselines don't, e.g. "Welcome", or when the baselines
+// contain filterString and it doesn't, e.g. "Access Denied".
+var matchString, filterString string
+
+// matchOnly makes the markers replace the comparison of responses instead of
+// supplementing it, see compareResponse.
+var matchOnly bool
+
+// usingMarkers reports whether -match-string or -filter-string is set.
+func usingMarkers() bool {
+	return matchString != "" || filterString != ""
+}
+
+// markersChanged reports whether the candidate gained matchString or lost
+// filterString compared to every baseline.
+func markersChanged(baselines []ResponseData, candidate ResponseData) bool {
+	if len(baselines) == 0 {
+		return false
+	}
+	for _, baseline := range baselines {
+		gained := matchString != "" && !bytes.Contains(baseline.Body, []byte(matchString)) && bytes.Contains(candidate.Body, []byte(matchString))
+		lost := filterString != "" && bytes.Contains(baseline.Body, []byte(filterString)) && !bytes.Contains(candidate.Body, []byte(filterString))
+		if !gained && !lost {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// probeMethod sends an OPTIONS request before the scan and reports the
+// methods the endpoint allows. Methods aren't probed by sending them, as e.g.
+// a DELETE could alter the target.
+var probeMethod bool
+
+// allowedMethods are the methods the endpoint of the current scan allows, nil
+// when they weren't probed or the endpoint didn't tell.
+var allowedMethods []string
+
+// probeAllowedMethods asks the endpoint which methods it allows with an
+// OPTIONS request, returning the methods of its Allow header in upper case.
+func probeAllowedMethods(request Request) []string {
+	probe := request
+	probe.Method = http.MethodOptions
+	probe.Data = ""
+	response := sendRequest(probe, url.Values{})
+	if response.Err != nil {
+		return nil
+	}
+	var methods []string
+	for _, header := range response.Headers.Values("Allow") {
+		for _, method := range strings.Split(header, ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" && !slices.Contains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+	}
+	if len(methods) == 0 {
+		logger.Info("The endpoint didn't list its allowed methods", "status", response.StatusCode)
+		return nil
+	}
+	logger.Info("Allowed methods", "methods", methods)
+	if !slices.Contains(methods, strings.ToUpper(request.Method)) {
+		logger.Warn("The scanned method isn't allowed by the endpoint, try another one with -method", "method", request.Method, "allowed", methods)
+	}
+	return methods
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// mutations are the enabled classes of parameter name variants generated from
+// the wordlist: "case" (Debug, DEBUG), "affix" (is_debug, debug_mode,
+// debugFlag) and "separator" (debug_mode, debug-mode, debugMode, DEBUGMODE).
+var mutations []string
+
+// expandCase enables the case and separator mutations, testing the naming
+// conventions of a name (userId, user_id, user-id, USERID) on top of it.
+var expandCase bool
+
+// maxMutations caps the variants added per wordlist entry, so every mutation
+// class enabled doesn't multiply the number of requests without bound.
+var maxMutations = 10
+
+var (
+	mutationPrefixes = []string{"is", "has", "enable"}
+	mutationSuffixes = []string{"mode", "flag", "enabled"}
+)
+
+// setMutations parses a comma separated list of mutation classes.
+func setMutations(list string) error {
+	mutations = nil
+	for _, class := range strings.Split(list, ",") {
+		switch class = strings.TrimSpace(class); class {
+		case "":
+		case "case", "affix", "separator":
+			mutations = append(mutations, class)
+		default:
+			return fmt.Errorf("unknown mutation %q, expected case, affix or separator", class)
+		}
+	}
+	return nil
+}
+
+// enableMutations enables the mutation classes that aren't already.
+func enableMutations(classes ...string) {
+	for _, class := range classes {
+		if !slices.Contains(mutations, class) {
+			mutations = append(mutations, class)
+		}
+	}
+}
+
+// mutateCandidates adds the variants of every candidate's name after the
+// candidates, skipping names that are already present. Variants keep the
+// value, location and method of the candidate they come from.
+func mutateCandidates(candidates []Candidate) []Candidate {
+	if len(mutations) == 0 {
+		return candidates
+	}
+	seen := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		seen[candidate.Name] = true
+	}
+
+	mutated := append([]Candidate{}, candidates...)
+	for _, candidate := range candidates {
+		added := 0
+		for _, name := range nameVariants(candidate.Name) {
+			if added >= maxMutations {
+				break
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			variant := candidate
+			variant.Name = name
+			mutated = append(mutated, variant)
+			added++
+		}
+	}
+	return mutated
+}
+
+// nameVariants returns the variants of name for the enabled mutation classes,
+// in the order the classes were given.
+func nameVariants(name string) []string {
+	words := nameWords(name)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var variants []string
+	for _, class := range mutations {
+		switch class {
+		case "case":
+			variants = append(variants, strings.ToLower(name), strings.ToUpper(name), capitalize(name))
+		case "affix":
+			for _, prefix := range mutationPrefixes {
+				variants = append(variants, snakeCase(append([]string{prefix}, words...)), camelCase(append([]string{prefix}, words...)))
+			}
+			for _, suffix := range mutationSuffixes {
+				variants = append(variants, snakeCase(append(words[:len(words):len(words)], suffix)), camelCase(append(words[:len(words):len(words)], suffix)))
+			}
+		case "separator":
+			if len(words) > 1 {
+				variants = append(variants, snakeCase(words), strings.Join(words, "-"), camelCase(words), strings.Join(words, ""), strings.ToUpper(strings.Join(words, "")))
+			}
+		}
+	}
+	return variants
+}
+
+// nameWords splits a name into lower case words at separators and camel case
+// boundaries.
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' {
+			if len(word) > 0 {
+				words = append(words, strings.ToLower(string(word)))
+			}
+			word = nil
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, strings.ToLower(string(word)))
+			word = nil
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, strings.ToLower(string(word)))
+	}
+	return words
+}
+
+func snakeCase(words []string) string {
+	return strings.Join(words, "_")
+}
+
+func camelCase(words []string) string {
+	var name strings.Builder
+	for i, word := range words {
+		if i == 0 {
+			name.WriteString(word)
+		} else {
+			name.WriteString(capitalize(word))
+		}
+	}
+	return name.String()
+}
+
+func capitalize(word string) string {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return word
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMutateCandidates(t *testing.T) {
+	defer func(previous []string, max int) { mutations, maxMutations = previous, max }(mutations, maxMutations)
+
+	if err := setMutations("case, separator"); err != nil {
+		t.Fatal(err)
+	}
+	value := "1"
+	mutated := mutateCandidates([]Candidate{{Name: "debug_mode", Value: &value}, {Name: "Debug_Mode"}})
+	var names []string
+	for _, candidate := range mutated {
+		names = append(names, candidate.Name)
+	}
+	expected := []string{"debug_mode", "Debug_Mode", "DEBUG_MODE", "Debug_mode", "debug-mode", "debugMode", "debugmode", "DEBUGMODE"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+	if mutated[2].Value == nil || *mutated[2].Value != "1" {
+		t.Errorf("Expected variants to keep the value of their candidate")
+	}
+
+	if err := setMutations("affix"); err != nil {
+		t.Fatal(err)
+	}
+	maxMutations = 3
+	mutated = mutateCandidates([]Candidate{{Name: "debug"}})
+	if len(mutated) != 4 || mutated[1].Name != "is_debug" || mutated[2].Name != "isDebug" || mutated[3].Name != "has_debug" {
+		t.Errorf("Expected the affix variants to be capped at 3, got %+v", mutated)
+	}
+
+	if got := nameWords("userIDFlag-HTTPServer"); !reflect.DeepEqual(got, []string{"user", "id", "flag", "http", "server"}) {
+		t.Errorf("Unexpected words: %v", got)
+	}
+	if err := setMutations("leet"); err == nil {
+		t.Errorf("Expected an unknown mutation class to be rejected")
+	}
+}
+
+func TestExpandCase(t *testing.T) {
+	defer func(previous []string) { mutations = previous }(mutations)
+
+	if err := setMutations("separator"); err != nil {
+		t.Fatal(err)
+	}
+	enableMutations("case", "separator")
+	if expected := []string{"separator", "case"}; !reflect.DeepEqual(mutations, expected) {
+		t.Fatalf("Expected the enabled mutations to be deduplicated, got %v", mutations)
+	}
+
+	mutated := mutateCandidates([]Candidate{{Name: "userId"}, {Name: "user_id"}})
+	var names []string
+	for _, candidate := range mutated {
+		names = append(names, candidate.Name)
+	}
+	expected := []string{"userId", "user_id", "user-id", "userid", "USERID", "UserId", "USER_ID", "User_id"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+)
+
+// maxParamLength is the longest candidate name injected, longer ones being
+// wordlist garbage that servers reject with an error looking like a change.
+// A value of zero means no limit.
+var maxParamLength = 100
+
+// defaultParamCharset only rejects the names with control characters, which
+// can't be sent as is. Targets rejecting unusual names with an error are
+// scanned with a stricter pattern, e.g. ^[\w.\-\[\]$:@]+$ for word
+// characters plus the punctuation of array, dotted and namespaced names.
+const defaultParamCharset = `^\P{Cc}+$`
+
+// paramCharset matches the candidate names injected, nil accepting any name.
+var paramCharset = regexp.MustCompile(defaultParamCharset)
+
+// setParamCharset compiles the -param-charset pattern, an empty pattern
+// disabling the check for targets accepting unusual names.
+func setParamCharset(pattern string) error {
+	if pattern == "" {
+		paramCharset = nil
+		return nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid parameter charset: %w", err)
+	}
+	paramCharset = compiled
+	return nil
+}
+
+// validParamNames drops the names that are too long or have characters out
+// of paramCharset before they are chunked, logging what was skipped.
+func validParamNames(params []string) []string {
+	valid := make([]string, 0, len(params))
+	var skipped []string
+	for _, param := range params {
+		if param == "" || (maxParamLength > 0 && utf8.RuneCountInString(param) > maxParamLength) || (paramCharset != nil && !paramCharset.MatchString(param)) {
+			skipped = append(skipped, param)
+			continue
+		}
+		valid = append(valid, param)
+	}
+	if len(skipped) > 0 {
+		logger.Warn("Skipping invalid parameter names", "count", len(skipped), "max_length", maxParamLength)
+		for _, param := range skipped {
+			logger.Debug("Skipped invalid parameter name", "parameter", param)
+		}
+	}
+	return valid
+}
+
+// paramPrefix and paramSuffix wrap the names of the candidates when they are
+// injected, e.g. filter[ and ] for frameworks reading filter[debug]. Reports
+// keep the names from the wordlist.
+var paramPrefix, paramSuffix string
+
+// formFieldNames are the fields of the forms of the scanned page that aren't
+// wordlist candidates. The page gives their actual names, so they are sent
+// as is rather than decorated.
+var formFieldNames map[string]bool
+
+// decorateParams returns params with paramPrefix and paramSuffix around the
+// name of every wordlist candidate, followed by [] in the brackets
+// arrayStyle.
+func decorateParams(params url.Values) url.Values {
+	suffix := paramSuffix
+	if arrayStyle == arrayBrackets {
+		suffix += "[]"
+	}
+	if paramPrefix == "" && suffix == "" {
+		return params
+	}
+	decorated := make(url.Values, len(params))
+	for name, values := range params {
+		if formFieldNames[name] {
+			decorated[name] = values
+			continue
+		}
+		decorated[paramPrefix+name+suffix] = values
+	}
+	return decorated
+}
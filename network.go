@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// resolverAddr is the ip:port of a DNS server used instead of the system resolver.
+var resolverAddr string
+
+// forceIPv4 and forceIPv6 restrict connections to a single address family.
+var forceIPv4, forceIPv6 bool
+
+// dialTimeout, tlsTimeout and responseTimeout bound the phases of a request
+// separately: connecting, the TLS handshake, and waiting for the response
+// headers once the request is written. requestTimeout bounds the whole
+// request, body read included; zero leaves it unbounded.
+var (
+	dialTimeout     = 30 * time.Second
+	tlsTimeout      = 10 * time.Second
+	responseTimeout time.Duration
+	requestTimeout  time.Duration
+)
+
+// dialFunc establishes the connections to resolved addresses. Tests replace it
+// to observe which address a request was sent to.
+var dialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return newDialer().DialContext(ctx, network, addr)
+}
+
+func newDialer() *net.Dialer {
+	return &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+}
+
+// validateTimeouts rejects negative timeouts, which the transport would
+// otherwise treat as already expired.
+func validateTimeouts() error {
+	for name, timeout := range map[string]time.Duration{
+		"dial-timeout":     dialTimeout,
+		"tls-timeout":      tlsTimeout,
+		"response-timeout": responseTimeout,
+		"timeout":          requestTimeout,
+		"stream-timeout":   streamTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("-%s must not be negative, got %s", name, timeout)
+		}
+	}
+	return nil
+}
+
+// resolverAddress adds the DNS port to a resolver address given without one,
+// accepting bare and bracketed IPv6 addresses.
+func resolverAddress(addr string) (string, error) {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr, nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid resolver address %q, expected ip or ip:port", addr)
+	}
+	return net.JoinHostPort(host, "53"), nil
+}
+
+// newDialContext returns the transport dial function honouring the resolver
+// and address family options, or nil when the defaults should be used.
+func newDialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if resolverAddr == "" && !forceIPv4 && !forceIPv6 {
+		return nil
+	}
+
+	resolver := net.DefaultResolver
+	if resolverAddr != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, resolverAddr)
+			},
+		}
+	}
+
+	if forceIPv4 {
+		return resolvingDialer(resolver, "tcp4", "ip4")
+	}
+	if forceIPv6 {
+		return resolvingDialer(resolver, "tcp6", "ip6")
+	}
+	return resolvingDialer(resolver, "", "ip")
+}
+
+// resolvingDialer resolves the host with resolver and dials the returned
+// addresses in order until one connects. An empty network keeps the one
+// requested by the transport.
+func resolvingDialer(resolver *net.Resolver, forcedNetwork string, ipNetwork string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if forcedNetwork != "" {
+			network = forcedNetwork
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			return dialFunc(ctx, network, addr)
+		}
+
+		ips, err := resolver.LookupIP(ctx, ipNetwork, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no %s addresses found for %s", ipNetwork, host)
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialFunc(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// recordDials replaces dialFunc with one that records the dialed addresses.
+func recordDials(t *testing.T) func() []string {
+	var mu sync.Mutex
+	var addresses []string
+	previous := dialFunc
+	t.Cleanup(func() { dialFunc = previous })
+
+	dialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mu.Lock()
+		addresses = append(addresses, network+" "+addr)
+		mu.Unlock()
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, addresses...)
+	}
+}
+
+func TestForceIPv4ResolvesHost(t *testing.T) {
+	startMockServer()
+	dials := recordDials(t)
+	defer func(previous bool) { forceIPv4 = previous }(forceIPv4)
+	defer func(previous *http.Client) { httpClient = previous }(httpClient)
+	forceIPv4 = true
+	httpClient = createHTTPClient()
+
+	response := makeRequest(Request{URL: "http://localhost:8181", Method: "GET"}, url.Values{})
+	if response.StatusCode != 200 {
+		t.Fatalf("Expected a 200 response, got %d", response.StatusCode)
+	}
+
+	addresses := dials()
+	if len(addresses) == 0 || addresses[0] != "tcp4 127.0.0.1:8181" {
+		t.Errorf("Expected the request to be dialed over IPv4 to 127.0.0.1:8181, got: %v", addresses)
+	}
+}
+
+// startStubResolver serves DNS over UDP, answering A queries for host with
+// 127.0.0.1 and any other query with no records, and returns its address.
+func startStubResolver(t *testing.T, host string) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			question := query.Questions[0]
+			answer := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true, RecursionDesired: query.RecursionDesired},
+				Questions: query.Questions,
+			}
+			if question.Type == dnsmessage.TypeA && strings.EqualFold(question.Name.String(), host+".") {
+				answer.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			if packed, err := answer.Pack(); err == nil {
+				conn.WriteTo(packed, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestResolverOverridesSystemDNS(t *testing.T) {
+	startMockServer()
+	dials := recordDials(t)
+	defer func(previous string) { resolverAddr = previous }(resolverAddr)
+	defer func(previous *http.Client) { httpClient = previous }(httpClient)
+	resolverAddr = startStubResolver(t, "staging.paramsmap.test")
+	httpClient = createHTTPClient()
+
+	response := makeRequest(Request{URL: "http://staging.paramsmap.test:8181", Method: "GET"}, url.Values{})
+	if response.StatusCode != 200 {
+		t.Fatalf("Expected a 200 response through the stub resolver, got %d (%v)", response.StatusCode, response.Err)
+	}
+	if addresses := dials(); len(addresses) == 0 || !strings.HasSuffix(addresses[0], " 127.0.0.1:8181") {
+		t.Errorf("Expected the address returned by the stub resolver to be dialed, got: %v", addresses)
+	}
+}
+
+func TestResolverHasNoSystemFallback(t *testing.T) {
+	startMockServer()
+	dials := recordDials(t)
+	defer func(addr string, ipv4 bool) { resolverAddr, forceIPv4 = addr, ipv4 }(resolverAddr, forceIPv4)
+	defer func(previous *http.Client) { httpClient = previous }(httpClient)
+	resolverAddr = startStubResolver(t, "staging.paramsmap.test")
+	forceIPv4 = true
+	httpClient = createHTTPClient()
+
+	// Names the resolver doesn't know fail instead of reaching the system DNS
+	response := makeRequest(Request{URL: "http://production.paramsmap.test:8181", Method: "GET"}, url.Values{})
+	if response.Err == nil || len(dials()) != 0 {
+		t.Errorf("Expected a name unknown to the resolver to fail without dialing, got %d and dials %v", response.StatusCode, dials())
+	}
+
+	response = makeRequest(Request{URL: "http://staging.paramsmap.test:8181", Method: "GET"}, url.Values{})
+	if response.StatusCode != 200 {
+		t.Fatalf("Expected a 200 response through the stub resolver, got %d (%v)", response.StatusCode, response.Err)
+	}
+	if addresses := dials(); len(addresses) != 1 || addresses[0] != "tcp4 127.0.0.1:8181" {
+		t.Errorf("Expected the resolved address to be dialed over IPv4, got: %v", addresses)
+	}
+}
+
+func TestIPv6Target(t *testing.T) {
+	startMockServer()
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	listener.Close()
+	defer func(previous bool) { forceIPv6 = previous }(forceIPv6)
+	defer func(previous *http.Client) { httpClient = previous }(httpClient)
+	forceIPv6 = true
+	httpClient = createHTTPClient()
+
+	response := makeRequest(Request{URL: "http://[::1]:8181/reflect", Method: "GET"}, url.Values{"q": {"ipv6canary"}})
+	if response.StatusCode != 200 || !strings.Contains(string(response.Body), "ipv6canary") {
+		t.Errorf("Expected the query to reach the IPv6 target, got %d: %s (%v)", response.StatusCode, response.Body, response.Err)
+	}
+}
+
+func TestResolverAddress(t *testing.T) {
+	for addr, expected := range map[string]string{"::1": "[::1]:53", "[::1]": "[::1]:53", "[::1]:5353": "[::1]:5353", "10.0.0.2": "10.0.0.2:53", "10.0.0.2:5353": "10.0.0.2:5353"} {
+		if normalized, err := resolverAddress(addr); err != nil || normalized != expected {
+			t.Errorf("Expected resolver %q to become %q, got %q (%v)", addr, expected, normalized, err)
+		}
+	}
+	for _, addr := range []string{"dns.example", "", "10.0.0"} {
+		if _, err := resolverAddress(addr); err == nil {
+			t.Errorf("Expected resolver %q to be rejected", addr)
+		}
+	}
+}
+
+// stallingServer serves handler, which can block on release until the test ends.
+func stallingServer(t *testing.T, handler func(w http.ResponseWriter, release <-chan struct{})) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, release)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server
+}
+
+// withTimeouts sets the timeouts for the test and creates a client using them.
+func withTimeouts(t *testing.T, dial, tls, response, request time.Duration) {
+	previousDial, previousTLS, previousResponse, previousRequest := dialTimeout, tlsTimeout, responseTimeout, requestTimeout
+	previousClient := httpClient
+	t.Cleanup(func() {
+		dialTimeout, tlsTimeout, responseTimeout, requestTimeout = previousDial, previousTLS, previousResponse, previousRequest
+		httpClient = previousClient
+	})
+	dialTimeout, tlsTimeout, responseTimeout, requestTimeout = dial, tls, response, request
+	httpClient = createHTTPClient()
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func TestDialTimeout(t *testing.T) {
+	startMockServer()
+	withTimeouts(t, time.Nanosecond, time.Second, 0, 0)
+
+	response := makeRequest(Request{URL: "http://localhost:8181", Method: "GET"}, url.Values{})
+	if !isTimeout(response.Err) || !strings.Contains(response.Err.Error(), "dial") {
+		t.Errorf("Expected the dial to time out, got %d (%v)", response.StatusCode, response.Err)
+	}
+}
+
+func TestTLSTimeout(t *testing.T) {
+	// Accepts connections but never answers the client hello
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	withTimeouts(t, time.Second, 100*time.Millisecond, 0, 0)
+
+	start := time.Now()
+	response := makeRequest(Request{URL: "https://" + listener.Addr().String(), Method: "GET"}, url.Values{})
+	if response.Err == nil || !strings.Contains(response.Err.Error(), "TLS handshake timeout") {
+		t.Errorf("Expected the TLS handshake to time out, got %d (%v)", response.StatusCode, response.Err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the handshake to be abandoned after the TLS timeout, took %s", elapsed)
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	server := stallingServer(t, func(w http.ResponseWriter, release <-chan struct{}) {
+		<-release
+	})
+	withTimeouts(t, time.Second, time.Second, 100*time.Millisecond, 0)
+
+	response := makeRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	if !isTimeout(response.Err) || !strings.Contains(response.Err.Error(), "awaiting response headers") {
+		t.Errorf("Expected the wait for the response headers to time out, got %d (%v)", response.StatusCode, response.Err)
+	}
+}
+
+func TestRequestTimeoutCoversBody(t *testing.T) {
+	server := stallingServer(t, func(w http.ResponseWriter, release <-chan struct{}) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial "))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-time.After(300 * time.Millisecond):
+		}
+		w.Write([]byte("body"))
+	})
+
+	// Headers arrive right away, so only the overall timeout covers the slow body
+	withTimeouts(t, time.Second, time.Second, 100*time.Millisecond, 0)
+	response := makeRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	if response.Err != nil || string(response.Body) != "partial body" {
+		t.Fatalf("Expected the slow body to be read without an overall timeout, got %q (%v)", response.Body, response.Err)
+	}
+
+	withTimeouts(t, time.Second, time.Second, 0, 100*time.Millisecond)
+	response = makeRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	if response.Err == nil || !strings.Contains(response.Err.Error(), "Client.Timeout") {
+		t.Errorf("Expected the overall timeout to cut the body read, got %q (%v)", response.Body, response.Err)
+	}
+}
+
+func TestEndlessStreamIsTruncated(t *testing.T) {
+	server := stallingServer(t, func(w http.ResponseWriter, release <-chan struct{}) {
+		for i := 0; ; i++ {
+			select {
+			case <-release:
+				return
+			default:
+			}
+			if _, err := fmt.Fprintf(w, "event %d\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	})
+	withTimeouts(t, time.Second, time.Second, 0, 0)
+	defer func(previous int64) { maxResponseBytes = previous }(maxResponseBytes)
+	maxResponseBytes = 4096
+
+	first := makeRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	second := makeRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	if first.Err != nil || !first.Truncated || len(first.Body) != 4096 {
+		t.Fatalf("Expected the stream to be cut at the cap without an error, got %d bytes, truncated=%v (%v)", len(first.Body), first.Truncated, first.Err)
+	}
+	if responseChanged([]ResponseData{first}, second, true) {
+		t.Errorf("Expected two truncated reads of the same stream to compare equal")
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	server := stallingServer(t, func(w http.ResponseWriter, release <-chan struct{}) {
+		w.Write([]byte("event 0\n"))
+		w.(http.Flusher).Flush()
+		<-release
+	})
+	withTimeouts(t, time.Second, time.Second, 0, 0)
+	defer func(previous time.Duration) { streamTimeout = previous }(streamTimeout)
+	streamTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	response := makeRequest(Request{URL: server.URL, Method: "GET"}, url.Values{})
+	if response.Err != nil || !response.Truncated || string(response.Body) != "event 0\n" {
+		t.Errorf("Expected the stalled stream to be kept truncated at what was read, got %q, truncated=%v (%v)", response.Body, response.Truncated, response.Err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the read to be abandoned after the stream timeout, took %s", elapsed)
+	}
+}
+
+func bodyResponse(body []byte) *http.Response {
+	return &http.Response{Body: io.NopCloser(bytes.NewReader(body)), ContentLength: int64(len(body))}
+}
+
+func TestReadResponseBodyDoesNotAlias(t *testing.T) {
+	first, _, err := readResponseBody(bodyResponse([]byte("first body")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		readResponseBody(bodyResponse([]byte("another, longer body overwriting the buffer")))
+	}
+	if string(first) != "first body" {
+		t.Errorf("Expected a body to stay intact once its buffer is reused, got %q", first)
+	}
+}
+
+func BenchmarkReadResponseBody(b *testing.B) {
+	for _, size := range []int{16 << 10, 512 << 10} {
+		body := bytes.Repeat([]byte("<p>paramsmap</p>"), size/16)
+		b.Run(fmt.Sprintf("pooled-%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				readResponseBody(bodyResponse(body))
+			}
+		})
+		b.Run(fmt.Sprintf("readall-%dKiB", size>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				io.ReadAll(bodyResponse(body).Body)
+			}
+		})
+	}
+}
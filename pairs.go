@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// pairsEnabled turns on the second pass that looks for parameters which only
+// change the response when sent together.
+var pairsEnabled bool
+
+// maxPairCandidates bounds the candidate set of the pairwise pass. Every pair
+// costs one request, so n candidates cost n*(n-1)/2 requests: 20 candidates
+// already mean 190 extra requests.
+var maxPairCandidates = 20
+
+// nearMissTracker keeps the parameters of the chunks whose change vanished
+// when they were split: neither half changed the response on its own, as
+// happens when the parameters that only work together end up in both halves.
+type nearMissTracker struct {
+	mu     sync.Mutex
+	params map[string]bool
+}
+
+var nearMisses = &nearMissTracker{params: make(map[string]bool)}
+
+func (n *nearMissTracker) reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.params = make(map[string]bool)
+}
+
+func (n *nearMissTracker) add(params []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, param := range params {
+		n.params[param] = true
+	}
+}
+
+func (n *nearMissTracker) has(param string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.params[param]
+}
+
+// pairCandidates selects the parameters to combine in the pairwise pass, the
+// most promising first: those of near-miss chunks, then form parameters, as
+// they are known to be used by the application, then reflected parameters,
+// each in wordlist order. Parameters that are valid on their own are skipped
+// since any pair containing them would trivially change the response.
+func pairCandidates(formParams []string, params []string, validParams []string) []string {
+	skip := make(map[string]bool)
+	for _, param := range validParams {
+		skip[param] = true
+	}
+	inForm := make(map[string]bool)
+	for _, param := range formParams {
+		inForm[param] = true
+	}
+	score := func(param string) int {
+		score := 0
+		if nearMisses.has(param) {
+			score += 4
+		}
+		if inForm[param] {
+			score += 2
+		}
+		if reflected.has(param) {
+			score++
+		}
+		return score
+	}
+
+	var candidates []string
+	for _, param := range append(append([]string{}, formParams...), params...) {
+		if !skip[param] {
+			skip[param] = true
+			candidates = append(candidates, param)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return score(candidates[i]) > score(candidates[j])
+	})
+	if len(candidates) > maxPairCandidates {
+		candidates = candidates[:maxPairCandidates]
+	}
+	return candidates
+}
+
+// discoverParamPairs sends every pair of candidates in its own request and
+// returns the pairs that change the response. The pairs are requested at most
+// filterConcurrency at a time, like the chunks.
+func discoverParamPairs(request Request, candidates []string, initialResponses InitialResponses) [][2]string {
+	group := newBoundedGroup(filterConcurrency)
+	var mu sync.Mutex
+	var pairs [][2]string
+
+	logger.Info("Testing parameter pairs", "candidates", len(candidates), "requests", len(candidates)*(len(candidates)-1)/2)
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			pair := [2]string{candidates[i], candidates[j]}
+			group.run(func() {
+				if scanStopped() {
+					return
+				}
+				response := makeRequest(request, generateParams(pair[:]))
+				if budget.isExceeded() {
+					return
+				}
+				changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+				if detector.observe(response, changed) || !changed {
+					return
+				}
+				mu.Lock()
+				pairs = append(pairs, pair)
+				mu.Unlock()
+				logger.Info("Valid parameter pair discovered", "parameters", pair)
+			})
+		}
+	}
+	group.wait()
+	return pairs
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// scanMode selects what the candidates are injected as: "params" sends them as
+// request parameters, "path" as path segments and "graphql" as the fields or
+// arguments of GraphQL queries.
+var scanMode = "params"
+
+// pathRequest returns a copy of request whose URL carries segment in place of
+// the FUZZ marker, or appended as a new last path segment.
+func pathRequest(request Request, segment string) Request {
+	escaped := url.PathEscape(segment)
+	if strings.Contains(request.URL, fuzzMarker) {
+		request.URL = strings.Replace(request.URL, fuzzMarker, escaped, 1)
+		return request
+	}
+
+	parsedURL, err := url.Parse(request.URL)
+	if err != nil {
+		return request
+	}
+	parsedURL.Path = strings.TrimSuffix(parsedURL.Path, "/") + "/" + segment
+	parsedURL.RawPath = strings.TrimSuffix(parsedURL.EscapedPath(), "/"+escaped) + "/" + escaped
+	request.URL = parsedURL.String()
+	return request
+}
+
+// makePathRequest requests the path with segment, removing the echoes of the
+// requested URL and path from the body so pages echoing them ("user x not
+// found") compare equal regardless of the segment.
+func makePathRequest(request Request, segment string) ResponseData {
+	request = pathRequest(request, segment)
+	response := makeRequest(request, url.Values{})
+	if body := stripEchoedPath(response.Body, request.URL, segment); len(body) != len(response.Body) {
+		response.Body = body
+		response.BodyHash = sha256.Sum256(body)
+	}
+	return response
+}
+
+// stripEchoedPath removes rawURL, its path and segment from body, the segment
+// only where it stands on its own: "admin" is removed from "/users/admin" or
+// "user admin not found", but not from "administrators".
+func stripEchoedPath(body []byte, rawURL, segment string) []byte {
+	echoes := []string{rawURL}
+	if parsedURL, err := url.Parse(rawURL); err == nil {
+		echoes = append(echoes, parsedURL.EscapedPath(), parsedURL.Path)
+	}
+	echoes = append(echoes, url.PathEscape(segment), segment)
+	for _, echo := range echoes {
+		if echo != "" && echo != "/" {
+			body = removeWord(body, []byte(echo))
+		}
+	}
+	return body
+}
+
+// removeWord returns body without the occurrences of word that aren't part of
+// a longer word.
+func removeWord(body, word []byte) []byte {
+	if !bytes.Contains(body, word) {
+		return body
+	}
+	var stripped []byte
+	for {
+		i := bytes.Index(body, word)
+		if i < 0 {
+			return append(stripped, body...)
+		}
+		end := i + len(word)
+		standalone := (i == 0 || !isWordByte(body[i-1]) || !isWordByte(word[0])) &&
+			(end == len(body) || !isWordByte(body[end]) || !isWordByte(word[len(word)-1]))
+		if standalone {
+			stripped = append(stripped, body[:i]...)
+		} else {
+			stripped = append(stripped, body[:end]...)
+		}
+		body = body[end:]
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '-' || b >= 0x80 || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// discoverPathParams tests each candidate as a path segment. A request can only
+// carry one path value, so there is no chunking: every candidate costs one
+// request. The baselines use a random segment, so candidates are compared with
+// the response of a path that doesn't exist.
+func discoverPathParams(request Request, params []string) (Results, error) {
+	params = validParamNames(params)
+	primeCookies(pathRequest(request, randomString(12)))
+	var baselineResponses []ResponseData
+	for i := 0; i < numBaselines; i++ {
+		segment := randomString(12)
+		baselineResponses = append(baselineResponses, makePathRequest(request, segment))
+	}
+	freezeCookies()
+	initialResponses := newInitialResponses(baselineResponses)
+	recordBaseline(initialResponses.Responses[0])
+	if budget.isExceeded() {
+		return abortedResults(request, "path", ErrBudgetExceeded, budgetAbortReason), nil
+	}
+	if baselinesFailed(initialResponses.Responses) {
+		return Results{}, errTargetUnreachable(request)
+	}
+	if contentType := unsupportedContentType(initialResponses.Responses[0]); contentType != "" {
+		logger.Warn("The baseline content type isn't scanned, skipping the scan", "content_type", contentType, "scanned", scannedContentTypes)
+		return abortedResults(request, "path", ErrUnsupportedContentType, contentTypeAbortReason+" "+contentType), nil
+	}
+	if !initialResponses.AreConsistent {
+		comparisons := compareBaselines(initialResponses.Responses)
+		reason := inconsistentBaselinesReason(comparisons)
+		logger.Warn("Baseline responses differ significantly. The page appears to be too dynamic. Scanning will be skipped.", "reason", reason)
+		results := abortedResults(request, "path", ErrInconsistentBaselines, reason)
+		results.BaselineComparisons = comparisons
+		return results, nil
+	}
+
+	logger.Info("Testing path segments one per request", "count", len(params))
+	detector.reset()
+	evidence.reset()
+	titles.reset()
+	routes.reset(initialResponses.Responses)
+	serverErrors.reset(initialResponses.Responses)
+	group := newBoundedGroup(filterConcurrency)
+	var mu sync.Mutex
+	validParams := []string{}
+	for _, param := range params {
+		group.run(func() {
+			if scanStopped() {
+				return
+			}
+			response := makePathRequest(request, param)
+			if budget.isExceeded() {
+				return
+			}
+			changed := responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+			if detector.observe(response, changed) || !changed {
+				return
+			}
+			recordResponse([]string{param}, response, changed)
+			mu.Lock()
+			validParams = append(validParams, param)
+			mu.Unlock()
+			logger.Info("Valid path segment discovered", "segment", param)
+			notifyDiscovered(request, param)
+		})
+	}
+	group.wait()
+
+	if detector.isBlocked() {
+		return abortedResults(request, "path", ErrBlocked, wafAbortReason), nil
+	}
+	if evidenceDir != "" {
+		if err := evidence.write(evidenceDir, validParams); err != nil {
+			logger.Warn("Failed to save evidence", "error", err)
+		}
+	}
+	results := Results{
+		Params:        validParams,
+		FormParams:    []string{},
+		Findings:      buildFindings(request, validParams, params, nil),
+		ErrorParams:   serverErrors.filter(validParams),
+		TotalRequests: scanRequests(),
+		Injection:     "path",
+		Request:       request,
+	}
+	if budget.isExceeded() {
+		results.abort(ErrBudgetExceeded, budgetAbortReason)
+	}
+	return results, nil
+}
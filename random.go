@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	randv2 "math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// randomSeed seeds the random values of a scan: the canaries, the baseline
+// names, the user agents, the jitter and the -shuffle order. A non-zero seed
+// draws the same sequence of values, zero picks a new seed for every scan.
+// Concurrent requests draw from the sequence in the order they are scheduled,
+// so only the set of values is reproduced, not which request gets which.
+var randomSeed int64
+
+// scanRand is the source of the random values of a scan, safe for use by its
+// concurrent workers. Unseeded, it draws from the math/rand/v2 generators,
+// which are per thread so workers don't contend for a lock. Seeded, every
+// value comes from a single *rand.Rand behind a mutex, as reproducing the
+// sequence needs a single source.
+type scanRand struct {
+	seeded atomic.Bool
+	mu     sync.Mutex
+	r      *rand.Rand
+}
+
+// rng is the source of every random value of paramsmap.
+var rng = &scanRand{}
+
+// seed restarts the sequence of random values from seed, or makes them
+// unpredictable again when seed is zero. It must not be called while a scan
+// is running.
+func (s *scanRand) seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.r = nil
+	if seed != 0 {
+		s.r = rand.New(rand.NewSource(seed))
+	}
+	s.seeded.Store(seed != 0)
+}
+
+func (s *scanRand) Intn(n int) int {
+	if !s.seeded.Load() {
+		return randv2.IntN(n)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Intn(n)
+}
+
+func (s *scanRand) Int63n(n int64) int64 {
+	if !s.seeded.Load() {
+		return randv2.Int64N(n)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Int63n(n)
+}
+
+func (s *scanRand) Shuffle(n int, swap func(i, j int)) {
+	if !s.seeded.Load() {
+		randv2.Shuffle(n, swap)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.r.Shuffle(n, swap)
+}
+
+// resetRandom reseeds rng from randomSeed at the start of a scan, so scans
+// with the same seed draw the same sequence of values.
+func resetRandom() {
+	if randomSeed != 0 {
+		logger.Debug("Seeding random values", "seed", randomSeed)
+	}
+	rng.seed(randomSeed)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestScanRandConcurrentWorkers(t *testing.T) {
+	defer func(seed int64) { randomSeed = seed; resetRandom() }(randomSeed)
+	names := []string{"param1", "param2", "param3", "param4", "param5", "param6", "param7", "param8"}
+
+	for _, seed := range []int64{0, 42} {
+		randomSeed = seed
+		resetRandom()
+		var wg sync.WaitGroup
+		for worker := 0; worker < 16; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					params := generateParams(names)
+					if len(params) != len(names) || len(params.Get("param1")) != 8 {
+						t.Errorf("Expected a canary of 8 letters for every parameter, got: %v", params)
+						return
+					}
+					if delay := rng.Int63n(10); delay < 0 || delay >= 10 {
+						t.Errorf("Expected a value in [0, 10), got %d", delay)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkRandomStringParallel(b *testing.B) {
+	defer func(seed int64) { randomSeed = seed; resetRandom() }(randomSeed)
+	for _, seed := range []int64{0, 42} {
+		name := "unseeded"
+		if seed != 0 {
+			name = fmt.Sprintf("seed=%d", seed)
+		}
+		b.Run(name, func(b *testing.B) {
+			randomSeed = seed
+			resetRandom()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					randomString(8)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ClientRedirect is a redirect done by the baseline page itself rather than
+// with a 3xx status, which the scan followed to reach the application.
+type ClientRedirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"` // "meta" or "javascript"
+}
+
+// followedRedirect is the client-side redirect followed by the current scan.
+var followedRedirect *ClientRedirect
+
+// metaRefreshURL matches the delay and URL of the content of a refresh meta
+// tag, e.g. "0; url=/app".
+var metaRefreshURL = regexp.MustCompile(`(?i)^\s*(\d*)\s*[;,]\s*url\s*=\s*['"]?([^'"]+)`)
+
+// maxShimDelay is the longest refresh delay of a shim, pages refreshing later
+// are meant to be read first.
+const maxShimDelay = 5
+
+// maxShimText is the most text a shim shows besides its redirect, e.g.
+// "Redirecting, click here if nothing happens".
+const maxShimText = 200
+
+// scriptRedirect matches the simple JavaScript redirects of redirect shims:
+// assignments to location or location.href and location.replace or assign
+// calls with a string literal.
+var scriptRedirect = regexp.MustCompile(`\blocation(?:\.href)?\s*=\s*['"]([^'"]+)['"]|\blocation\.(?:replace|assign)\(\s*['"]([^'"]+)['"]\s*\)`)
+
+// clientRedirect returns the redirect of a baseline page that is only a shim
+// sending the browser elsewhere, with a refresh meta tag or a script: it has
+// next to no text and refreshes within maxShimDelay. Only GET requests without
+// a FUZZ marker in the URL are redirected, and only to the same host, so the
+// scan doesn't wander to e.g. a login provider.
+func clientRedirect(request Request, baseline ResponseData) *ClientRedirect {
+	if request.Method != "GET" || injectionPoint(request) == "url" || len(baseline.Body) == 0 {
+		return nil
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(baseline.Body))
+	if err != nil {
+		return nil
+	}
+
+	var target, kind string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
+		if !strings.EqualFold(meta.AttrOr("http-equiv", ""), "refresh") {
+			return true
+		}
+		match := metaRefreshURL.FindStringSubmatch(meta.AttrOr("content", ""))
+		if match == nil {
+			return true
+		}
+		if delay, _ := strconv.Atoi(match[1]); delay <= maxShimDelay {
+			target, kind = strings.TrimSpace(match[2]), "meta"
+			return false
+		}
+		return true
+	})
+	if target == "" {
+		doc.Find("script").EachWithBreak(func(_ int, script *goquery.Selection) bool {
+			if match := scriptRedirect.FindStringSubmatch(script.Text()); match != nil {
+				target, kind = match[1]+match[2], "javascript"
+				return false
+			}
+			return true
+		})
+	}
+	if target == "" {
+		return nil
+	}
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript").Remove()
+	if text := strings.Join(strings.Fields(body.Text()), " "); len(text) > maxShimText {
+		logger.Info("Not following the client-side redirect of a baseline page with content", "to", target, "text_length", len(text))
+		return nil
+	}
+
+	base, err := url.Parse(request.URL)
+	if err != nil {
+		return nil
+	}
+	destination, err := base.Parse(target)
+	if err != nil || (destination.Scheme != "http" && destination.Scheme != "https") {
+		return nil
+	}
+	destination.Fragment = ""
+	if destination.Host != base.Host {
+		logger.Info("Not following the client-side redirect of the baseline to another host", "to", destination.String())
+		return nil
+	}
+	if destination.String() == base.String() {
+		return nil
+	}
+	return &ClientRedirect{From: request.URL, To: destination.String(), Type: kind}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// redirectChain returns the URL resp was finally served from and the URLs
+// redirected from on the way there, in order.
+func redirectChain(resp *http.Response) (string, []string) {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return "", nil
+	}
+	var chain []string
+	for req := resp.Request; req.Response != nil && req.Response.Request != nil; req = req.Response.Request {
+		chain = append([]string{req.Response.Request.URL.String()}, chain...)
+	}
+	return resp.Request.URL.String(), chain
+}
+
+// routeKey returns the URL rawURL routes to, without the query parameters of
+// params, as sent or decorated, so redirects echoing the scanned parameters
+// route where the baseline does.
+func routeKey(rawURL string, params []string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	sent := url.Values{}
+	for _, param := range params {
+		sent.Set(param, "")
+	}
+	query := parsedURL.Query()
+	for name := range sent {
+		query.Del(name)
+	}
+	for name := range decorateParams(sent) {
+		query.Del(name)
+	}
+	parsedURL.RawQuery = query.Encode()
+	parsedURL.ForceQuery = false
+	parsedURL.Fragment = ""
+	return parsedURL.String()
+}
+
+// routeTracker keeps, for each parameter, the URL its first attributable
+// response landed on when redirects took it somewhere other than the
+// baseline, which makes the parameter an open redirect candidate. URLs are
+// compared by routeKey.
+type routeTracker struct {
+	mu       sync.Mutex
+	baseline string
+	params   map[string]string
+}
+
+var routes = &routeTracker{params: make(map[string]string)}
+
+func (r *routeTracker) reset(baselines []ResponseData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.baseline = ""
+	if len(baselines) > 0 {
+		r.baseline = routeKey(baselines[0].FinalURL, nil)
+	}
+	r.params = make(map[string]string)
+}
+
+// record keeps where response landed when it can be attributed to a single
+// parameter and was redirected elsewhere than the baseline.
+func (r *routeTracker) record(params []string, response ResponseData, changed bool) {
+	if !changed || len(params) != 1 || len(response.Redirects) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.params[params[0]]; !ok && routeKey(response.FinalURL, params) != r.baseline {
+		r.params[params[0]] = response.FinalURL
+		logger.Info("Parameter changed where the request is redirected", "parameter", params[0], "to", response.FinalURL)
+	}
+}
+
+func (r *routeTracker) get(param string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.params[param]
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Contexts a reflected value is found in, which decide whether it's
+// exploitable and which encoding the application has to apply.
+const (
+	contextHTMLText  = "html_text"
+	contextAttribute = "attribute"
+	contextScript    = "script"
+	contextURL       = "url"
+	contextJSON      = "json_string"
+)
+
+// urlAttributes are the attributes holding a URL, where a javascript: scheme
+// or an open redirect matter more than breaking out of the quotes.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "data": true,
+	"poster": true, "cite": true, "background": true, "srcset": true,
+}
+
+// reflectionContexts classifies where canary appears in a response body of the
+// given Content-Type. JSON bodies only have strings to reflect a canary in.
+// HTML bodies are parsed, so a canary is found in text nodes, attribute values
+// and scripts, including event handlers and javascript: URLs. Other bodies
+// have no context.
+func reflectionContexts(body []byte, contentType string, canary string) []string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case !bytes.Contains(body, []byte(canary)):
+		return nil
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || (mediaType == "" && json.Valid(body)):
+		return []string{contextJSON}
+	case mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml":
+		return nil
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var contexts []string
+	add := func(context string) {
+		if !slices.Contains(contexts, context) {
+			contexts = append(contexts, context)
+		}
+	}
+	doc.Find("*").Each(func(_ int, selection *goquery.Selection) {
+		node := selection.Get(0)
+		for _, attribute := range node.Attr {
+			if !strings.Contains(attribute.Val, canary) {
+				continue
+			}
+			key := strings.ToLower(attribute.Key)
+			value := strings.ToLower(strings.TrimSpace(attribute.Val))
+			switch {
+			case strings.HasPrefix(key, "on"), urlAttributes[key] && strings.HasPrefix(value, "javascript:"):
+				add(contextScript)
+			case urlAttributes[key]:
+				add(contextURL)
+			default:
+				add(contextAttribute)
+			}
+		}
+		selection.Contents().Each(func(_ int, child *goquery.Selection) {
+			if goquery.NodeName(child) != "#text" || !strings.Contains(child.Text(), canary) {
+				return
+			}
+			switch {
+			case node.Data == "script" && strings.Contains(selection.AttrOr("type", ""), "json"):
+				add(contextJSON)
+			case node.Data == "script":
+				add(contextScript)
+			default:
+				add(contextHTMLText)
+			}
+		})
+	})
+	return contexts
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReflectionContexts(t *testing.T) {
+	const canary = "qWeRtYuI"
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		expected    []string
+	}{
+		{"text node", `<html><body><p>You searched for qWeRtYuI</p></body></html>`, "text/html; charset=utf-8", []string{contextHTMLText}},
+		{"attribute", `<html><body><input name="q" value="qWeRtYuI"></body></html>`, "text/html", []string{contextAttribute}},
+		{"script", `<html><body><script>var q = "qWeRtYuI";</script></body></html>`, "text/html", []string{contextScript}},
+		{"event handler", `<html><body><img src="/a.png" onerror="log('qWeRtYuI')"></body></html>`, "text/html", []string{contextScript}},
+		{"url", `<html><body><a href="/next?page=qWeRtYuI">Next</a></body></html>`, "text/html", []string{contextURL}},
+		{"javascript url", `<html><body><a href="javascript:go('qWeRtYuI')">Go</a></body></html>`, "text/html", []string{contextScript}},
+		{"json script", `<html><body><script type="application/json">{"q":"qWeRtYuI"}</script></body></html>`, "text/html", []string{contextJSON}},
+		{"json body", `{"query":"qWeRtYuI","results":[]}`, "application/json", []string{contextJSON}},
+		{"json without content type", `{"query":"qWeRtYuI"}`, "", []string{contextJSON}},
+		{"several", `<html><head><title>qWeRtYuI</title></head><body><form action="/search?q=qWeRtYuI"></form></body></html>`, "", []string{contextHTMLText, contextURL}},
+		{"plain text", `You searched for qWeRtYuI`, "text/plain", nil},
+		{"not reflected", `<html><body><p>Nothing</p></body></html>`, "text/html", nil},
+	}
+	for _, test := range tests {
+		if contexts := reflectionContexts([]byte(test.body), test.contentType, canary); !reflect.DeepEqual(contexts, test.expected) {
+			t.Errorf("%s: expected contexts %v, got %v", test.name, test.expected, contexts)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// replayCapture answers the requests of a scan from recorded responses
+// instead of the network, so the diff and filtering logic can be run offline
+// and deterministically, e.g. over captured traffic in regression tests.
+var replayCapture *ReplayCapture
+
+// ReplayEntry is a recorded response, one JSON object per line of a capture
+// file. It answers the requests that send all its Params, or any request
+// when it has none, which makes it the baseline.
+type ReplayEntry struct {
+	Params     []string          `json:"params"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+}
+
+// ReplayCapture is an http.RoundTripper answering every request with the
+// first entry, in file order, whose parameters are all sent, falling back to
+// the first entry without parameters.
+type ReplayCapture struct {
+	Entries []ReplayEntry
+}
+
+// setReplay loads the -replay capture file.
+func setReplay(path string) error {
+	capture, err := loadReplay(path)
+	if err != nil {
+		return err
+	}
+	replayCapture = capture
+	return nil
+}
+
+// loadReplay reads a capture file.
+func loadReplay(path string) (*ReplayCapture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readReplay(file)
+}
+
+func readReplay(r io.Reader) (*ReplayCapture, error) {
+	capture := &ReplayCapture{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry ReplayEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("capture line %d: %w", lineNumber, err)
+		}
+		if entry.StatusCode == 0 {
+			entry.StatusCode = http.StatusOK
+		}
+		capture.Entries = append(capture.Entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading capture: %w", err)
+	}
+	if capture.baseline() == nil {
+		return nil, fmt.Errorf("the capture has no baseline response, an entry without params")
+	}
+	return capture, nil
+}
+
+func (c *ReplayCapture) baseline() *ReplayEntry {
+	for i := range c.Entries {
+		if len(c.Entries[i].Params) == 0 {
+			return &c.Entries[i]
+		}
+	}
+	return nil
+}
+
+// match returns the entry answering a request that sends the given names.
+func (c *ReplayCapture) match(sent map[string]bool) *ReplayEntry {
+	for i := range c.Entries {
+		entry := &c.Entries[i]
+		if len(entry.Params) > 0 && !slices.ContainsFunc(entry.Params, func(name string) bool { return !sent[name] }) {
+			return entry
+		}
+	}
+	return c.baseline()
+}
+
+func (c *ReplayCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	sent, err := sentParams(req)
+	if err != nil {
+		return nil, err
+	}
+	entry := c.match(sent)
+	header := http.Header{}
+	for name, value := range entry.Headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
+
+// sentParams returns the names of the parameters of a request: those of its
+// query, cookies and form, multipart or top-level JSON body.
+func sentParams(req *http.Request) (map[string]bool, error) {
+	sent := map[string]bool{}
+	for name := range req.URL.Query() {
+		sent[name] = true
+	}
+	for _, cookie := range req.Cookies() {
+		sent[cookie.Name] = true
+	}
+	if req.Body == nil {
+		return sent, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, mediaParams, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) == nil {
+			for name := range fields {
+				sent[name] = true
+			}
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		form, _ := url.ParseQuery(string(body))
+		for name := range form {
+			sent[name] = true
+		}
+	case mediaType == "multipart/form-data":
+		reader := multipart.NewReader(bytes.NewReader(body), mediaParams["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			sent[part.FormName()] = true
+		}
+	}
+	return sent, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const replayCaptureFile = `{"params": [], "headers": {"Content-Type": "text/html"}, "body": "<html><body><h1>Search</h1><p>No query</p></body></html>"}
+{"params": ["debug"], "headers": {"Content-Type": "text/html"}, "body": "<html><body><h1>Search</h1><pre>SQL: SELECT * FROM items</pre></body></html>"}
+{"params": ["admin"], "status_code": 403, "headers": {"Content-Type": "text/html"}, "body": "<html><body><h1>Forbidden</h1></body></html>"}
+`
+
+func TestDiscoverParamsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	if err := os.WriteFile(path, []byte(replayCaptureFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous *ReplayCapture) { replayCapture = previous }(replayCapture)
+	if err := setReplay(path); err != nil {
+		t.Fatalf("Unexpected error loading the capture: %v", err)
+	}
+
+	// Nothing listens there, every response comes from the capture
+	params := []string{"param1", "debug", "param2", "param3", "admin", "param4", "param5", "param6"}
+	for _, request := range []Request{
+		{URL: "http://replay.invalid/search", Method: "GET"},
+		{URL: "http://replay.invalid/search", Method: "POST", ContentType: "json"},
+	} {
+		var runs [][]string
+		for range 2 {
+			results, err := DiscoverParams(request, params, 4)
+			if err != nil {
+				t.Fatalf("%s: unexpected scan error: %v", request.Method, err)
+			}
+			sort.Strings(results.Params)
+			runs = append(runs, results.Params)
+		}
+		if !reflect.DeepEqual(runs[0], []string{"admin", "debug"}) || !reflect.DeepEqual(runs[0], runs[1]) {
+			t.Errorf("%s: expected admin and debug to be discovered on every run, got %v", request.Method, runs)
+		}
+	}
+}
+
+func TestReadReplayErrors(t *testing.T) {
+	if _, err := readReplay(strings.NewReader(`{"params": ["debug"], "body": "debug"}`)); err == nil {
+		t.Error("Expected a capture without baseline to be rejected")
+	}
+	if _, err := readReplay(strings.NewReader("{\"params\": []}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected the invalid line to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// reportSchemaVersion is the version of the JSON report format. It must be
+// bumped whenever fields are removed, renamed or change meaning, so consumers
+// of the report can detect incompatible changes.
+const reportSchemaVersion = 1
+
+// reportRequiredFields lists the top level fields every report of the current
+// schema version contains.
+var reportRequiredFields = []string{"schema_version", "params", "form_params", "findings", "total_requests", "aborted", "abort_reason", "request", "timing"}
+
+// stdoutReport is the -report value that writes the report to stdout.
+const stdoutReport = "-"
+
+var reportOutput io.Writer = os.Stdout
+
+// reportFormat selects how the report is written: "json" for a single
+// indented document, "ndjson" for one record per line, written as it is
+// encoded instead of being built in memory first, and "sarif" for the
+// findings alone as a SARIF log for code scanning dashboards.
+var reportFormat = "json"
+
+// saveReport writes the report of results to reportPath, creating its
+// directory, or to stdout.
+func saveReport(reportPath string, results Results) error {
+	results.SchemaVersion = reportSchemaVersion
+	write := writeJSONReport
+	switch reportFormat {
+	case "ndjson":
+		write = writeNDJSONReport
+	case "sarif":
+		write = writeSARIFReport
+	}
+
+	if reportPath == stdoutReport {
+		if err := write(reportOutput, results); err != nil {
+			return fmt.Errorf("writing the report to stdout: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(reportPath), 0755); err != nil {
+		return fmt.Errorf("creating the report directory: %w", err)
+	}
+	err := writeFileAtomic(reportPath, func(w io.Writer) error {
+		return write(w, results)
+	})
+	if err != nil {
+		return fmt.Errorf("writing the report to %s: %w", reportPath, err)
+	}
+
+	logger.Info("Report saved successfully", slog.String("path", reportPath))
+	return nil
+}
+
+func writeJSONReport(w io.Writer, results Results) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// ndjsonRecord is a line of an NDJSON report. The first line holds the scan
+// with its findings, pairs and warnings left out, each of which follows on a
+// line of its own.
+type ndjsonRecord struct {
+	Type    string     `json:"type"`
+	Scan    *Results   `json:"scan,omitempty"`
+	Finding *Finding   `json:"finding,omitempty"`
+	Pair    *[2]string `json:"pair,omitempty"`
+	Warning *Warning   `json:"warning,omitempty"`
+}
+
+func writeNDJSONReport(w io.Writer, results Results) error {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+
+	scan := results
+	scan.Findings, scan.ParamPairs, scan.Warnings = nil, nil, nil
+	if err := encoder.Encode(ndjsonRecord{Type: "scan", Scan: &scan}); err != nil {
+		return err
+	}
+	for i := range results.Findings {
+		if err := encoder.Encode(ndjsonRecord{Type: "finding", Finding: &results.Findings[i]}); err != nil {
+			return err
+		}
+	}
+	for i := range results.ParamPairs {
+		if err := encoder.Encode(ndjsonRecord{Type: "pair", Pair: &results.ParamPairs[i]}); err != nil {
+			return err
+		}
+	}
+	for i := range results.Warnings {
+		if err := encoder.Encode(ndjsonRecord{Type: "warning", Warning: &results.Warnings[i]}); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// writeFileAtomic writes a temporary file next to path and renames it into
+// place once it has been fully written and synced, so an interrupted write
+// never leaves a truncated file behind and the previous file survives.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath) // No-op once renamed
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// validateReport checks that data is a report of a supported schema version
+// with all of its required fields.
+func validateReport(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("report is not a JSON object: %w", err)
+	}
+
+	var version int
+	if err := json.Unmarshal(fields["schema_version"], &version); err != nil || version == 0 {
+		return fmt.Errorf("report has no valid schema_version")
+	}
+	if version > reportSchemaVersion {
+		return fmt.Errorf("unsupported report schema version %d, latest is %d", version, reportSchemaVersion)
+	}
+
+	for _, field := range reportRequiredFields {
+		if _, ok := fields[field]; !ok {
+			return fmt.Errorf("report is missing required field %q", field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestWriteFileAtomicKeepsPreviousReportOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	if err := os.WriteFile(path, []byte(`{"params":["page"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		w.Write([]byte(`{"params":["qu`))
+		return errors.New("interrupted")
+	})
+	if err == nil {
+		t.Fatalf("Expected the interrupted write to return an error")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != `{"params":["page"]}` {
+		t.Errorf("Expected the previous report to survive the partial write, got %q (%v)", data, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestSaveReportReplacesReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saveReport(path, Results{Params: []string{"page"}})
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) == "old" {
+		t.Errorf("Expected the report to be replaced, got %q (%v)", data, err)
+	}
+}
+
+func TestSaveReportCreatesDirectories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scans", "example.com", "report.json")
+	if err := saveReport(path, Results{Params: []string{"page"}}); err != nil {
+		t.Fatalf("Expected the report directories to be created, got %v", err)
+	}
+	if results, err := loadReport(path); err != nil || len(results.Params) != 1 {
+		t.Errorf("Expected the report to be saved, got %+v (%v)", results, err)
+	}
+
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveReport(filepath.Join(blocker, "report.json"), Results{}); err == nil {
+		t.Errorf("Expected an error when the report directory can't be created")
+	}
+}
+
+func TestSaveReportEmitsSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	saveReport(path, Results{Params: []string{"page"}})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"schema_version": 1`) {
+		t.Errorf("Expected the report to contain the schema version, got: %s", data)
+	}
+	if err := validateReport(data); err != nil {
+		t.Errorf("Expected the saved report to be valid: %v", err)
+	}
+
+	if err := validateReport([]byte(`{"params":["page"]}`)); err == nil {
+		t.Errorf("Expected a report without schema_version to be invalid")
+	}
+	if err := validateReport([]byte(`{"schema_version":99}`)); err == nil {
+		t.Errorf("Expected a report with a future schema version to be invalid")
+	}
+}
+
+func TestSaveReportToStdout(t *testing.T) {
+	defer func(previous io.Writer) { reportOutput = previous }(reportOutput)
+	var output bytes.Buffer
+	reportOutput = &output
+
+	saveReport(stdoutReport, Results{Params: []string{"page"}})
+
+	var results Results
+	if err := json.Unmarshal(output.Bytes(), &results); err != nil {
+		t.Fatalf("Expected the report on stdout to be valid JSON: %v", err)
+	}
+	if len(results.Params) != 1 || results.SchemaVersion != reportSchemaVersion {
+		t.Errorf("Unexpected report written to stdout: %+v", results)
+	}
+	if _, err := os.Stat(stdoutReport); err == nil {
+		t.Errorf("Expected no file named %q to be created", stdoutReport)
+	}
+}
+
+func TestBaselineReportDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.json")
+	saveReport(path, Results{Params: []string{"page", "debug", "user"}, FormParams: []string{}})
+
+	baseline, err := loadReport(path)
+	if err != nil {
+		t.Fatalf("Failed to load the saved report: %v", err)
+	}
+
+	drift := newDrift(path, baseline, Results{Params: []string{"user", "token", "page"}})
+	if strings.Join(drift.NewParams, ",") != "token" {
+		t.Errorf("Expected token to be new, got: %v", drift.NewParams)
+	}
+	if strings.Join(drift.RemovedParams, ",") != "debug" {
+		t.Errorf("Expected debug to be removed, got: %v", drift.RemovedParams)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"params":["page"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadReport(path); err == nil {
+		t.Errorf("Expected a report without schema version to be rejected")
+	}
+}
+
+func TestSaveReportNDJSON(t *testing.T) {
+	defer func(previous string) { reportFormat = previous }(reportFormat)
+	reportFormat = "ndjson"
+
+	path := filepath.Join(t.TempDir(), "report.ndjson")
+	saveReport(path, Results{
+		Params:     []string{"page", "debug"},
+		Findings:   []Finding{{Name: "page", Sources: []string{"wordlist"}}, {Name: "debug", Sources: []string{"form"}}},
+		ParamPairs: [][2]string{{"a", "b"}},
+		Warnings:   []Warning{{URL: "http://a.test/", Message: "scan aborted: possible WAF block"}},
+	})
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []ndjsonRecord
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var record ndjsonRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("Expected every line to be a JSON record: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 5 {
+		t.Fatalf("Expected a scan, two findings, a pair and a warning record, got %d", len(records))
+	}
+	if records[0].Type != "scan" || records[0].Scan.SchemaVersion != reportSchemaVersion || len(records[0].Scan.Params) != 2 || records[0].Scan.Findings != nil {
+		t.Errorf("Unexpected scan record: %+v", records[0].Scan)
+	}
+	if records[1].Type != "finding" || records[1].Finding.Name != "page" || records[2].Finding.Name != "debug" {
+		t.Errorf("Unexpected finding records: %+v %+v", records[1].Finding, records[2].Finding)
+	}
+	if records[3].Type != "pair" || *records[3].Pair != [2]string{"a", "b"} {
+		t.Errorf("Unexpected pair record: %+v", records[3])
+	}
+	if records[4].Type != "warning" || records[4].Warning.URL != "http://a.test/" {
+		t.Errorf("Unexpected warning record: %+v", records[4])
+	}
+}
+
+// checkSARIF validates a SARIF log against the official SARIF 2.1.0 schema in
+// testdata, then checks what the schema can't express: the rule references of
+// the results and their absolute location URIs.
+func checkSARIF(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+	schema, err := jsonschema.Compile(filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	if err != nil {
+		t.Fatalf("Failed to compile the SARIF schema: %v", err)
+	}
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("SARIF log is not valid JSON: %v", err)
+	}
+	if err := schema.Validate(document); err != nil {
+		t.Fatalf("SARIF log doesn't match the 2.1.0 schema: %v", err)
+	}
+
+	var log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string           `json:"name"`
+					Rules []map[string]any `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []map[string]any `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("SARIF log is not valid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || !strings.Contains(log.Schema, "sarif-2.1.0") || len(log.Runs) != 1 {
+		t.Fatalf("Expected a SARIF 2.1.0 log with one run, got version %q, schema %q and %d runs", log.Version, log.Schema, len(log.Runs))
+	}
+	driver := log.Runs[0].Tool.Driver
+	if driver.Name == "" {
+		t.Errorf("Expected the tool driver to have a name")
+	}
+	ruleIDs := make([]string, len(driver.Rules))
+	for i, rule := range driver.Rules {
+		ruleIDs[i], _ = rule["id"].(string)
+		if ruleIDs[i] == "" {
+			t.Errorf("Expected rule %d to have an id", i)
+		}
+	}
+
+	for i, result := range log.Runs[0].Results {
+		message, _ := result["message"].(map[string]any)
+		if text, _ := message["text"].(string); text == "" {
+			t.Errorf("Result %d: a message text is required", i)
+		}
+		index, _ := result["ruleIndex"].(float64)
+		if int(index) >= len(ruleIDs) || result["ruleId"] != ruleIDs[int(index)] {
+			t.Errorf("Result %d: rule %v at index %v isn't in the driver rules %v", i, result["ruleId"], index, ruleIDs)
+		}
+		locations, _ := result["locations"].([]any)
+		for _, location := range locations {
+			physical, _ := location.(map[string]any)["physicalLocation"].(map[string]any)
+			artifact, _ := physical["artifactLocation"].(map[string]any)
+			uri, _ := artifact["uri"].(string)
+			if parsed, err := url.Parse(uri); err != nil || !parsed.IsAbs() {
+				t.Errorf("Result %d: invalid location URI %q", i, uri)
+			}
+		}
+	}
+	return log.Runs[0].Results
+}
+
+func TestSaveReportSARIF(t *testing.T) {
+	defer func(previous string) { reportFormat = previous }(reportFormat)
+	reportFormat = "sarif"
+
+	path := filepath.Join(t.TempDir(), "report.sarif")
+	saveReport(path, Results{
+		Params: []string{"id", "q", "debug"},
+		Findings: []Finding{
+			{Name: "id", Sources: []string{"wordlist"}, ErrorTriggering: true, Reflected: true, URL: "http://a.test/item", Method: "GET"},
+			{Name: "q", Sources: []string{"wordlist", "form"}, Reflected: true, URL: "http://a.test/item", Method: "GET"},
+			{Name: "debug", Sources: []string{"wordlist"}, URL: "http://a.test/item", Method: "GET"},
+		},
+		FormTargets: []Results{{
+			Findings: []Finding{{Name: "remember", Sources: []string{"wordlist"}, URL: "http://a.test/login", Method: "POST"}},
+		}},
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := checkSARIF(t, data)
+	if len(results) != 4 {
+		t.Fatalf("Expected a result per finding, form targets included, got %d", len(results))
+	}
+	expected := []struct{ rule, level, uri string }{
+		{"error-triggering-parameter", "error", "http://a.test/item"},
+		{"reflected-parameter", "warning", "http://a.test/item"},
+		{"hidden-parameter", "note", "http://a.test/item"},
+		{"hidden-parameter", "note", "http://a.test/login"},
+	}
+	for i, result := range results {
+		uri := result["locations"].([]any)[0].(map[string]any)["physicalLocation"].(map[string]any)["artifactLocation"].(map[string]any)["uri"]
+		if result["ruleId"] != expected[i].rule || result["level"] != expected[i].level || uri != expected[i].uri {
+			t.Errorf("Result %d: expected %+v, got %v %v %v", i, expected[i], result["ruleId"], result["level"], uri)
+		}
+	}
+	if text := results[1]["message"].(map[string]any)["text"].(string); !strings.Contains(text, `"q"`) || !strings.Contains(text, "reflected") || !strings.Contains(text, "wordlist and form") {
+		t.Errorf("Expected the message to name the parameter and its evidence, got %q", text)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Warning is a condition of a scan worth reporting that didn't make it fail,
+// attributed to the URL of the scanned target.
+type Warning struct {
+	URL     string `json:"url"`
+	Message string `json:"message"`
+}
+
+// scanWarnings lists the conditions that weaken or cut short a scan.
+func scanWarnings(results Results) []Warning {
+	var warnings []Warning
+	add := func(message string) {
+		warnings = append(warnings, Warning{URL: results.Request.URL, Message: message})
+	}
+	if results.Aborted {
+		add("scan aborted: " + results.AbortReason)
+	}
+	if results.FailedRequests > 0 {
+		add(fmt.Sprintf("%d requests failed, the parameters they carried may have been missed", results.FailedRequests))
+	}
+	if tooManyIgnored(results.IgnoredResponses, results.TotalRequests) {
+		add(fmt.Sprintf("%d responses had an ignored status code, the parameters they carried may have been missed", results.IgnoredResponses))
+	}
+	if len(results.Clusters) > 0 {
+		add(fmt.Sprintf("%d parameter clusters reached the maximum depth, each holds valid parameters to find by hand", len(results.Clusters)))
+	}
+	if results.Degraded {
+		add("baseline responses differ, only status codes and reflections were compared")
+	}
+	switch results.CatchAll {
+	case catchAllReacts:
+		add("the application reacts to unknown parameters")
+	case catchAllReflects:
+		add("the application reflects unknown parameters")
+	}
+	return warnings
+}
+
+// abortedResults returns the results of a scan of request aborted on
+// condition for reason before any parameter was found. injection is empty
+// for parameter scans.
+func abortedResults(request Request, injection string, condition error, reason string) Results {
+	results := Results{
+		Params:        []string{},
+		FormParams:    []string{},
+		TotalRequests: scanRequests(),
+		Injection:     injection,
+		Request:       request,
+	}
+	results.abort(condition, reason)
+	return results
+}
+
+// Merge combines the results of two scans, e.g. of different URLs or methods.
+// Parameters are deduplicated and request counts summed, while findings and
+// warnings keep the target they belong to. The merged results are only
+// aborted when both scans were, a single aborted scan is left as a warning.
+func (r Results) Merge(other Results) Results {
+	merged := r
+	merged.Params = appendUnique(r.Params, other.Params)
+	merged.FormParams = appendUnique(r.FormParams, other.FormParams)
+	merged.AllParams = appendUnique(r.AllParams, other.AllParams)
+	if len(merged.AllParams) == 0 {
+		merged.AllParams = nil
+	}
+	merged.ReflectedParams = appendUnique(r.ReflectedParams, other.ReflectedParams)
+	sort.Strings(merged.ReflectedParams)
+	merged.ErrorParams = appendUnique(r.ErrorParams, other.ErrorParams)
+	sort.Strings(merged.ErrorParams)
+	merged.HashParams = appendUnique(r.HashParams, other.HashParams)
+	merged.Clusters = append(append([][]string{}, r.Clusters...), other.Clusters...)
+	if len(merged.Clusters) == 0 {
+		merged.Clusters = nil
+	}
+	merged.TotalRequests = r.TotalRequests + other.TotalRequests
+	merged.SavedRequests = r.SavedRequests + other.SavedRequests
+	merged.FailedRequests = r.FailedRequests + other.FailedRequests
+	merged.IgnoredResponses = r.IgnoredResponses + other.IgnoredResponses
+	merged.Degraded = r.Degraded || other.Degraded
+	merged.Aborted = r.Aborted && other.Aborted
+	if !merged.Aborted {
+		merged.AbortReason = ""
+	}
+
+	type findingKey struct{ name, url, method string }
+	seen := make(map[findingKey]bool)
+	merged.Findings = []Finding{}
+	for _, finding := range append(append([]Finding{}, r.Findings...), other.Findings...) {
+		key := findingKey{finding.Name, finding.URL, finding.Method}
+		if !seen[key] {
+			seen[key] = true
+			merged.Findings = append(merged.Findings, finding)
+		}
+	}
+
+	seenPairs := make(map[[2]string]bool)
+	merged.ParamPairs = nil
+	for _, pair := range append(append([][2]string{}, r.ParamPairs...), other.ParamPairs...) {
+		if !seenPairs[pair] {
+			seenPairs[pair] = true
+			merged.ParamPairs = append(merged.ParamPairs, pair)
+		}
+	}
+
+	merged.Warnings = append(append([]Warning{}, r.Warnings...), other.Warnings...)
+	if len(merged.Warnings) == 0 {
+		merged.Warnings = nil
+	}
+	merged.Timing = mergeTiming(r.Timing, other.Timing)
+	return merged
+}
+
+// mergeTiming spans both timings, ignoring one that was never set.
+func mergeTiming(a, b Timing) Timing {
+	if a.StartTime.IsZero() {
+		return b
+	}
+	if b.StartTime.IsZero() {
+		return a
+	}
+	start, end := a.StartTime, a.EndTime
+	if b.StartTime.Before(start) {
+		start = b.StartTime
+	}
+	if b.EndTime.After(end) {
+		end = b.EndTime
+	}
+	return newTiming(start, end, a.Requests+b.Requests)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResultsMergeOverlapping(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := Results{
+		Params:          []string{"page", "debug"},
+		FormParams:      []string{"page"},
+		TotalRequests:   10,
+		Findings:        []Finding{{Name: "page", URL: "http://a.test/", Method: "GET"}, {Name: "debug", URL: "http://a.test/", Method: "GET"}},
+		ReflectedParams: []string{"page"},
+		Timing:          newTiming(start, start.Add(2*time.Second), 10),
+	}
+	b := Results{
+		Params:          []string{"debug", "token"},
+		FormParams:      []string{},
+		TotalRequests:   6,
+		Findings:        []Finding{{Name: "debug", URL: "http://a.test/", Method: "GET"}, {Name: "token", URL: "http://a.test/", Method: "GET"}},
+		ReflectedParams: []string{"debug"},
+		Timing:          newTiming(start.Add(time.Second), start.Add(4*time.Second), 6),
+	}
+
+	merged := a.Merge(b)
+	if !reflect.DeepEqual(merged.Params, []string{"page", "debug", "token"}) {
+		t.Errorf("Expected the parameters to be deduplicated, got: %v", merged.Params)
+	}
+	if !reflect.DeepEqual(merged.FormParams, []string{"page"}) {
+		t.Errorf("Unexpected form parameters: %v", merged.FormParams)
+	}
+	if !reflect.DeepEqual(merged.ReflectedParams, []string{"debug", "page"}) {
+		t.Errorf("Unexpected reflected parameters: %v", merged.ReflectedParams)
+	}
+	if merged.TotalRequests != 16 || merged.Timing.Requests != 16 {
+		t.Errorf("Expected the requests to be summed, got %d and %d", merged.TotalRequests, merged.Timing.Requests)
+	}
+	if len(merged.Findings) != 3 {
+		t.Errorf("Expected the duplicate finding to be dropped, got: %+v", merged.Findings)
+	}
+	if merged.Timing.DurationSeconds != 4 {
+		t.Errorf("Expected the timing to span both scans, got %v seconds", merged.Timing.DurationSeconds)
+	}
+	if len(a.Params) != 2 || len(a.Findings) != 2 {
+		t.Errorf("Expected Merge to leave the receiver untouched, got: %v", a.Params)
+	}
+}
+
+func TestResultsMergeDisjointTargets(t *testing.T) {
+	a := Results{
+		Params:        []string{"page"},
+		FormParams:    []string{},
+		TotalRequests: 5,
+		Findings:      []Finding{{Name: "page", URL: "http://a.test/", Method: "GET"}},
+		Request:       Request{URL: "http://a.test/", Method: "GET"},
+	}
+	b := Results{
+		Params:        []string{},
+		FormParams:    []string{},
+		TotalRequests: 3,
+		Aborted:       true,
+		AbortReason:   "possible WAF block",
+		Request:       Request{URL: "http://b.test/", Method: "POST"},
+	}
+	b.Warnings = scanWarnings(b)
+	c := Results{
+		Params:        []string{"page"},
+		FormParams:    []string{},
+		TotalRequests: 4,
+		Findings:      []Finding{{Name: "page", URL: "http://c.test/", Method: "POST"}},
+		Request:       Request{URL: "http://c.test/", Method: "POST"},
+	}
+
+	merged := a.Merge(b).Merge(c)
+	if !reflect.DeepEqual(merged.Params, []string{"page"}) {
+		t.Errorf("Unexpected parameters: %v", merged.Params)
+	}
+	if merged.TotalRequests != 12 {
+		t.Errorf("Expected 12 requests, got %d", merged.TotalRequests)
+	}
+	if len(merged.Findings) != 2 || merged.Findings[0].URL != "http://a.test/" || merged.Findings[1].URL != "http://c.test/" {
+		t.Errorf("Expected a finding per target, got: %+v", merged.Findings)
+	}
+	if merged.Aborted {
+		t.Errorf("Expected the merged results not to be aborted when only one scan was")
+	}
+	if len(merged.Warnings) != 1 || merged.Warnings[0].URL != "http://b.test/" {
+		t.Errorf("Expected the aborted scan to be kept as a warning, got: %+v", merged.Warnings)
+	}
+}
+
+func TestResultsErr(t *testing.T) {
+	if err := (Results{}).Err(); err != nil {
+		t.Errorf("Expected no error for a completed scan, got %v", err)
+	}
+
+	tests := []struct {
+		reason   string
+		expected error
+	}{
+		{"Baseline responses differ significantly: lowest similarity 0.412, threshold 0.950", ErrInconsistentBaselines},
+		{"unsupported content type image/png", ErrUnsupportedContentType},
+		{"possible WAF block", ErrBlocked},
+		{"request budget exceeded", ErrBudgetExceeded},
+	}
+	for _, test := range tests {
+		results := abortedResults(Request{}, "", test.expected, test.reason)
+		err := results.Err()
+		if !errors.Is(err, test.expected) || err.Error() != "scan aborted: "+test.reason {
+			t.Errorf("%s: expected an error wrapping %v, got %v", test.reason, test.expected, err)
+		}
+	}
+
+	// The condition isn't read back from a report
+	data, _ := json.Marshal(abortedResults(Request{}, "", ErrBlocked, wafAbortReason))
+	var results Results
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	var abort *AbortError
+	if err := results.Err(); !errors.As(err, &abort) || abort.Reason != wafAbortReason || abort.Err != nil {
+		t.Errorf("Expected an AbortError without condition for results read from a report, got %v", err)
+	}
+}
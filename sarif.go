@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SARIF rules a finding is reported under, from the most to the least
+// severe. A finding is reported once, under the first rule it matches.
+var sarifRules = []sarifRule{
+	{
+		ID:                   "error-triggering-parameter",
+		Name:                 "ErrorTriggeringParameter",
+		ShortDescription:     sarifMessage{Text: "Parameter triggers a server-side error"},
+		FullDescription:      sarifMessage{Text: "The parameter is read by the application and makes it return an error page, e.g. a stack trace or a database error."},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+	{
+		ID:                   "reflected-parameter",
+		Name:                 "ReflectedParameter",
+		ShortDescription:     sarifMessage{Text: "Parameter value reflected in the response"},
+		FullDescription:      sarifMessage{Text: "The parameter is read by the application and its value appears in the response, a candidate injection sink."},
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	},
+	{
+		ID:                   "hidden-parameter",
+		Name:                 "HiddenParameter",
+		ShortDescription:     sarifMessage{Text: "Hidden parameter changes the response"},
+		FullDescription:      sarifMessage{Text: "The parameter is read by the application: sending it changes the response."},
+		DefaultConfiguration: sarifConfiguration{Level: "note"},
+	},
+}
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// writeSARIFReport writes the findings of the scan, and of the form targets
+// it led to, as the results of a single SARIF run.
+func writeSARIFReport(w io.Writer, results Results) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "paramsmap",
+			InformationURI: "https://github.com/pyneda/paramsmap",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+	for _, scan := range append([]Results{results}, results.FormTargets...) {
+		for _, finding := range scan.Findings {
+			run.Results = append(run.Results, newSARIFResult(finding))
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+func newSARIFResult(finding Finding) sarifResult {
+	index := 2
+	evidence := "sending it changes the response"
+	switch {
+	case finding.ErrorTriggering:
+		index, evidence = 0, "sending it makes the response an error page"
+	case finding.Reflected:
+		index, evidence = 1, "its value is reflected in the response"
+		if len(finding.ReflectionContexts) > 0 {
+			evidence += " (" + strings.Join(finding.ReflectionContexts, ", ") + ")"
+		}
+	}
+	rule := sarifRules[index]
+
+	message := fmt.Sprintf("Parameter %q is read by %s %s: %s.", finding.Name, finding.Method, finding.URL, evidence)
+	if len(finding.Sources) > 0 {
+		message += " Found in the " + strings.Join(finding.Sources, " and ") + "."
+	}
+	return sarifResult{
+		RuleID:    rule.ID,
+		RuleIndex: index,
+		Level:     rule.DefaultConfiguration.Level,
+		Message:   sarifMessage{Text: message},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: finding.URL},
+		}}},
+		Properties: map[string]any{
+			"parameter": finding.Name,
+			"method":    finding.Method,
+			"sources":   finding.Sources,
+			"contexts":  finding.ReflectionContexts,
+		},
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serveWordlists is the directory the wordlists named by API scans are read
+// from. Clients can only pick a file by name, never a path.
+var serveWordlists = "."
+
+// serveToken is the bearer token API clients must send. It defaults to the
+// PARAMSMAP_API_TOKEN environment variable, and a random one is generated and
+// logged when neither is set.
+var serveToken string
+
+// serveHosts restricts the hosts API scans can target, e.g. example.com or
+// *.example.com for its subdomains. Any host can be scanned when it's empty.
+var serveHosts []string
+
+// setServeHosts parses the comma separated -serve-hosts list.
+func setServeHosts(list string) error {
+	serveHosts = nil
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			serveHosts = append(serveHosts, host)
+		}
+	}
+	return nil
+}
+
+// maxChunkSize bounds the chunk size API clients can ask for.
+const maxChunkSize = 10000
+
+// progressInterval is how often the progress stream reports the running scan.
+var progressInterval = time.Second
+
+var methodPattern = regexp.MustCompile(`^[A-Z]+$`)
+
+// ScanRequest is the body of a POST /scans request.
+type ScanRequest struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	Data        string            `json:"data"`
+	ContentType string            `json:"content_type"`
+	Headers     map[string]string `json:"headers"`
+	Wordlist    string            `json:"wordlist"`
+	ChunkSize   int               `json:"chunk_size"`
+	MaxRequests int               `json:"max_requests"`
+}
+
+// ScanProgress is a line of the GET /scans/progress stream.
+type ScanProgress struct {
+	Running        bool    `json:"running"`
+	URL            string  `json:"url,omitempty"`
+	Method         string  `json:"method,omitempty"`
+	Requests       int64   `json:"requests"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// scanServer runs scans submitted over HTTP. Scans share the scanner's global
+// state, so a single scan runs at a time and submissions made meanwhile are
+// rejected rather than queued.
+type scanServer struct {
+	wordlistDir  string
+	token        string
+	allowedHosts []string
+	slot         chan struct{}
+
+	mu      sync.Mutex
+	request Request
+	started time.Time
+}
+
+func newScanServer(wordlistDir string, token string, allowedHosts []string) *scanServer {
+	return &scanServer{wordlistDir: wordlistDir, token: token, allowedHosts: allowedHosts, slot: make(chan struct{}, 1)}
+}
+
+func (s *scanServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /scans", s.handleScan)
+	mux.HandleFunc("GET /scans/progress", s.handleProgress)
+	return s.authenticate(mux)
+}
+
+// authenticate only lets the requests bearing the token through.
+func (s *scanServer) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, errors.New("a valid bearer token is required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serve runs the scan API on addr until it fails.
+func serve(addr string) int {
+	addr = listenAddress(addr)
+	token := serveToken
+	if token == "" {
+		token = os.Getenv("PARAMSMAP_API_TOKEN")
+	}
+	if token == "" {
+		token = newToken()
+		logger.Info("Generated the API token, set -serve-token or PARAMSMAP_API_TOKEN to choose one", "token", token)
+	}
+	logger.Info("Serving the scan API", "address", addr, "wordlists", serveWordlists, "hosts", serveHosts)
+	if err := http.ListenAndServe(addr, newScanServer(serveWordlists, token, serveHosts).handler()); err != nil {
+		logger.Error("API server failed", "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// listenAddress binds an address without a host, such as :8080, to the
+// loopback interface. Serving other machines takes an explicit host, e.g.
+// 0.0.0.0:8080.
+func listenAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// newToken returns a random API token. It doesn't come from rng, whose values
+// are predictable with -seed.
+func newToken() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// hostAllowed reports whether API scans may target host.
+func (s *scanServer) hostAllowed(host string) bool {
+	if len(s.allowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range s.allowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scanServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	var scan ScanRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&scan); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid scan request: %w", err))
+		return
+	}
+	request, err := s.validateScan(&scan)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	select {
+	case s.slot <- struct{}{}:
+		defer func() { <-s.slot }()
+	default:
+		writeJSONError(w, http.StatusTooManyRequests, errors.New("a scan is already running"))
+		return
+	}
+
+	// Wordlist hints are global, so the previous scan's must not leak into this one
+	paramValues, paramLocations = map[string]string{}, map[string]string{}
+	candidates, err := loadWordlist(filepath.Join(s.wordlistDir, scan.Wordlist))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	defer func(previous int) { maxRequests = previous }(maxRequests)
+	if scan.MaxRequests > 0 {
+		maxRequests = scan.MaxRequests
+	}
+	s.setRunning(request)
+	defer s.setRunning(Request{})
+	logger.Info("Starting API scan", "url", request.URL, "method", request.Method, "wordlist", scan.Wordlist)
+
+	results, err := scanCandidates(request, candidates, scan.ChunkSize)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	results.SchemaVersion = reportSchemaVersion
+	writeJSON(w, http.StatusOK, results)
+}
+
+// validateScan checks the scan request, filling in the defaults, and returns
+// the request to scan.
+func (s *scanServer) validateScan(scan *ScanRequest) (Request, error) {
+	parsedURL, err := url.Parse(scan.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return Request{}, fmt.Errorf("invalid URL %q: an http or https URL is required", scan.URL)
+	}
+	if !s.hostAllowed(parsedURL.Hostname()) {
+		return Request{}, fmt.Errorf("host %q is not allowed to be scanned", parsedURL.Hostname())
+	}
+
+	scan.Method = strings.ToUpper(scan.Method)
+	if scan.Method == "" {
+		scan.Method = "GET"
+	}
+	if !methodPattern.MatchString(scan.Method) {
+		return Request{}, fmt.Errorf("invalid method %q", scan.Method)
+	}
+	if scan.ContentType == "" {
+		scan.ContentType = "form"
+	}
+	if err := validateData(scan.Data, scan.ContentType); err != nil {
+		return Request{}, err
+	}
+
+	for name, value := range scan.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") || strings.ContainsAny(value, "\r\n") {
+			return Request{}, fmt.Errorf("invalid header %q", name)
+		}
+	}
+
+	if scan.Wordlist == "" || scan.Wordlist != filepath.Base(scan.Wordlist) || strings.HasPrefix(scan.Wordlist, ".") {
+		return Request{}, fmt.Errorf("invalid wordlist %q: a file name in the wordlist directory is required", scan.Wordlist)
+	}
+
+	if scan.ChunkSize == 0 {
+		scan.ChunkSize = 1000
+	}
+	if scan.ChunkSize < 1 || scan.ChunkSize > maxChunkSize {
+		return Request{}, fmt.Errorf("invalid chunk size %d: it must be between 1 and %d", scan.ChunkSize, maxChunkSize)
+	}
+	if scan.MaxRequests < 0 {
+		return Request{}, fmt.Errorf("invalid max_requests %d", scan.MaxRequests)
+	}
+
+	return Request{
+		URL:         scan.URL,
+		Method:      scan.Method,
+		Data:        scan.Data,
+		ContentType: scan.ContentType,
+		Headers:     scan.Headers,
+	}, nil
+}
+
+func (s *scanServer) setRunning(request Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.request = request
+	s.started = time.Now()
+}
+
+func (s *scanServer) progress() ScanProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.request.URL == "" {
+		return ScanProgress{}
+	}
+	return ScanProgress{
+		Running:        true,
+		URL:            s.request.URL,
+		Method:         s.request.Method,
+		Requests:       budget.requests(),
+		ElapsedSeconds: time.Since(s.started).Seconds(),
+	}
+}
+
+// handleProgress streams the progress of the running scan as JSON lines until
+// it finishes or the client goes away. The last line has running set to false.
+func (s *scanServer) handleProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		progress := s.progress()
+		if err := encoder.Encode(progress); err != nil || !progress.Running {
+			return
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
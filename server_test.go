@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testToken is the API token of the test servers.
+const testToken = "s3cr3t"
+
+func postScan(t *testing.T, serverURL string, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest("POST", serverURL+"/scans", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+func TestServeScan(t *testing.T) {
+	startMockServer()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "params.txt"), []byte("param1\nsession\nrandom1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	api := newScanServer(dir, testToken, nil)
+	server := httptest.NewServer(api.handler())
+	defer server.Close()
+
+	resp, data := postScan(t, server.URL, `{"url":"http://localhost:8181","wordlist":"params.txt","chunk_size":2}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the scan to succeed, got %d: %s", resp.StatusCode, data)
+	}
+	var results Results
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("Expected the results as JSON: %v", err)
+	}
+	if !contains(results.Params, "session") || contains(results.Params, "param1") {
+		t.Errorf("Unexpected parameters: %v", results.Params)
+	}
+	if err := validateReport(data); err != nil {
+		t.Errorf("Expected the results to be a valid report: %v", err)
+	}
+
+	// A second scan only reports its own requests
+	for i := 0; i < 2; i++ {
+		start := totalRequests.Load()
+		resp, data = postScan(t, server.URL, `{"url":"http://localhost:8181","wordlist":"params.txt","chunk_size":2}`)
+		sent := int(totalRequests.Load() - start)
+		var again Results
+		if resp.StatusCode != http.StatusOK || json.Unmarshal(data, &again) != nil || again.TotalRequests != sent {
+			t.Errorf("Scan %d: expected the %d requests it sent, got %d: %s", i+2, sent, again.TotalRequests, data)
+		}
+	}
+
+	progress := getProgress(t, server.URL)
+	defer progress.Body.Close()
+	lines, _ := io.ReadAll(progress.Body)
+	if !bytes.Equal(bytes.TrimSpace(lines), []byte(`{"running":false,"requests":0,"elapsed_seconds":0}`)) {
+		t.Errorf("Expected a single idle progress line, got: %s", lines)
+	}
+}
+
+func TestServeScanRejectsInvalidRequests(t *testing.T) {
+	api := newScanServer(t.TempDir(), testToken, []string{"localhost", "*.example.com"})
+	server := httptest.NewServer(api.handler())
+	defer server.Close()
+
+	for _, body := range []string{
+		`{"url":"http://localhost:8181","wordlist":"../../etc/passwd"}`,
+		`{"url":"http://localhost:8181","wordlist":".hidden"}`,
+		`{"url":"file:///etc/passwd","wordlist":"params.txt"}`,
+		`{"url":"http://localhost:8181","wordlist":"params.txt","method":"GET /x"}`,
+		`{"url":"http://localhost:8181","wordlist":"params.txt","headers":{"X-A":"1\r\nX-B: 2"}}`,
+		`{"url":"http://localhost:8181","wordlist":"params.txt","chunk_size":100000}`,
+		`{"url":"http://localhost:8181","wordlist":"params.txt","unknown":true}`,
+		`{"url":"http://localhost:8181","wordlist":"params.txt","content_type":"multipart","data":"name=%zz"}`,
+		`{"url":"http://127.0.0.1:8181","wordlist":"params.txt"}`,
+		`{"url":"http://example.com","wordlist":"params.txt"}`,
+		`{"url":"http://evil-example.com","wordlist":"params.txt"}`,
+	} {
+		if resp, data := postScan(t, server.URL, body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d: %s", body, resp.StatusCode, data)
+		}
+	}
+
+	api.slot <- struct{}{}
+	defer func() { <-api.slot }()
+	if resp, _ := postScan(t, server.URL, `{"url":"http://localhost:8181","wordlist":"params.txt"}`); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected a scan submitted while another runs to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func getProgress(t *testing.T, serverURL string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest("GET", serverURL+"/scans/progress", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServeScanProgressWhileRunning(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "params.txt"), []byte("param1\nrandom1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("<html><body><h1>Slow</h1></body></html>"))
+	}))
+	defer target.Close()
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 10 * time.Millisecond
+
+	api := newScanServer(dir, testToken, nil)
+	server := httptest.NewServer(api.handler())
+	defer server.Close()
+
+	scan, _ := http.NewRequest("POST", server.URL+"/scans", strings.NewReader(`{"url":"`+target.URL+`","wordlist":"params.txt"}`))
+	scan.Header.Set("Authorization", "Bearer "+testToken)
+	done := make(chan int)
+	go func() {
+		resp, err := http.DefaultClient.Do(scan)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	for api.progress().URL == "" {
+		time.Sleep(time.Millisecond)
+	}
+
+	progress := getProgress(t, server.URL)
+	defer progress.Body.Close()
+	lines := bufio.NewScanner(progress.Body)
+	var first ScanProgress
+	if !lines.Scan() || json.Unmarshal(lines.Bytes(), &first) != nil || !first.Running || first.URL != target.URL || first.Method != "GET" {
+		t.Errorf("Expected the running scan to be reported, got: %s", lines.Bytes())
+	}
+	close(release)
+	var last ScanProgress
+	for lines.Scan() {
+		last = ScanProgress{}
+		json.Unmarshal(lines.Bytes(), &last)
+	}
+	if last.Running {
+		t.Errorf("Expected the stream to end once the scan finished, got: %+v", last)
+	}
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("Expected the scan to succeed, got status %d", status)
+	}
+}
+
+func TestServeRequiresToken(t *testing.T) {
+	server := httptest.NewServer(newScanServer(t.TempDir(), testToken, nil).handler())
+	defer server.Close()
+
+	for _, authorization := range []string{"", "Bearer wrong", testToken} {
+		req, _ := http.NewRequest("GET", server.URL+"/scans/progress", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected authorization %q to be rejected, got %d", authorization, resp.StatusCode)
+		}
+	}
+
+	if addr := listenAddress(":8080"); addr != "127.0.0.1:8080" {
+		t.Errorf("Expected a port alone to bind to the loopback interface, got %s", addr)
+	}
+	if addr := listenAddress("0.0.0.0:8080"); addr != "0.0.0.0:8080" {
+		t.Errorf("Expected an explicit host to be kept, got %s", addr)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// errorPagePatterns fingerprint the error pages and messages of common
+// frameworks, databases and runtimes, which are often served with a 200.
+var errorPagePatterns = regexp.MustCompile(`Traceback \(most recent call last\)|Fatal error:|Stack trace:|Unhandled Exception|Internal Server Error|You have an error in your SQL syntax|ORA-\d{5}|SQLSTATE\[|Microsoft OLE DB Provider|at [\w$.]+\([\w]+\.java:\d+\)|Warning: \w+\(\): .+ on line \d+`)
+
+// errorTracker remembers the parameters whose response, when sent on their
+// own, was a server error although none of the baselines was. Such a
+// parameter is often more interesting than one that merely changes the page:
+// it may reach an injection point or crash the application.
+type errorTracker struct {
+	mu        sync.Mutex
+	baselines []ResponseData
+	params    map[string]bool
+}
+
+var serverErrors = &errorTracker{params: make(map[string]bool)}
+
+func (e *errorTracker) reset(baselines []ResponseData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.baselines = baselines
+	e.params = make(map[string]bool)
+}
+
+// record tags the parameter when response can be attributed to it alone and
+// is an error the baselines weren't.
+func (e *errorTracker) record(params []string, response ResponseData, changed bool) {
+	if !changed || len(params) != 1 || !e.triggersError(response) {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.params[params[0]] {
+		e.params[params[0]] = true
+		logger.Warn("Parameter triggers a server error", "parameter", params[0], "status", response.StatusCode)
+	}
+}
+
+// triggersError reports whether response is a 5xx while no baseline was, or
+// contains an error fingerprint no baseline contained.
+func (e *errorTracker) triggersError(response ResponseData) bool {
+	e.mu.Lock()
+	baselines := e.baselines
+	e.mu.Unlock()
+
+	fingerprint := errorPagePatterns.Find(response.Body)
+	serverError := response.StatusCode >= 500
+	for _, baseline := range baselines {
+		if baseline.StatusCode >= 500 {
+			serverError = false
+		}
+		if fingerprint != nil && errorPagePatterns.Match(baseline.Body) {
+			fingerprint = nil
+		}
+	}
+	return serverError || fingerprint != nil
+}
+
+func (e *errorTracker) has(param string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.params[param]
+}
+
+// filter returns the parameters of params that triggered an error, sorted.
+func (e *errorTracker) filter(params []string) []string {
+	kept := []string{}
+	for _, param := range params {
+		if e.has(param) {
+			kept = append(kept, param)
+		}
+	}
+	sort.Strings(kept)
+	return kept
+}
+
+// recordResponse keeps what a response attributable to a single parameter
+// tells about it: the evidence proving the parameter, whether it triggered a
+// server error, the heading of the page it led to and where it redirected.
+func recordResponse(params []string, response ResponseData, changed bool) {
+	evidence.record(params, response, changed)
+	serverErrors.record(params, response, changed)
+	titles.record(params, response, changed)
+	routes.record(params, response, changed)
+}
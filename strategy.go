@@ -0,0 +1,125 @@
+package main
+
+import "sync/atomic"
+
+// strategy selects how valid chunks are narrowed down to single parameters:
+// "bisect" requests both halves at every level, while "confirm" infers the
+// right half from the left one and batch-confirms the inferred parameters.
+var strategy = "bisect"
+
+// savedRequests counts the requests skipped by the confirm strategy, net of
+// the requests spent confirming inferred parameters.
+var savedRequests int64
+
+func filterPart(request Request, part []string, initialResponses InitialResponses) []string {
+	if strategy != "confirm" {
+		return recursiveFilter(request, part, initialResponses, 0)
+	}
+	observed, inferred := inferredFilter(request, part, initialResponses, true, 0)
+	return append(observed, confirmCandidates(request, inferred, initialResponses)...)
+}
+
+// inferredFilter bisects params like recursiveFilter, but only requests the
+// right half when the left half changed. When the left half is unchanged the
+// change is assumed to come from the right half, which saves a request per
+// level but leaves the parameter unverified when it is the last one standing.
+// It keeps the safeguards of recursiveFilter: the depth cap, the direct test
+// of small chunks and the re-check of changed halves on dynamic pages.
+func inferredFilter(request Request, params []string, initialResponses InitialResponses, verified bool, depth int) (observed, inferred []string) {
+	if scanStopped() {
+		return nil, nil
+	}
+	if len(params) == 1 {
+		if verified {
+			return params, nil
+		}
+		return nil, params
+	}
+	if len(params) <= directThreshold {
+		return directFilter(request, params, initialResponses), nil
+	}
+	if depth >= maxRecursionDepth {
+		logger.Warn("Maximum recursion depth reached, reporting the parameters as a cluster to review", "depth", depth, "parameters", params)
+		clusters.add(params)
+		return nil, nil
+	}
+	mid := len(params) / 2
+	left := params[:mid]
+	right := params[mid:]
+
+	leftChanged, stop := testPart(request, left, initialResponses)
+	if stop {
+		return nil, nil
+	}
+	if !leftChanged || !changePersists(request, left, initialResponses) {
+		atomic.AddInt64(&savedRequests, 1)
+		return inferredFilter(request, right, initialResponses, false, depth+1)
+	}
+
+	observed, inferred = inferredFilter(request, left, initialResponses, true, depth+1)
+
+	rightChanged, stop := testPart(request, right, initialResponses)
+	if stop {
+		return nil, nil
+	}
+	if rightChanged && changePersists(request, right, initialResponses) {
+		rightObserved, rightInferred := inferredFilter(request, right, initialResponses, true, depth+1)
+		observed = append(observed, rightObserved...)
+		inferred = append(inferred, rightInferred...)
+	}
+	return observed, inferred
+}
+
+// testPart requests params and reports whether the response changed, recording
+// the response. stop is set when the budget is spent or the response looks
+// like a block, and the part must not be narrowed down any further.
+func testPart(request Request, params []string, initialResponses InitialResponses) (changed bool, stop bool) {
+	response := makeRequestRetrying(request, generateParams(params))
+	if budget.isExceeded() {
+		return false, true
+	}
+	changed = responseChanged(initialResponses.Responses, response, initialResponses.SameBody)
+	if detector.observe(response, changed) {
+		return false, true
+	}
+	recordResponse(params, response, changed)
+	return changed, false
+}
+
+// confirmCandidates verifies the inferred parameters with a single combined
+// request and only falls back to one request per parameter when the combined
+// response is ambiguous, i.e. it changed but there are several candidates.
+func confirmCandidates(request Request, candidates []string, initialResponses InitialResponses) []string {
+	if len(candidates) == 0 || scanStopped() {
+		return nil
+	}
+
+	atomic.AddInt64(&savedRequests, -1)
+	changed, stop := testPart(request, candidates, initialResponses)
+	if stop {
+		return nil
+	}
+	if !changed {
+		logger.Debug("Discarding inferred parameters that did not change the response", "parameters", candidates)
+		return nil
+	}
+	if len(candidates) == 1 {
+		return candidates
+	}
+
+	var confirmed []string
+	for _, candidate := range candidates {
+		if scanStopped() {
+			return confirmed
+		}
+		atomic.AddInt64(&savedRequests, -1)
+		changed, stop := testPart(request, []string{candidate}, initialResponses)
+		if stop {
+			return confirmed
+		}
+		if changed {
+			confirmed = append(confirmed, candidate)
+		}
+	}
+	return confirmed
+}
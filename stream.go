@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxResponseBytes caps the body read from a response. Bodies over it are cut
+// to the cap and compared as they are, which keeps an endless stream from
+// being read forever while baselines and candidates, cut at the same length,
+// stay comparable. A value of zero means no limit.
+var maxResponseBytes int64 = 5 << 20
+
+// streamTimeout bounds reading a body of unknown length when no -timeout is
+// set, so a stream that trickles without reaching maxResponseBytes can't
+// block a worker forever. The body read until then is kept as a truncated
+// one. With -timeout the client deadline applies instead.
+var streamTimeout = 30 * time.Second
+
+// bodyBuffers are the buffers bodies are read into. A body is then copied out
+// at its exact size, so the buffer's growth is paid once per worker rather
+// than once per response, and the bodies kept for diffing never share memory
+// with a buffer that goes back to the pool.
+var bodyBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBuffer is the capacity above which a buffer is left to the garbage
+// collector, so a single huge response doesn't stay pinned in the pool.
+const maxPooledBuffer = 8 << 20
+
+// readResponseBody reads the body of resp within maxResponseBytes and
+// streamTimeout, reporting whether it was truncated by either.
+func readResponseBody(resp *http.Response) ([]byte, bool, error) {
+	var reader io.Reader = resp.Body
+	if maxResponseBytes > 0 {
+		reader = io.LimitReader(resp.Body, maxResponseBytes+1)
+	}
+
+	var expired atomic.Bool
+	if resp.ContentLength < 0 && requestTimeout == 0 && streamTimeout > 0 {
+		timer := time.AfterFunc(streamTimeout, func() {
+			expired.Store(true)
+			resp.Body.Close()
+		})
+		defer timer.Stop()
+	}
+
+	buffer := bodyBuffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer func() {
+		if buffer.Cap() <= maxPooledBuffer {
+			bodyBuffers.Put(buffer)
+		}
+	}()
+	_, err := buffer.ReadFrom(reader)
+	body := buffer.Bytes()
+	truncated := maxResponseBytes > 0 && int64(len(body)) > maxResponseBytes
+	if truncated {
+		body = body[:maxResponseBytes]
+	}
+	body = append([]byte{}, body...)
+
+	if expired.Load() {
+		logger.Debug("Truncating response stream that stalled", "timeout", streamTimeout, "bytes", len(body))
+		return body, true, nil
+	}
+	if truncated {
+		logger.Debug("Truncating response body", "limit", maxResponseBytes)
+		return body, true, nil
+	}
+	return body, false, err
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// fuzzMarker is the placeholder that candidate parameters are spliced into.
+const fuzzMarker = "FUZZ"
+
+// injectionPoint returns where the candidate parameters are injected: the
+// first FUZZ marker found in the URL ("url"), a header value ("header:Name")
+// or a non-GET body ("body"), falling back to the query string ("query").
+func injectionPoint(request Request) string {
+	if strings.Contains(request.URL, fuzzMarker) {
+		return "url"
+	}
+	names := make([]string, 0, len(request.Headers))
+	for name := range request.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.Contains(request.Headers[name], fuzzMarker) {
+			return "header:" + name
+		}
+	}
+	if methodHasBody(request.Method) && strings.Contains(request.Data, fuzzMarker) {
+		return "body"
+	}
+	return "query"
+}
+
+// renderHeaderValue replaces the marker in a header value with the parameters,
+// using cookie syntax for the Cookie header and query syntax for any other.
+func renderHeaderValue(name string, value string, params url.Values) string {
+	if !strings.EqualFold(name, "Cookie") {
+		return strings.Replace(value, fuzzMarker, params.Encode(), 1)
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var cookies []string
+	for _, key := range keys {
+		cookies = append(cookies, key+"="+params.Get(key))
+	}
+	return strings.Replace(value, fuzzMarker, strings.Join(cookies, "; "), 1)
+}
+
+// renderBodyTemplate replaces the marker in the template with the parameters
+// encoded for the content type: JSON object members, XML elements or form
+// pairs. The baseline renders with no parameters, leaving e.g. an empty object.
+func renderBodyTemplate(template string, contentType string, params url.Values) string {
+	members, separator := bodyMembers(contentType, params)
+	if members == "" && separator != "" {
+		template = trimSeparator(template, separator)
+	}
+	return strings.Replace(template, fuzzMarker, members, 1)
+}
+
+// bodyMembers encodes the parameters for the content type, ordered by name,
+// and returns them joined by the separator of the content type.
+func bodyMembers(contentType string, params url.Values) (string, string) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var members []string
+	for _, key := range keys {
+		values := params[key]
+		switch contentType {
+		case "json":
+			name, _ := json.Marshal(key)
+			encoded, _ := json.Marshal(values[0])
+			if len(values) > 1 {
+				encoded, _ = json.Marshal(values)
+			}
+			members = append(members, string(name)+":"+string(encoded))
+		case "xml":
+			for _, value := range values {
+				var escaped bytes.Buffer
+				xml.EscapeText(&escaped, []byte(value))
+				members = append(members, "<"+key+">"+escaped.String()+"</"+key+">")
+			}
+		default:
+			for _, value := range values {
+				members = append(members, url.QueryEscape(key)+"="+url.QueryEscape(value))
+			}
+		}
+	}
+
+	separator := "&"
+	switch contentType {
+	case "json":
+		separator = ","
+	case "xml":
+		separator = ""
+	}
+	return strings.Join(members, separator), separator
+}
+
+// trimSeparator removes the separator next to the marker of a template, so
+// a baseline rendered without parameters keeps the shape of the candidates
+// rather than e.g. leaving a trailing comma that makes the JSON invalid.
+func trimSeparator(template string, separator string) string {
+	before, after, found := strings.Cut(template, fuzzMarker)
+	if !found {
+		return template
+	}
+	if trimmed := strings.TrimRight(before, " \t\r\n"); strings.HasSuffix(trimmed, separator) {
+		return strings.TrimSuffix(trimmed, separator) + fuzzMarker + after
+	}
+	if trimmed := strings.TrimLeft(after, " \t\r\n"); strings.HasPrefix(trimmed, separator) {
+		return before + fuzzMarker + strings.TrimPrefix(trimmed, separator)
+	}
+	return template
+}
+
+// multipartBoundary separates the parts of multipart bodies. It's fixed so
+// the bodies of the baselines and of the candidates only differ by the parts
+// of the candidates.
+const multipartBoundary = "paramsmap-4f1c2e9a7b3d"
+
+// contentTypeHeader returns the Content-Type header for a content type, which
+// is either one of the known names or a media type used as is.
+func contentTypeHeader(contentType string) string {
+	switch contentType {
+	case "json":
+		return "application/json"
+	case "xml":
+		return "application/xml"
+	case "multipart":
+		return "multipart/form-data; boundary=" + multipartBoundary
+	}
+	if strings.Contains(contentType, "/") {
+		return contentType
+	}
+	return "application/x-www-form-urlencoded"
+}
+
+// getBody sends a body with GET requests too, for APIs that read one.
+var getBody bool
+
+// methodHasBody reports whether requests with method carry a body.
+func methodHasBody(method string) bool {
+	return method != "GET" || getBody
+}
+
+// requestBody builds the body of a request for its content type. Templates get
+// the parameters spliced in, JSON, XML and custom media types are sent as given
+// and forms get the parameters appended when they are injected in the query.
+func requestBody(request Request, params url.Values, injection string) string {
+	if injection == "body" {
+		return renderBodyTemplate(request.Data, request.ContentType, params)
+	}
+	if !formContentType(request.ContentType) || injection != "query" {
+		return request.Data
+	}
+	return appendFormBody(request.Data, params)
+}
+
+// formContentType reports whether bodies of the content type are made of
+// name and value pairs, which the candidates are added to.
+func formContentType(contentType string) bool {
+	return contentType == "multipart" || contentTypeHeader(contentType) == "application/x-www-form-urlencoded"
+}
+
+// buildBody returns the body of a request and its Content-Type. Baselines go
+// through it with no params, so their body only differs from the candidates'
+// by the candidates. Multipart bodies are written from the form encoded pairs
+// of the request data and the candidates.
+func buildBody(request Request, params url.Values, injection string, located url.Values) (string, string) {
+	var body string
+	if injection == "body" {
+		// Located params belong to the same template as the injected ones
+		merged := url.Values{}
+		for key, values := range params {
+			merged[key] = values
+		}
+		for key, values := range located {
+			merged[key] = values
+		}
+		body = requestBody(request, merged, injection)
+	} else {
+		body = mergeBody(requestBody(request, params, injection), request.ContentType, located)
+	}
+	if request.ContentType == "multipart" {
+		body = multipartBody(body)
+	}
+	return body, contentTypeHeader(request.ContentType)
+}
+
+// validateData checks that the request data can be sent as contentType.
+// Multipart bodies are written from the form encoded pairs of the data, which
+// must parse.
+func validateData(data string, contentType string) error {
+	if contentType != "multipart" {
+		return nil
+	}
+	if _, err := url.ParseQuery(data); err != nil {
+		return fmt.Errorf("multipart data must be form encoded pairs: %w", err)
+	}
+	return nil
+}
+
+// multipartBody encodes the form encoded pairs of form as multipart parts,
+// ordered by name. The request data was checked by validateData.
+func multipartBody(form string) string {
+	values, _ := url.ParseQuery(form)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.SetBoundary(multipartBoundary)
+	for _, key := range keys {
+		for _, value := range values[key] {
+			writer.WriteField(key, value)
+		}
+	}
+	writer.Close()
+	return body.String()
+}
+
+// appendFormBody appends the form encoded params to body.
+func appendFormBody(body string, params url.Values) string {
+	if len(params) == 0 {
+		return body
+	}
+	if body == "" {
+		return params.Encode()
+	}
+	return body + "&" + params.Encode()
+}
+
+// mergeBody adds the params to a body of the content type: members of a JSON
+// object, elements of the XML root or else form pairs.
+func mergeBody(body string, contentType string, params url.Values) string {
+	if len(params) == 0 {
+		return body
+	}
+	members, _ := bodyMembers(contentType, params)
+	switch contentType {
+	case "json":
+		trimmed := strings.TrimSpace(body)
+		if trimmed == "" {
+			return "{" + members + "}"
+		}
+		if object, found := strings.CutSuffix(trimmed, "}"); found {
+			object = strings.TrimRight(object, " \t\r\n")
+			if !strings.HasSuffix(object, "{") {
+				object += ","
+			}
+			return object + members + "}"
+		}
+	case "xml":
+		if end := strings.LastIndex(body, "</"); end >= 0 {
+			return body[:end] + members + body[end:]
+		}
+		return body + members
+	}
+	return appendFormBody(body, params)
+}
+
+// locateParams splits off the params whose wordlist entry says where they are
+// sent, keyed by location, leaving the rest for the request's injection point.
+// Body params are sent in the query when the request has no body.
+func locateParams(params url.Values, method string) (url.Values, map[string]url.Values) {
+	if len(paramLocations) == 0 {
+		return params, nil
+	}
+	injected := url.Values{}
+	located := make(map[string]url.Values)
+	for key, values := range params {
+		location, ok := paramLocations[key]
+		if !ok {
+			injected[key] = values
+			continue
+		}
+		if location == "body" && !methodHasBody(method) {
+			location = "query"
+		}
+		if located[location] == nil {
+			located[location] = url.Values{}
+		}
+		located[location][key] = values
+	}
+	return injected, located
+}
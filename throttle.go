@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// requestDelay is the base delay enforced between any two requests of a scan.
+var requestDelay time.Duration
+
+// requestJitter randomizes every delay within [requestDelay-requestJitter,
+// requestDelay+requestJitter] so the request timing doesn't form a pattern.
+var requestJitter time.Duration
+
+// throttle spaces requests out across all goroutines by handing out send slots.
+type throttle struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+var requestThrottle = &throttle{}
+
+// wait blocks until the caller is allowed to send its request.
+func (t *throttle) wait() {
+	if requestDelay <= 0 && requestJitter <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.next
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next = slot.Add(nextDelay())
+	t.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
+
+func nextDelay() time.Duration {
+	delay := requestDelay
+	if requestJitter > 0 {
+		delay += time.Duration(rng.Int63n(int64(2*requestJitter)+1)) - requestJitter
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
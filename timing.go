@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// timingDeviations is how many standard deviations above the mean baseline
+// latency a response must take to be changed when timing is compared.
+var timingDeviations = 3.0
+
+// timingMinDelay is the least a response must be slower than the mean
+// baseline latency to be changed when timing is compared, so the jitter of a
+// few fast baselines with almost no deviation isn't taken for a delay.
+var timingMinDelay = 500 * time.Millisecond
+
+// latencyThreshold is the latency above which a response of the current scan
+// is changed when timing is compared, set from the baselines.
+var latencyThreshold time.Duration
+
+// latencyStats returns the mean and standard deviation of the latencies of
+// the responses that got one.
+func latencyStats(responses []ResponseData) (mean, stddev time.Duration) {
+	var latencies []float64
+	for _, response := range responses {
+		if response.Err == nil {
+			latencies = append(latencies, float64(response.Elapsed))
+		}
+	}
+	if len(latencies) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, latency := range latencies {
+		sum += latency
+	}
+	average := sum / float64(len(latencies))
+	var variance float64
+	for _, latency := range latencies {
+		variance += (latency - average) * (latency - average)
+	}
+	variance /= float64(len(latencies))
+	return time.Duration(average), time.Duration(math.Sqrt(variance))
+}
+
+// newLatencyThreshold returns the latency above which a response is an
+// anomaly compared to the baselines: their mean latency plus
+// timingDeviations standard deviations, and at least timingMinDelay more.
+func newLatencyThreshold(baselines []ResponseData) time.Duration {
+	mean, stddev := latencyStats(baselines)
+	threshold := mean + time.Duration(timingDeviations*float64(stddev))
+	threshold = max(threshold, mean+timingMinDelay)
+	logger.Info("Baseline latency", "mean", mean, "stddev", stddev, "threshold", threshold)
+	return threshold
+}
+
+// latencyAnomaly reports whether a response took longer than the
+// latencyThreshold of the scan.
+func latencyAnomaly(response ResponseData) bool {
+	return response.Elapsed > latencyThreshold
+}
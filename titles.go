@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// pageHeading is the title and first h1 of a response, which tell at a glance
+// what a parameter turned the page into ("Admin Panel", "Debug console").
+type pageHeading struct {
+	Title   string
+	Heading string
+}
+
+// titleTracker keeps the heading of the first response attributable to each
+// parameter alone.
+type titleTracker struct {
+	mu       sync.Mutex
+	headings map[string]pageHeading
+}
+
+var titles = &titleTracker{headings: make(map[string]pageHeading)}
+
+func (t *titleTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.headings = make(map[string]pageHeading)
+}
+
+// record parses the heading of response when it changed and can be
+// attributed to a single parameter that has none yet.
+func (t *titleTracker) record(params []string, response ResponseData, changed bool) {
+	if !changed || len(params) != 1 {
+		return
+	}
+	t.mu.Lock()
+	_, ok := t.headings[params[0]]
+	t.mu.Unlock()
+	if ok {
+		return
+	}
+
+	heading := extractHeading(response.Body)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.headings[params[0]]; !ok {
+		t.headings[params[0]] = heading
+	}
+}
+
+func (t *titleTracker) get(param string) pageHeading {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.headings[param]
+}
+
+// extractHeading returns the whitespace-normalized title and first h1 of an
+// HTML body, empty for other bodies.
+func extractHeading(body []byte) pageHeading {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return pageHeading{}
+	}
+	return pageHeading{
+		Title:   strings.Join(strings.Fields(doc.Find("title").First().Text()), " "),
+		Heading: strings.Join(strings.Fields(doc.Find("h1").First().Text()), " "),
+	}
+}
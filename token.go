@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// tokenRefreshCommand is a shell command printing a fresh bearer token.
+var tokenRefreshCommand string
+
+// tokenRefreshURL is fetched to obtain a fresh bearer token, either as a JSON
+// object with an access_token field or as the plain response body.
+var tokenRefreshURL string
+
+// tokenRefresher holds the current bearer token. When expired tokens cause a
+// storm of 401 responses, the first goroutine to notice refreshes the token
+// while the others wait for it instead of reporting the 401s as changes.
+type tokenRefresher struct {
+	mu         sync.Mutex
+	token      string
+	generation int
+}
+
+var tokens = &tokenRefresher{}
+
+func tokenRefreshEnabled() bool {
+	return tokenRefreshCommand != "" || tokenRefreshURL != ""
+}
+
+// apply sets the current bearer token on req and returns its generation.
+func (t *tokenRefresher) apply(req *http.Request) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.generation
+}
+
+// refresh replaces the token unless it was already refreshed after generation.
+func (t *tokenRefresher) refresh(generation int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.generation != generation {
+		return nil
+	}
+
+	logger.Info("Received 401 response, refreshing the bearer token")
+	token, err := fetchToken()
+	if err != nil {
+		return err
+	}
+	t.token = token
+	t.generation++
+	return nil
+}
+
+func fetchToken() (string, error) {
+	var output []byte
+	if tokenRefreshCommand != "" {
+		var err error
+		output, err = exec.Command("sh", "-c", tokenRefreshCommand).Output()
+		if err != nil {
+			return "", fmt.Errorf("running token refresh command: %w", err)
+		}
+	} else {
+		resp, err := httpClient.Get(tokenRefreshURL)
+		if err != nil {
+			return "", fmt.Errorf("requesting token refresh URL: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("token refresh URL returned status %d", resp.StatusCode)
+		}
+		output, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("reading token refresh response: %w", err)
+		}
+	}
+
+	var payload struct {
+		AccessToken string `json:"access_token"`
+	}
+	token := strings.TrimSpace(string(output))
+	if json.Unmarshal(output, &payload) == nil && payload.AccessToken != "" {
+		token = payload.AccessToken
+	}
+	token = strings.TrimPrefix(token, "Bearer ")
+	if token == "" {
+		return "", fmt.Errorf("token refresh returned an empty token")
+	}
+	return token, nil
+}
+
+// needsTokenRefresh reports whether resp was rejected because of an expired
+// token, refreshing it so the request can be retried.
+func needsTokenRefresh(resp *http.Response, generation int) bool {
+	if !tokenRefreshEnabled() || resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	if err := tokens.refresh(generation); err != nil {
+		logger.Warn("Failed to refresh the bearer token", "error", err)
+		return false
+	}
+	return true
+}
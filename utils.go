@@ -1,17 +1,58 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"crypto/tls"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"net/url"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
+// headerFlags collects repeated "Name: value" command line headers.
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	var headers []string
+	for name, value := range h {
+		headers = append(headers, name+": "+value)
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h headerFlags) Set(header string) error {
+	name, value, found := strings.Cut(header, ":")
+	if !found || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	return nil
+}
+
+// shuffleParams randomizes the order of the candidates before they are
+// chunked, so chunks aren't composed the same way on every scan. A non-zero
+// randomSeed makes the order reproducible.
+var shuffleParams bool
+
+// shuffledParams returns a copy of params in random order. With a randomSeed
+// the order only depends on the seed, not on the values drawn before it.
+func shuffledParams(params []string) []string {
+	shuffled := append([]string{}, params...)
+	swap := func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	if randomSeed != 0 {
+		rand.New(rand.NewSource(randomSeed)).Shuffle(len(shuffled), swap)
+		return shuffled
+	}
+	rng.Shuffle(len(shuffled), swap)
+	return shuffled
+}
+
 func chunkParams(params []string, chunkSize int) [][]string {
 	var chunks [][]string
 	for i := 0; i < len(params); i += chunkSize {
@@ -24,28 +65,6 @@ func chunkParams(params []string, chunkSize int) [][]string {
 	return chunks
 }
 
-func loadWordlist(wordlist string) []string {
-	file, err := os.Open(wordlist)
-	if err != nil {
-		logger.Error("Failed to open wordlist", "error", err)
-		os.Exit(1)
-	}
-	defer file.Close()
-
-	var params []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		params = append(params, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		logger.Error("Failed to read wordlist", "error", err)
-		os.Exit(1)
-	}
-
-	return params
-}
-
 func randomUserAgent() string {
 	userAgents := []string{
 		"Mozilla/5.0 (Windows NT 6.1; WOW64; rv:40.0) Gecko/20100101 Firefox/40.1",
@@ -54,35 +73,137 @@ func randomUserAgent() string {
 		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
 	}
-	return userAgents[rand.Intn(len(userAgents))]
+	return userAgents[rng.Intn(len(userAgents))]
 }
 
-func countReflections(params url.Values, body []byte) int {
-	count := 0
-	for _, values := range params {
+// reflectedParams returns the parameters whose value appears in the body.
+func reflectedParams(params url.Values, body []byte) []string {
+	var names []string
+	for key, values := range params {
+		if _, fixed := paramValues[key]; fixed {
+			// Predefined values such as "true" or "1" appear in pages regardless of the parameter
+			continue
+		}
 		for _, value := range values {
 			if bytes.Contains(body, []byte(value)) {
-				count++
+				names = append(names, key)
+				break
 			}
 		}
 	}
-	return count
+	return names
+}
+
+// appendUnique appends the params that aren't already present, returning a new slice.
+func appendUnique(params []string, extra []string) []string {
+	seen := make(map[string]bool, len(params))
+	result := make([]string, 0, len(params)+len(extra))
+	for _, param := range append(params[:len(params):len(params)], extra...) {
+		if !seen[param] {
+			seen[param] = true
+			result = append(result, param)
+		}
+	}
+	return result
 }
 
 func createHTTPClient() *http.Client {
-	if ignoreCertErrors {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConns = 100
+	tr.MaxIdleConnsPerHost = 100
+	tr.IdleConnTimeout = 90 * time.Second
+	tr.DisableKeepAlives = false
+	tr.DialContext = newDialer().DialContext
+	if dial := newDialContext(); dial != nil {
+		tr.DialContext = dial
+	}
+	tr.TLSHandshakeTimeout = tlsTimeout
+	tr.ResponseHeaderTimeout = responseTimeout
+
+	tlsConfig, err := buildTLSConfig()
+	if err != nil {
+		logger.Error("Failed to build TLS configuration", "error", err)
+	}
+	if tlsConfig != nil {
+		tr.TLSClientConfig = tlsConfig
+	}
+	client := &http.Client{Transport: tr, Timeout: requestTimeout}
+	if replayCapture != nil {
+		client.Transport = replayCapture
+	}
+	cookies = nil
+	if reuseCookies {
+		cookies = newBaselineJar()
+		client.Jar = cookies
+	}
+	return client
+}
+
+// buildTLSConfig returns the TLS configuration derived from the command line
+// options, or nil when the defaults should be used.
+func buildTLSConfig() (*tls.Config, error) {
+	if !ignoreCertErrors && clientCert == "" && clientKey == "" && tlsMin == "" && tlsMax == "" && hostOverride == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: ignoreCertErrors, ServerName: hostOverride}
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, fmt.Errorf("both a client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
 		}
-		return &http.Client{Transport: tr}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	var err error
+	if config.MinVersion, err = parseTLSVersion(tlsMin); err != nil {
+		return nil, err
+	}
+	if config.MaxVersion, err = parseTLSVersion(tlsMax); err != nil {
+		return nil, err
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return nil, fmt.Errorf("minimum TLS version %s is greater than maximum %s", tlsMin, tlsMax)
 	}
-	return &http.Client{}
+	return config, nil
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q", version)
 }
 
 func generateParams(params []string) url.Values {
 	values := url.Values{}
+	canaries := make(map[string]bool, len(params))
 	for _, param := range params {
-		values.Set(param, randomString(8))
+		if value, ok := paramValues[param]; ok {
+			values.Set(param, value)
+			continue
+		}
+		// Every parameter gets its own canary so a reflection points to exactly one of them
+		values[param] = canaryValues(func() string {
+			canary := randomString(8)
+			for canaries[canary] {
+				canary = randomString(8)
+			}
+			canaries[canary] = true
+			return canary
+		})
 	}
 	return values
 }
@@ -107,7 +228,7 @@ func randomString(n int) string {
 	letters := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+		b[i] = letters[rng.Intn(len(letters))]
 	}
 	return string(b)
 }
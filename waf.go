@@ -0,0 +1,65 @@
+package main
+
+import "sync"
+
+// wafThreshold is the number of consecutive changed responses sharing the same
+// fingerprint after which the target is assumed to be blocking the scan.
+// A value of zero disables the detection.
+var wafThreshold = 20
+
+// blockDetector tracks runs of consecutive candidate responses that differ from
+// the baseline but are similar to each other, which is the typical symptom of a
+// WAF or rate limiter kicking in mid-scan.
+type blockDetector struct {
+	mu        sync.Mutex
+	reference *ResponseData
+	streak    int
+	blocked   bool
+}
+
+var detector = &blockDetector{}
+
+func (d *blockDetector) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reference = nil
+	d.streak = 0
+	d.blocked = false
+}
+
+// observe records a candidate response and reports whether the scan should be
+// considered blocked.
+func (d *blockDetector) observe(response ResponseData, changed bool) bool {
+	if wafThreshold <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.blocked {
+		return true
+	}
+	if !changed {
+		d.reference = nil
+		d.streak = 0
+		return false
+	}
+
+	if d.reference != nil && responsesAreSimilar(*d.reference, response) {
+		d.streak++
+	} else {
+		d.reference = &response
+		d.streak = 1
+	}
+
+	if d.streak >= wafThreshold {
+		d.blocked = true
+		logger.Warn("Consecutive responses changed to the same new page. The target is possibly blocking the scan.", "status", response.StatusCode, "streak", d.streak)
+	}
+	return d.blocked
+}
+
+func (d *blockDetector) isBlocked() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.blocked
+}
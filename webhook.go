@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// webhookURL receives a POST for every parameter confirmed during a scan.
+var webhookURL string
+
+// webhookQueueSize bounds the notifications waiting to be sent. Once the
+// queue is full, e.g. because the receiver is slow, notifications are dropped
+// rather than holding up the scan.
+const webhookQueueSize = 100
+
+// webhookDrainTimeout bounds how long the end of a scan waits for the queued
+// notifications. The ones still queued then are abandoned, so an unreachable
+// receiver doesn't hold up the report.
+var webhookDrainTimeout = 10 * time.Second
+
+// WebhookEvent is the JSON payload posted to webhookURL.
+type WebhookEvent struct {
+	Parameter string `json:"parameter"`
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	Reflected bool   `json:"reflected"`
+}
+
+// webhookNotifier posts the events queued during a scan from a single worker.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+	events chan WebhookEvent
+	done   chan struct{}
+	// ctx is canceled to abort the posts once the drain timed out.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// webhook is the notifier of the current scan, nil without -webhook.
+var webhook *webhookNotifier
+
+func startWebhook(url string) *webhookNotifier {
+	notifier := &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		events: make(chan WebhookEvent, webhookQueueSize),
+		done:   make(chan struct{}),
+	}
+	notifier.ctx, notifier.cancel = context.WithCancel(context.Background())
+	go notifier.run()
+	return notifier
+}
+
+func (n *webhookNotifier) run() {
+	defer close(n.done)
+	for event := range n.events {
+		if n.ctx.Err() != nil {
+			continue
+		}
+		n.post(event)
+	}
+}
+
+func (n *webhookNotifier) post(event WebhookEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		logger.Warn("Failed to notify the webhook", "parameter", event.Parameter, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logger.Warn("The webhook rejected the notification", "parameter", event.Parameter, "status", resp.StatusCode)
+	}
+}
+
+// notify queues event without blocking, dropping it when the queue is full.
+func (n *webhookNotifier) notify(event WebhookEvent) {
+	select {
+	case n.events <- event:
+	default:
+		logger.Warn("Webhook queue full, dropping the notification", "parameter", event.Parameter)
+	}
+}
+
+// close waits for the queued notifications to be sent, for at most
+// webhookDrainTimeout.
+func (n *webhookNotifier) close() {
+	close(n.events)
+	select {
+	case <-n.done:
+	case <-time.After(webhookDrainTimeout):
+		logger.Warn("Timed out sending the webhook notifications, abandoning the rest", "queued", len(n.events))
+		n.cancel()
+		<-n.done
+	}
+	n.cancel()
+}
+
+// notifyDiscovered tells the webhook, if any, that param was confirmed.
+func notifyDiscovered(request Request, param string) {
+	if webhook == nil {
+		return
+	}
+	webhook.notify(WebhookEvent{Parameter: param, URL: request.URL, Method: request.Method, Reflected: reflected.has(param)})
+}
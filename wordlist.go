@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultWordlist holds the most common parameter names, scanned when no
+// wordlist file is available.
+//
+//go:embed default-wordlist.txt
+var defaultWordlist string
+
+// wordlistValues makes loadWordlist read "name=value" lines as a parameter
+// name with a predefined value.
+var wordlistValues bool
+
+// paramValues holds the predefined values for parameters, which generateParams
+// uses instead of a random string.
+var paramValues = map[string]string{}
+
+// paramLocations holds where parameters are sent when their wordlist entry
+// says so, instead of the request's injection point.
+var paramLocations = map[string]string{}
+
+// Candidate is a wordlist entry. Plain text wordlists only carry names, while
+// JSON lines wordlists can also give the value, where the parameter is sent
+// and the method it is tested with. A nil Value gets a random canary.
+type Candidate struct {
+	Name   string  `json:"name"`
+	Value  *string `json:"value"`
+	In     string  `json:"in"`
+	Method string  `json:"method"`
+}
+
+// loadWordlist reads the candidates of a wordlist, one per line. Files with a
+// .jsonl extension hold one JSON object per line, any other file holds names.
+func loadWordlist(wordlist string) ([]Candidate, error) {
+	file, err := os.Open(wordlist)
+	if err != nil {
+		return nil, fmt.Errorf("opening wordlist: %w", err)
+	}
+	defer file.Close()
+	return readCandidates(file, strings.EqualFold(filepath.Ext(wordlist), ".jsonl"))
+}
+
+// appendWordlistPath is the wordlist file the parameters discovered by a scan
+// are added to when it doesn't list them yet.
+var appendWordlistPath string
+
+// appendWordlist appends the params that the wordlist file doesn't list yet
+// to it, creating it when needed, and returns them. A .jsonl wordlist gets
+// JSON objects with the name alone.
+func appendWordlist(path string, params []string) ([]string, error) {
+	jsonLines := strings.EqualFold(filepath.Ext(path), ".jsonl")
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	candidates, err := readCandidates(bytes.NewReader(data), jsonLines)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool)
+	for _, candidate := range candidates {
+		name := strings.TrimSpace(candidate.Name)
+		listed[name] = true
+		if !jsonLines {
+			// Lines read with -wordlist-values list the name before the value
+			name, _, _ = strings.Cut(name, "=")
+			listed[name] = true
+		}
+	}
+
+	var added []string
+	var lines bytes.Buffer
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		lines.WriteString("\n")
+	}
+	for _, param := range params {
+		if listed[param] {
+			continue
+		}
+		listed[param] = true
+		added = append(added, param)
+		if jsonLines {
+			line, _ := json.Marshal(map[string]string{"name": param})
+			lines.Write(line)
+		} else {
+			lines.WriteString(param)
+		}
+		lines.WriteString("\n")
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(lines.Bytes()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return added, file.Close()
+}
+
+// loadWordlistOrDefault loads the wordlist file, falling back to the
+// embedded defaultWordlist when no file is given or the file doesn't exist.
+func loadWordlistOrDefault(wordlist string) ([]Candidate, error) {
+	if wordlist != "" {
+		candidates, err := loadWordlist(wordlist)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return candidates, err
+		}
+		logger.Warn("Wordlist not found, using the built-in wordlist", "wordlist", wordlist)
+	} else {
+		logger.Warn("No wordlist given, using the built-in wordlist")
+	}
+	return readCandidates(strings.NewReader(defaultWordlist), false)
+}
+
+// readCandidates reads the candidates of a wordlist from r, names or JSON
+// objects one per line.
+func readCandidates(r io.Reader, jsonLines bool) ([]Candidate, error) {
+	var candidates []Candidate
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if !jsonLines {
+			candidates = append(candidates, textCandidate(line))
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		candidate, err := jsonCandidate(line)
+		if err != nil {
+			return nil, fmt.Errorf("wordlist line %d: %w", lineNumber, err)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading wordlist: %w", err)
+	}
+
+	return candidates, nil
+}
+
+func textCandidate(line string) Candidate {
+	if wordlistValues {
+		if name, value, found := strings.Cut(line, "="); found && name != "" {
+			return Candidate{Name: name, Value: &value}
+		}
+	}
+	return Candidate{Name: line}
+}
+
+func jsonCandidate(line string) (Candidate, error) {
+	var candidate Candidate
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&candidate); err != nil {
+		return Candidate{}, err
+	}
+	if candidate.Name == "" {
+		return Candidate{}, fmt.Errorf("missing parameter name")
+	}
+	candidate.In = strings.ToLower(candidate.In)
+	switch candidate.In {
+	case "", "query", "body", "header", "cookie":
+	default:
+		return Candidate{}, fmt.Errorf("unsupported location %q for %s, expected query, body, header or cookie", candidate.In, candidate.Name)
+	}
+	candidate.Method = strings.ToUpper(candidate.Method)
+	return candidate, nil
+}
+
+// methodGroup is the set of candidates tested with the same method.
+type methodGroup struct {
+	Method string
+	Params []string
+}
+
+// applyCandidates records the values and locations of the candidates and
+// groups their names by method, in the order the methods first appear.
+// Candidates without a method are tested with defaultMethod.
+func applyCandidates(candidates []Candidate, defaultMethod string) []methodGroup {
+	var groups []methodGroup
+	index := make(map[string]int)
+	for _, candidate := range candidates {
+		if candidate.Value != nil {
+			paramValues[candidate.Name] = *candidate.Value
+		}
+		if candidate.In != "" {
+			paramLocations[candidate.Name] = candidate.In
+		}
+
+		method := candidate.Method
+		if method == "" {
+			method = defaultMethod
+		}
+		i, ok := index[method]
+		if !ok {
+			i = len(groups)
+			index[method] = i
+			groups = append(groups, methodGroup{Method: method})
+		}
+		groups[i].Params = append(groups[i].Params, candidate.Name)
+	}
+	if len(groups) == 0 {
+		// An empty wordlist still scans the form parameters
+		groups = append(groups, methodGroup{Method: defaultMethod})
+	}
+	return groups
+}